@@ -1 +1,14 @@
 package main
+
+import (
+	demo520 "demo520/internal/520"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if err := demo520.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
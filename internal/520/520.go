@@ -1 +1,139 @@
 package demo520
+
+import (
+	"context"
+	"demo520/internal/520/biz/image"
+	"demo520/internal/520/biz/tag"
+	"demo520/internal/pkg/convert"
+	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/middleware"
+	"demo520/pkg/token"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// Run 是 520 服务的入口：读取配置，初始化依赖，启动 HTTP 服务并在收到退出信号后优雅关停.
+// 命令行中的第一个非选项参数用于选择维护子命令，例如 `520 -c 520.yaml gc -dry-run`.
+func Run() error {
+	var cfgFile string
+	flag.StringVar(&cfgFile, "c", "", "The path to the 520 configuration file. Empty string for no configuration file.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-c config] [command]\n\nCommands:\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "  gc\tcollect image files that are no longer referenced")
+		fmt.Fprintln(flag.CommandLine.Output(), "  backfill\trecord dimensions, type and sizes of images uploaded before they were tracked")
+		fmt.Fprintln(flag.CommandLine.Output(), "\nOptions:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := initConfig(cfgFile); err != nil {
+		return err
+	}
+
+	// 初始化日志
+	log.Init(logOptions())
+	defer log.Sync() // Sync 将缓存中的日志刷新到磁盘文件中
+
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		return run()
+	case "gc":
+		return runGC(flag.Args()[1:])
+	case "backfill":
+		return runBackfill()
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command: %s", cmd)
+	}
+}
+
+// run 函数是实际的业务代码入口函数.
+func run() error {
+	// 设置 token 包的签发密钥，用于 token 包 token 的签发和解析
+	token.Init(viper.GetString("jwt-secret"))
+
+	// 初始化 store 层
+	ds, err := initStore()
+	if err != nil {
+		return err
+	}
+
+	// 初始化图片文件的存储后端
+	if err := initStorage(); err != nil {
+		return err
+	}
+
+	// 初始化图片转换器，退出前释放 libvips 资源
+	converter := convert.InitImageConverter()
+	defer converter.Shutdown()
+
+	// 启动异步图片转换队列，退出前等待正在执行的转换任务结束
+	convertQueue := image.NewConvertQueue(ds)
+	convertQueue.Start()
+	defer convertQueue.Stop()
+
+	// 启动标签改写队列，按别名和蕴含规则改写已有图片的标签
+	rewriteQueue := tag.NewRewriteQueue(ds)
+	rewriteQueue.Start()
+	defer rewriteQueue.Stop()
+
+	// 启动批量修改队列，分批执行批量修改图片标签和可见性的任务
+	bulkQueue := image.NewBulkQueue(ds)
+	bulkQueue.Start()
+	defer bulkQueue.Stop()
+
+	// 设置 Gin 模式
+	gin.SetMode(viper.GetString("runmode"))
+
+	// 创建 Gin 引擎
+	g := gin.New()
+	g.Use(gin.Recovery(), middleware.RequestID())
+
+	if err := installRouters(g, ds); err != nil {
+		return err
+	}
+
+	// 创建 HTTP Server 实例
+	httpsrv := &http.Server{Addr: viper.GetString("addr"), Handler: g}
+
+	// 运行 HTTP 服务器
+	// 打印一条日志，用来提示 HTTP 服务已经起来，方便排障
+	log.Infow("Start to listening the incoming requests on http address", "addr", viper.GetString("addr"))
+	go func() {
+		if err := httpsrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalw(err.Error())
+		}
+	}()
+
+	// 等待中断信号优雅地关闭服务器（10 秒超时)。
+	quit := make(chan os.Signal, 1)
+	// kill 默认会发送 syscall.SIGTERM 信号
+	// kill -2 发送 syscall.SIGINT 信号，我们常用的 CTRL + C 就是触发系统 SIGINT 信号
+	// kill -9 发送 syscall.SIGKILL 信号，但是不能被捕获，所以不需要添加它
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Infow("Shutting down server ...")
+
+	// 创建 ctx 用于通知服务器 goroutine, 它有 10 秒时间完成当前正在处理的请求
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 10 秒内优雅关闭服务（将未处理完的请求处理完再关闭服务），超过 10 秒就超时退出
+	if err := httpsrv.Shutdown(ctx); err != nil {
+		log.Errorw("Server forced to shutdown", "err", err)
+		return err
+	}
+
+	log.Infow("Server exiting")
+
+	return nil
+}
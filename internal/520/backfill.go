@@ -0,0 +1,31 @@
+package demo520
+
+import (
+	"context"
+	"demo520/internal/520/biz"
+	"demo520/internal/pkg/convert"
+	"encoding/json"
+	"os"
+)
+
+// runBackfill 为已有图片补齐固有属性，并将结果以 JSON 格式输出到标准输出.
+func runBackfill() error {
+	ds, err := initStore()
+	if err != nil {
+		return err
+	}
+	if err := initStorage(); err != nil {
+		return err
+	}
+	defer convert.InitImageConverter().Shutdown()
+
+	report, err := biz.NewIBiz(ds).Blobs().Backfill(context.Background())
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(report); encodeErr != nil && err == nil {
+			err = encodeErr
+		}
+	}
+	return err
+}
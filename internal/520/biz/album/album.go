@@ -0,0 +1,468 @@
+package album
+
+import (
+	"context"
+	"demo520/internal/520/biz/image"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxTitleLength 是合集标题允许的最大字符数，与 albums.title 列的长度一致.
+	maxTitleLength = 100
+	// maxDescriptionLength 是合集描述允许的最大字符数，与 albums.description 列的长度一致.
+	maxDescriptionLength = 500
+	// maxAlbumImages 是一个合集最多包含的图片数.
+	maxAlbumImages = 1000
+	// maxAddImages 是一次追加请求最多包含的图片数.
+	maxAddImages = 100
+)
+
+type AlbumBiz interface {
+	Create(ctx context.Context, userUUID string, r *api.CreateAlbumRequest) (*api.CreateAlbumResponse, error)
+	Get(ctx context.Context, userUUID string, albumUUID string, r *api.PageRequest) (*api.GetAlbumResponse, error)
+	Update(ctx context.Context, userUUID string, albumUUID string, r *api.UpdateAlbumRequest) (*api.UpdateAlbumResponse, error)
+	Delete(ctx context.Context, userUUID string, albumUUID string) error
+	ListUserAlbums(ctx context.Context, userUUID string, ownerUUID string, r *api.PageRequest) (*api.ListAlbumsResponse, error)
+	ListUserAlbumsByCursor(ctx context.Context, userUUID string, ownerUUID string, r *api.CursorRange) (*api.ListAlbumsByCursorResponse, error)
+	ListImagesByCursor(ctx context.Context, userUUID string, albumUUID string, r *api.CursorRange) (*api.ListImageResponse, error)
+	AddImages(ctx context.Context, userUUID string, albumUUID string, r *api.AddAlbumImagesRequest) error
+	RemoveImage(ctx context.Context, userUUID string, albumUUID string, imageUUID string) error
+	Reorder(ctx context.Context, userUUID string, albumUUID string, r *api.ReorderAlbumRequest) error
+}
+
+type albumBiz struct {
+	db     store.IStore
+	images image.ImageBiz
+}
+
+var _ AlbumBiz = (*albumBiz)(nil)
+
+func NewAlbumBiz(db store.IStore) AlbumBiz {
+	return &albumBiz{
+		db:     db,
+		images: image.NewImageBiz(db),
+	}
+}
+
+// validateTitle 校验并返回去除首尾空白后的合集标题.
+func validateTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" || !utf8.ValidString(title) || utf8.RuneCountInString(title) > maxTitleLength {
+		return "", fmt.Errorf("%w: title must be 1 to %d characters", errno.ErrAlbumTitleInvalid, maxTitleLength)
+	}
+	return title, nil
+}
+
+// validateDescription 校验合集描述的长度.
+func validateDescription(description string) error {
+	if !utf8.ValidString(description) || utf8.RuneCountInString(description) > maxDescriptionLength {
+		return fmt.Errorf("%w: description must be at most %d characters", errno.ErrInvalidParameter, maxDescriptionLength)
+	}
+	return nil
+}
+
+// checkImagesVisible 确认 imageUUIDs 中的每张图片对 userUUID 都可见，否则返回第一张不可见图片的错误.
+func (a *albumBiz) checkImagesVisible(ctx context.Context, userUUID string, imageUUIDs []string) error {
+	infos, err := a.images.ListByUUIDs(ctx, userUUID, imageUUIDs)
+	if err != nil {
+		return err
+	}
+	visible := make(map[string]struct{}, len(infos))
+	for _, info := range infos {
+		visible[info.ImageUUID] = struct{}{}
+	}
+	for _, imageUUID := range imageUUIDs {
+		if _, ok := visible[imageUUID]; ok {
+			continue
+		}
+		// 复用单张图片的可见性检查得到具体的错误
+		if _, err := a.images.Get(ctx, userUUID, imageUUID); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: image=%s", errno.ErrImageNotFound, imageUUID)
+	}
+	return nil
+}
+
+// getVisibleAlbum 读取合集记录并校验 userUUID 是否有权查看该合集，userUUID 为空表示匿名访问.
+func (a *albumBiz) getVisibleAlbum(ctx context.Context, userUUID string, albumUUID string) (*model.AlbumM, error) {
+	if !govalidator.IsUUID(albumUUID) {
+		return nil, fmt.Errorf("%w: invalid album UUID", errno.ErrInvalidParameter)
+	}
+	if userUUID != "" && !govalidator.IsUUID(userUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	album, err := a.db.Album().Get(ctx, albumUUID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: album=%s", errno.ErrAlbumNotFound, albumUUID)
+		}
+		return nil, fmt.Errorf("failed to get album: %w", err)
+	}
+	if !album.IsPublic && album.UserUUID != userUUID {
+		return nil, fmt.Errorf("%w: unauthorized operation", errno.ErrUnauthorized)
+	}
+	return album, nil
+}
+
+// getOwnedAlbum 读取合集记录并校验 userUUID 是否为合集的所有者.
+func (a *albumBiz) getOwnedAlbum(ctx context.Context, userUUID string, albumUUID string) (*model.AlbumM, error) {
+	if !govalidator.IsUUID(userUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	album, err := a.getVisibleAlbum(ctx, userUUID, albumUUID)
+	if err != nil {
+		return nil, err
+	}
+	if album.UserUUID != userUUID {
+		return nil, fmt.Errorf("%w: unauthorized operation", errno.ErrUnauthorized)
+	}
+	return album, nil
+}
+
+// buildAlbumInfos 将 albums 转换为 api.AlbumInfo，对 userUUID 不可见的封面会被隐藏.
+func (a *albumBiz) buildAlbumInfos(ctx context.Context, userUUID string, albums []*model.AlbumM) ([]api.AlbumInfo, error) {
+	var covers []string
+	for _, album := range albums {
+		if album.CoverImageUUID != "" {
+			covers = append(covers, album.CoverImageUUID)
+		}
+	}
+	visibleCovers := make(map[string]struct{}, len(covers))
+	if len(covers) > 0 {
+		infos, err := a.images.ListByUUIDs(ctx, userUUID, covers)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			visibleCovers[info.ImageUUID] = struct{}{}
+		}
+	}
+
+	ret := make([]api.AlbumInfo, len(albums))
+	for idx, album := range albums {
+		ret[idx] = api.AlbumInfo{
+			AlbumUUID:   album.AlbumUUID,
+			UserUUID:    album.UserUUID,
+			Title:       album.Title,
+			Description: album.Description,
+			IsPublic:    album.IsPublic,
+			CreatedAt:   album.CreatedAt.String(),
+			UpdatedAt:   album.UpdatedAt.String(),
+		}
+		if _, ok := visibleCovers[album.CoverImageUUID]; ok {
+			ret[idx].CoverImageUUID = album.CoverImageUUID
+		}
+	}
+	return ret, nil
+}
+
+func (a *albumBiz) Create(ctx context.Context, userUUID string, r *api.CreateAlbumRequest) (*api.CreateAlbumResponse, error) {
+	if !govalidator.IsUUID(userUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	title, err := validateTitle(r.Title)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDescription(r.Description); err != nil {
+		return nil, err
+	}
+	if r.CoverImageUUID != "" {
+		if err := a.checkImagesVisible(ctx, userUUID, []string{r.CoverImageUUID}); err != nil {
+			return nil, err
+		}
+	}
+
+	album := &model.AlbumM{
+		AlbumUUID:      uuid.New().String(),
+		UserUUID:       userUUID,
+		Title:          title,
+		Description:    r.Description,
+		IsPublic:       r.IsPublic,
+		CoverImageUUID: r.CoverImageUUID,
+	}
+	if err := a.db.Album().Create(ctx, album); err != nil {
+		return nil, fmt.Errorf("failed to create album: %w", err)
+	}
+	infos, err := a.buildAlbumInfos(ctx, userUUID, []*model.AlbumM{album})
+	if err != nil {
+		return nil, err
+	}
+	ret := api.CreateAlbumResponse(infos[0])
+	return &ret, nil
+}
+
+// Get 返回合集信息以及按合集顺序分页的图片，其他用户的私有图片不会出现在结果中.
+func (a *albumBiz) Get(ctx context.Context, userUUID string, albumUUID string, r *api.PageRequest) (*api.GetAlbumResponse, error) {
+	page, pageSize, offset, err := image.NormalizePage(r)
+	if err != nil {
+		return nil, err
+	}
+	album, err := a.getVisibleAlbum(ctx, userUUID, albumUUID)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := a.buildAlbumInfos(ctx, userUUID, []*model.AlbumM{album})
+	if err != nil {
+		return nil, err
+	}
+
+	total, imageUUIDs, err := a.db.Album().ListVisibleImageUUIDs(ctx, albumUUID, userUUID, offset, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list album images: %w", err)
+	}
+	images, err := a.images.ListByUUIDs(ctx, userUUID, imageUUIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &api.GetAlbumResponse{
+		AlbumInfo: infos[0],
+		Images: api.ImagePageResponse{
+			Total:     total,
+			Page:      page,
+			PageSize:  pageSize,
+			ImageList: images,
+		},
+	}, nil
+}
+
+// Update 修改合集的标题、描述、可见性和封面，只有合集所有者可以修改.
+func (a *albumBiz) Update(ctx context.Context, userUUID string, albumUUID string, r *api.UpdateAlbumRequest) (*api.UpdateAlbumResponse, error) {
+	updates := make(map[string]interface{})
+	if r.Title != nil {
+		title, err := validateTitle(*r.Title)
+		if err != nil {
+			return nil, err
+		}
+		updates["title"] = title
+	}
+	if r.Description != nil {
+		if err := validateDescription(*r.Description); err != nil {
+			return nil, err
+		}
+		updates["description"] = *r.Description
+	}
+	if r.IsPublic != nil {
+		updates["is_public"] = *r.IsPublic
+	}
+
+	if _, err := a.getOwnedAlbum(ctx, userUUID, albumUUID); err != nil {
+		return nil, err
+	}
+	if r.CoverImageUUID != nil {
+		if *r.CoverImageUUID != "" {
+			if err := a.checkImagesVisible(ctx, userUUID, []string{*r.CoverImageUUID}); err != nil {
+				return nil, err
+			}
+		}
+		updates["coverImageUUID"] = *r.CoverImageUUID
+	}
+	if err := a.db.Album().Update(ctx, albumUUID, updates); err != nil {
+		return nil, fmt.Errorf("failed to update album: %w", err)
+	}
+
+	album, err := a.db.Album().Get(ctx, albumUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get album: %w", err)
+	}
+	infos, err := a.buildAlbumInfos(ctx, userUUID, []*model.AlbumM{album})
+	if err != nil {
+		return nil, err
+	}
+	ret := api.UpdateAlbumResponse(infos[0])
+	return &ret, nil
+}
+
+func (a *albumBiz) Delete(ctx context.Context, userUUID string, albumUUID string) error {
+	if _, err := a.getOwnedAlbum(ctx, userUUID, albumUUID); err != nil {
+		return err
+	}
+	if err := a.db.Album().Delete(ctx, albumUUID); err != nil {
+		return fmt.Errorf("failed to delete album: %w", err)
+	}
+	return nil
+}
+
+// ListUserAlbums 列出 ownerUUID 的合集，userUUID 不是 ownerUUID 时只列出公开合集.
+func (a *albumBiz) ListUserAlbums(ctx context.Context, userUUID string, ownerUUID string, r *api.PageRequest) (*api.ListAlbumsResponse, error) {
+	if !govalidator.IsUUID(ownerUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	page, pageSize, offset, err := image.NormalizePage(r)
+	if err != nil {
+		return nil, err
+	}
+	total, albums, err := a.db.Album().ListByUser(ctx, ownerUUID, userUUID != ownerUUID, offset, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list albums: %w", err)
+	}
+	infos, err := a.buildAlbumInfos(ctx, userUUID, albums)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ListAlbumsResponse{
+		Total:     total,
+		Page:      page,
+		PageSize:  pageSize,
+		AlbumList: infos,
+	}, nil
+}
+
+// ListUserAlbumsByCursor 与 ListUserAlbums 相同，但按游标分页.
+func (a *albumBiz) ListUserAlbumsByCursor(ctx context.Context, userUUID string, ownerUUID string, r *api.CursorRange) (*api.ListAlbumsByCursorResponse, error) {
+	if !govalidator.IsUUID(ownerUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	limit, err := image.NormalizeLimit(r.Limit)
+	if err != nil {
+		return nil, err
+	}
+	var after *store.AlbumCursor
+	if r.Cursor != "" {
+		key, albumUUID, err := image.ParseCursor(image.CursorAlbums, r.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &store.AlbumCursor{Time: time.Unix(0, key), AlbumUUID: albumUUID}
+	}
+
+	publicOnly := userUUID != ownerUUID
+	// 多取一个以判断是否还有下一页
+	albums, err := a.db.Album().ListByUserAfter(ctx, ownerUUID, publicOnly, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list albums: %w", err)
+	}
+	ret := &api.ListAlbumsByCursorResponse{}
+	if len(albums) > limit {
+		albums = albums[:limit]
+		last := albums[limit-1]
+		ret.NextCursor = image.FormatCursor(image.CursorAlbums, last.CreatedAt.UnixNano(), last.AlbumUUID)
+	}
+	if ret.AlbumList, err = a.buildAlbumInfos(ctx, userUUID, albums); err != nil {
+		return nil, err
+	}
+	ret.Count = len(albums)
+	if r.WithTotal {
+		total, err := a.db.Album().CountByUser(ctx, ownerUUID, publicOnly)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count albums: %w", err)
+		}
+		ret.Total = &total
+	}
+	return ret, nil
+}
+
+// ListImagesByCursor 按合集中的顺序以游标分页列出 userUUID 可见的图片，与 Get 中的图片相同.
+func (a *albumBiz) ListImagesByCursor(ctx context.Context, userUUID string, albumUUID string, r *api.CursorRange) (*api.ListImageResponse, error) {
+	limit, err := image.NormalizeLimit(r.Limit)
+	if err != nil {
+		return nil, err
+	}
+	var after *store.ImageCursor
+	if r.Cursor != "" {
+		key, imageUUID, err := image.ParseCursor(image.CursorAlbumImages, r.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &store.ImageCursor{Position: int(key), ImageUUID: imageUUID}
+	}
+	if _, err := a.getVisibleAlbum(ctx, userUUID, albumUUID); err != nil {
+		return nil, err
+	}
+
+	// 多取一张以判断是否还有下一页
+	rows, err := a.db.Album().ListVisibleImagesAfter(ctx, albumUUID, userUUID, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list album images: %w", err)
+	}
+	ret := &api.ListImageResponse{}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		ret.NextCursor = image.FormatCursor(image.CursorAlbumImages, int64(last.Position), last.ImageUUID)
+	}
+	imageUUIDs := make([]string, len(rows))
+	for i, row := range rows {
+		imageUUIDs[i] = row.ImageUUID
+	}
+	if ret.ImageList, err = a.images.ListByUUIDs(ctx, userUUID, imageUUIDs); err != nil {
+		return nil, err
+	}
+	ret.Count = len(ret.ImageList)
+	if r.WithTotal {
+		total, err := a.db.Album().CountVisibleImages(ctx, albumUUID, userUUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count album images: %w", err)
+		}
+		ret.Total = &total
+	}
+	return ret, nil
+}
+
+// AddImages 按顺序将图片追加到合集末尾. 图片必须对合集所有者可见，已在合集中的图片保持原位置.
+func (a *albumBiz) AddImages(ctx context.Context, userUUID string, albumUUID string, r *api.AddAlbumImagesRequest) error {
+	if len(r.ImageUUIDs) == 0 || len(r.ImageUUIDs) > maxAddImages {
+		return fmt.Errorf("%w: image_uuids must contain 1 to %d images", errno.ErrInvalidParameter, maxAddImages)
+	}
+	for _, imageUUID := range r.ImageUUIDs {
+		if !govalidator.IsUUID(imageUUID) {
+			return fmt.Errorf("%w: invalid image UUID %q", errno.ErrInvalidParameter, imageUUID)
+		}
+	}
+	if _, err := a.getOwnedAlbum(ctx, userUUID, albumUUID); err != nil {
+		return err
+	}
+	if err := a.checkImagesVisible(ctx, userUUID, r.ImageUUIDs); err != nil {
+		return err
+	}
+	if err := a.db.Album().AddImages(ctx, albumUUID, r.ImageUUIDs, maxAlbumImages); err != nil {
+		if errors.Is(err, errno.ErrAlbumFull) {
+			return err
+		}
+		return fmt.Errorf("failed to add album images: %w", err)
+	}
+	return nil
+}
+
+func (a *albumBiz) RemoveImage(ctx context.Context, userUUID string, albumUUID string, imageUUID string) error {
+	if !govalidator.IsUUID(imageUUID) {
+		return fmt.Errorf("%w: invalid image UUID", errno.ErrInvalidParameter)
+	}
+	if _, err := a.getOwnedAlbum(ctx, userUUID, albumUUID); err != nil {
+		return err
+	}
+	if err := a.db.Album().RemoveImage(ctx, albumUUID, imageUUID); err != nil {
+		return fmt.Errorf("failed to remove album image: %w", err)
+	}
+	return nil
+}
+
+// Reorder 按请求中的顺序重新排列合集，请求必须恰好包含合集中的每张图片一次.
+func (a *albumBiz) Reorder(ctx context.Context, userUUID string, albumUUID string, r *api.ReorderAlbumRequest) error {
+	if len(r.ImageUUIDs) > maxAlbumImages {
+		return fmt.Errorf("%w: at most %d images", errno.ErrAlbumOrderMismatch, maxAlbumImages)
+	}
+	if _, err := a.getOwnedAlbum(ctx, userUUID, albumUUID); err != nil {
+		return err
+	}
+	if err := a.db.Album().Reorder(ctx, albumUUID, r.ImageUUIDs); err != nil {
+		if errors.Is(err, errno.ErrAlbumOrderMismatch) {
+			return err
+		}
+		return fmt.Errorf("failed to reorder album: %w", err)
+	}
+	return nil
+}
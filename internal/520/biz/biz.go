@@ -1,7 +1,10 @@
 package biz
 
 import (
+	"demo520/internal/520/biz/album"
+	"demo520/internal/520/biz/blob"
 	"demo520/internal/520/biz/image"
+	"demo520/internal/520/biz/tag"
 	"demo520/internal/520/biz/user"
 	"demo520/internal/520/store"
 )
@@ -9,6 +12,9 @@ import (
 type IBiz interface {
 	Images() image.ImageBiz
 	Users() user.UserBiz
+	Blobs() blob.BlobBiz
+	Albums() album.AlbumBiz
+	Tags() tag.TagBiz
 }
 
 type biz struct {
@@ -28,3 +34,15 @@ func (b *biz) Images() image.ImageBiz {
 func (b *biz) Users() user.UserBiz {
 	return user.NewUserBiz(b.db)
 }
+
+func (b *biz) Blobs() blob.BlobBiz {
+	return blob.NewBlobBiz(b.db)
+}
+
+func (b *biz) Albums() album.AlbumBiz {
+	return album.NewAlbumBiz(b.db)
+}
+
+func (b *biz) Tags() tag.TagBiz {
+	return tag.NewTagBiz(b.db)
+}
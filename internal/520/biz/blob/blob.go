@@ -0,0 +1,175 @@
+package blob
+
+import (
+	"context"
+	"demo520/internal/520/biz/image"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/log"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// defaultGracePeriod 是 blob 最近一次上传后至少需要经过的时间，保护尚未写入图片记录的上传.
+	defaultGracePeriod = time.Hour
+	// defaultRecoveryWindow 是软删除的图片仍可恢复的时间，期间其文件不会被回收.
+	defaultRecoveryWindow = 30 * 24 * time.Hour
+	// gcBatchSize 是每批检查的 blob 数量.
+	gcBatchSize = 100
+	// backfillBatchSize 是补齐固有属性时每批处理的记录数.
+	backfillBatchSize = 100
+)
+
+type BlobBiz interface {
+	Collect(ctx context.Context, dryRun bool) (*GCReport, error)
+	Backfill(ctx context.Context) (*BackfillReport, error)
+}
+
+// GCReport 是一次垃圾回收的结果. DryRun 时 Collected 中列出的是将会被回收的 hash.
+type GCReport struct {
+	DryRun     bool     `json:"dry_run"`
+	Scanned    int      `json:"scanned"`
+	Collected  []string `json:"collected"`
+	Skipped    []string `json:"skipped"`
+	FreedBytes int64    `json:"freed_bytes"`
+}
+
+// BackfillReport 是一次固有属性补齐的结果. Failed 中列出的是原图缺失或无法解析的 hash.
+type BackfillReport struct {
+	Images   int      `json:"images"`
+	Variants int      `json:"variants"`
+	Failed   []string `json:"failed"`
+}
+
+type blobBiz struct {
+	db             store.IStore
+	imageFileStore image.ImageFileStore
+}
+
+var _ BlobBiz = (*blobBiz)(nil)
+
+func NewBlobBiz(db store.IStore) BlobBiz {
+	return &blobBiz{
+		db:             db,
+		imageFileStore: image.NewImageFileStore(),
+	}
+}
+
+// durationOrDefault 读取 viper 中的时长配置，未配置或非法时返回 def.
+func durationOrDefault(key string, def time.Duration) time.Duration {
+	if d := viper.GetDuration(key); d > 0 {
+		return d
+	}
+	return def
+}
+
+// Collect 回收不再被任何存活或可恢复图片引用的 blob 文件. dryRun 为 true 时只报告不删除.
+func (b *blobBiz) Collect(ctx context.Context, dryRun bool) (*GCReport, error) {
+	now := time.Now()
+	touchedBefore := now.Add(-durationOrDefault("BlobGCGracePeriod", defaultGracePeriod))
+	recoverableAfter := now.Add(-durationOrDefault("ImageRecoveryWindow", defaultRecoveryWindow))
+
+	report := &GCReport{DryRun: dryRun, Collected: []string{}, Skipped: []string{}}
+	afterHash := ""
+	for {
+		blobs, err := b.db.Blob().ListUnreferenced(ctx, afterHash, recoverableAfter, touchedBefore, gcBatchSize)
+		if err != nil {
+			return report, fmt.Errorf("failed to list unreferenced blobs: %w", err)
+		}
+		if len(blobs) == 0 {
+			break
+		}
+		for _, blob := range blobs {
+			afterHash = blob.Hash
+			report.Scanned++
+			size, err := b.imageFileStore.Usage(ctx, blob.Hash)
+			if err != nil {
+				log.C(ctx).Warnw("Failed to stat blob files", "hash", blob.Hash, "err", err)
+			}
+			if dryRun {
+				report.Collected = append(report.Collected, blob.Hash)
+				report.FreedBytes += size
+				continue
+			}
+			hash := blob.Hash
+			deleted, err := b.db.Blob().DeleteIfUnreferenced(ctx, hash, recoverableAfter, touchedBefore, func() error {
+				return b.imageFileStore.Remove(ctx, hash)
+			})
+			if err != nil {
+				return report, fmt.Errorf("failed to collect blob %s: %w", hash, err)
+			}
+			if !deleted {
+				// 列出后又被重新引用
+				report.Skipped = append(report.Skipped, hash)
+				continue
+			}
+			log.C(ctx).Infow("Collected image blob", "hash", hash, "bytes", size)
+			report.Collected = append(report.Collected, hash)
+			report.FreedBytes += size
+		}
+	}
+	return report, nil
+}
+
+// Backfill 为固有属性功能上线前上传的图片补齐宽高、MIME 类型、字节数和差异哈希，并补齐已生成的转换格式的字节数.
+func (b *blobBiz) Backfill(ctx context.Context) (*BackfillReport, error) {
+	report := &BackfillReport{Failed: []string{}}
+	afterHash := ""
+	for {
+		hashes, err := b.db.Image().ListHashesWithoutProperties(ctx, afterHash, backfillBatchSize)
+		if err != nil {
+			return report, fmt.Errorf("failed to list images without properties: %w", err)
+		}
+		if len(hashes) == 0 {
+			break
+		}
+		for _, hash := range hashes {
+			afterHash = hash
+			props, err := b.imageFileStore.Probe(ctx, hash)
+			if err != nil {
+				log.C(ctx).Warnw("Failed to probe image blob", "hash", hash, "err", err)
+				report.Failed = append(report.Failed, hash)
+				continue
+			}
+			if err := b.db.Image().UpdateByHash(ctx, hash, map[string]interface{}{
+				"width":     props.Width,
+				"height":    props.Height,
+				"mime_type": props.MimeType,
+				"size":      props.Size,
+				"phash":     props.PHash,
+			}); err != nil {
+				return report, fmt.Errorf("failed to update image properties of %s: %w", hash, err)
+			}
+			report.Images++
+		}
+	}
+
+	var afterID uint
+	for {
+		jobs, err := b.db.ConvertJob().ListReadyWithoutSize(ctx, afterID, backfillBatchSize)
+		if err != nil {
+			return report, fmt.Errorf("failed to list convert jobs without size: %w", err)
+		}
+		if len(jobs) == 0 {
+			break
+		}
+		for _, job := range jobs {
+			afterID = job.ID
+			file, err := b.imageFileStore.Open(ctx, job.Hash, image.Variant(job.Format))
+			if err != nil {
+				log.C(ctx).Warnw("Failed to open converted image", "hash", job.Hash, "format", job.Format, "err", err)
+				report.Failed = append(report.Failed, job.Hash)
+				continue
+			}
+			job.Size = file.Size
+			file.Close()
+			if err := b.db.ConvertJob().Update(ctx, job); err != nil {
+				return report, fmt.Errorf("failed to update convert job %d: %w", job.ID, err)
+			}
+			report.Variants++
+		}
+	}
+	return report, nil
+}
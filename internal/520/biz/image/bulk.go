@@ -0,0 +1,171 @@
+package image
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxBulkImages 是一个批量修改任务最多直接列出的图片数.
+	maxBulkImages = 10000
+	// bulkChunkSize 是批量修改每个事务处理的图片数，每批处理后保存一次进度.
+	bulkChunkSize = 100
+)
+
+func bulkJobInfo(job *model.BulkJobM) *api.BulkJobInfo {
+	return &api.BulkJobInfo{
+		ID:        job.ID,
+		Status:    job.Status,
+		Total:     job.Total,
+		Processed: job.Processed,
+		Skipped:   job.Skipped,
+		LastError: job.LastError,
+		CreatedAt: job.CreatedAt.String(),
+		UpdatedAt: job.UpdatedAt.String(),
+	}
+}
+
+// bulkSelection 校验并去重 r 选中的图片，ImageUUIDs 按顺序排列以便分批处理.
+func (i *imageBiz) bulkSelection(ctx context.Context, userUUID string, r *api.BulkEditImagesRequest, job *model.BulkJobM) error {
+	if (len(r.ImageUUIDs) > 0) == (r.Query != "") {
+		return fmt.Errorf("%w: exactly one of image_uuids and query is required", errno.ErrInvalidParameter)
+	}
+	if r.Query != "" {
+		q, err := parseQuery(r.Query)
+		if err != nil {
+			return err
+		}
+		if q.IsEmpty() {
+			return fmt.Errorf("%w: empty query", errno.ErrInvalidSearchQuery)
+		}
+		if job.Total, err = i.db.Image().CountOwnMatching(ctx, userUUID, q); err != nil {
+			return fmt.Errorf("failed to count images: %w", err)
+		}
+		job.Query = r.Query
+		return nil
+	}
+	if len(r.ImageUUIDs) > maxBulkImages {
+		return fmt.Errorf("%w: at most %d images per request", errno.ErrInvalidParameter, maxBulkImages)
+	}
+	for _, imageUUID := range r.ImageUUIDs {
+		if !govalidator.IsUUID(imageUUID) {
+			return fmt.Errorf("%w: invalid image UUID %q", errno.ErrInvalidParameter, imageUUID)
+		}
+	}
+	job.ImageUUIDs = slices.Compact(slices.Sorted(slices.Values(r.ImageUUIDs)))
+	job.Total = int64(len(job.ImageUUIDs))
+	return nil
+}
+
+// CreateBulkJob 校验批量修改请求并创建后台任务，任务由 BulkQueue 分批执行，进度通过 GetBulkJob 查询.
+func (i *imageBiz) CreateBulkJob(ctx context.Context, userUUID string, r *api.BulkEditImagesRequest) (*api.BulkJobInfo, error) {
+	if !govalidator.IsUUID(userUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	addTags, err := normalizeTags(r.AddTags)
+	if err != nil {
+		return nil, err
+	}
+	removeTags, err := normalizeTags(r.RemoveTags)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range addTags {
+		if slices.Contains(removeTags, tag) {
+			return nil, fmt.Errorf("%w: tag %q is both added and removed", errno.ErrInvalidParameter, tag)
+		}
+	}
+	if r.Visibility != "" && !model.IsValidVisibility(r.Visibility) {
+		return nil, fmt.Errorf("%w: invalid visibility %q", errno.ErrInvalidParameter, r.Visibility)
+	}
+	if len(addTags) == 0 && len(removeTags) == 0 && r.Visibility == "" {
+		return nil, fmt.Errorf("%w: no operation given", errno.ErrInvalidParameter)
+	}
+
+	job := &model.BulkJobM{
+		UserUUID:   userUUID,
+		AddTags:    addTags,
+		RemoveTags: removeTags,
+		Visibility: r.Visibility,
+	}
+	if err := i.bulkSelection(ctx, userUUID, r, job); err != nil {
+		return nil, err
+	}
+	if err := i.db.BulkJob().Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create bulk job: %w", err)
+	}
+	notifyBulkQueue()
+	return bulkJobInfo(job), nil
+}
+
+// GetBulkJob 返回 userUUID 创建的批量修改任务的进度，其他用户的任务视为不存在.
+func (i *imageBiz) GetBulkJob(ctx context.Context, userUUID string, id uint) (*api.BulkJobInfo, error) {
+	job, err := i.db.BulkJob().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrBulkJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get bulk job: %w", err)
+	}
+	if job.UserUUID != userUUID {
+		return nil, errno.ErrBulkJobNotFound
+	}
+	return bulkJobInfo(job), nil
+}
+
+// nextBulkChunk 返回 job 在 LastImageUUID 之后的下一批图片.
+func nextBulkChunk(ctx context.Context, db store.IStore, job *model.BulkJobM) ([]string, error) {
+	if job.Query == "" {
+		start, found := slices.BinarySearch(job.ImageUUIDs, job.LastImageUUID)
+		if found {
+			start++
+		}
+		return job.ImageUUIDs[start:min(start+bulkChunkSize, len(job.ImageUUIDs))], nil
+	}
+	q, err := parseQuery(job.Query)
+	if err != nil {
+		return nil, err
+	}
+	return db.Image().ListOwnUUIDsMatching(ctx, job.UserUUID, q, job.LastImageUUID, bulkChunkSize)
+}
+
+// RunBulkJob 从 job 保存的进度处开始，按 imageUUID 顺序分批修改选中的图片. 每批在一个事务中完成，
+// 处理后保存进度并将租约延长 lease. 按搜索语句选择的图片在执行时匹配，已处理的图片不会因修改后仍匹配而被重复处理.
+func RunBulkJob(ctx context.Context, db store.IStore, job *model.BulkJobM, lease time.Duration) error {
+	edit := &store.BulkImageEdit{
+		AddTags:    job.AddTags,
+		RemoveTags: job.RemoveTags,
+		Visibility: job.Visibility,
+		MaxTags:    maxImageTags,
+	}
+	for {
+		chunk, err := nextBulkChunk(ctx, db, job)
+		if err != nil {
+			return err
+		}
+		if len(chunk) == 0 {
+			return nil
+		}
+		edited, err := db.Image().BulkEdit(ctx, job.UserUUID, chunk, edit)
+		if err != nil {
+			return err
+		}
+		job.Skipped += int64(len(chunk) - len(edited))
+		job.LastImageUUID = chunk[len(chunk)-1]
+		job.Processed += int64(len(chunk))
+		job.NextRunAt = time.Now().Add(lease)
+		if err := db.BulkJob().Update(ctx, job); err != nil {
+			return err
+		}
+	}
+}
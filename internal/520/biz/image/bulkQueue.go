@@ -0,0 +1,148 @@
+package image
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/model"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	// bulkMaxAttempts 是批量修改任务最多尝试的次数.
+	bulkMaxAttempts = 5
+	// bulkRetryBackoff 是批量修改任务失败后重试前的等待时间.
+	bulkRetryBackoff = time.Minute
+)
+
+var (
+	bulkQueueOnce sync.Once
+	bulkJobQueue  *bulkQueue
+)
+
+// BulkQueue 在后台执行批量修改图片的任务. 任务持久化在 bulk_jobs 表中，多个节点可以同时运行.
+type BulkQueue interface {
+	// Start 启动 worker，重复调用无效.
+	Start()
+	// Stop 通知 worker 退出并等待正在执行的任务结束.
+	Stop()
+	// Notify 唤醒空闲的 worker 立即检查新任务.
+	Notify()
+}
+
+type bulkQueue struct {
+	db           store.IStore
+	pollInterval time.Duration
+	lease        time.Duration
+
+	notify    chan struct{}
+	startOnce sync.Once
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+var _ BulkQueue = (*bulkQueue)(nil)
+
+// NewBulkQueue 返回全局的批量修改任务队列，轮询间隔和租约与图片转换队列共用配置.
+func NewBulkQueue(db store.IStore) BulkQueue {
+	bulkQueueOnce.Do(func() {
+		bulkJobQueue = &bulkQueue{
+			db:           db,
+			pollInterval: viper.GetDuration("ConvertPollInterval"),
+			lease:        viper.GetDuration("ConvertLease"),
+			notify:       make(chan struct{}, 1),
+		}
+		if bulkJobQueue.pollInterval <= 0 {
+			bulkJobQueue.pollInterval = 5 * time.Second
+		}
+		if bulkJobQueue.lease <= 0 {
+			bulkJobQueue.lease = 10 * time.Minute
+		}
+	})
+	return bulkJobQueue
+}
+
+// notifyBulkQueue 在队列已创建时唤醒 worker，队列未创建（例如单元测试中）时什么也不做.
+func notifyBulkQueue() {
+	if bulkJobQueue != nil {
+		bulkJobQueue.Notify()
+	}
+}
+
+func (q *bulkQueue) Start() {
+	q.startOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		q.cancel = cancel
+		q.wg.Add(1)
+		go q.work(ctx)
+		log.Infow("Bulk edit queue started")
+	})
+}
+
+func (q *bulkQueue) Stop() {
+	if q.cancel == nil {
+		return
+	}
+	q.cancel()
+	q.wg.Wait()
+}
+
+func (q *bulkQueue) Notify() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// work 循环领取并执行任务，没有任务时等待通知或轮询间隔.
+func (q *bulkQueue) work(ctx context.Context) {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := q.db.BulkJob().Claim(ctx, q.lease)
+		if err == nil {
+			q.process(ctx, job)
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) && ctx.Err() == nil {
+			log.Errorw("Failed to claim bulk edit job", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// process 执行一个任务并保存结果. 失败的任务从保存的进度处重试，已处理的批次不会重复执行，
+// 重试次数用尽时标记为 failed.
+func (q *bulkQueue) process(ctx context.Context, job *model.BulkJobM) {
+	err := RunBulkJob(ctx, q.db, job, q.lease)
+	// 即使 worker 正在退出也要保存结果，否则任务要等租约过期才能被重新领取
+	ctx = context.WithoutCancel(ctx)
+	switch {
+	case err == nil:
+		job.Status = model.BulkJobDone
+		job.LastError = ""
+	case job.Attempts >= bulkMaxAttempts:
+		job.Status = model.BulkJobFailed
+		job.LastError = truncateError(err)
+		log.Errorw("Bulk edit job failed", "id", job.ID, "attempts", job.Attempts, "err", err)
+	default:
+		job.Status = model.BulkJobPending
+		job.LastError = truncateError(err)
+		job.NextRunAt = time.Now().Add(bulkRetryBackoff)
+		log.Warnw("Bulk edit job will be retried", "id", job.ID, "attempts", job.Attempts, "err", err)
+	}
+	if err := q.db.BulkJob().Update(ctx, job); err != nil {
+		log.Errorw("Failed to update bulk edit job", "id", job.ID, "err", err)
+	}
+}
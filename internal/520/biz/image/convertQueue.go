@@ -0,0 +1,179 @@
+package image
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/convert"
+	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/model"
+	"errors"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// convertFormats 是上传后需要异步生成的图片格式.
+var convertFormats = []string{convert.FormatWebP, convert.FormatAVIF}
+
+var (
+	convertQueueOnce sync.Once
+	queue            *convertQueue
+)
+
+// ConvertQueue 是执行异步图片转换任务的 worker 池. 任务持久化在 convert_jobs 表中，
+// 多个节点可以同时运行各自的 worker 池.
+type ConvertQueue interface {
+	// Start 启动 worker，重复调用无效.
+	Start()
+	// Stop 通知 worker 退出并等待正在执行的任务结束.
+	Stop()
+	// Notify 唤醒一个空闲的 worker 立即检查新任务.
+	Notify()
+}
+
+type convertQueue struct {
+	db             store.IStore
+	imageFileStore ImageFileStore
+	workers        int
+	maxAttempts    int
+	pollInterval   time.Duration
+	lease          time.Duration
+	retryBackoff   time.Duration
+
+	notify    chan struct{}
+	startOnce sync.Once
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+var _ ConvertQueue = (*convertQueue)(nil)
+
+// NewConvertQueue 返回全局的转换任务队列. 配置项未设置时使用 2 个 worker、最多尝试 5 次.
+func NewConvertQueue(db store.IStore) ConvertQueue {
+	convertQueueOnce.Do(func() {
+		queue = &convertQueue{
+			db:             db,
+			imageFileStore: NewImageFileStore(),
+			workers:        viper.GetInt("ConvertWorkers"),
+			maxAttempts:    viper.GetInt("ConvertMaxAttempts"),
+			pollInterval:   viper.GetDuration("ConvertPollInterval"),
+			lease:          viper.GetDuration("ConvertLease"),
+			retryBackoff:   viper.GetDuration("ConvertRetryBackoff"),
+			notify:         make(chan struct{}, 1),
+		}
+		if queue.workers <= 0 {
+			queue.workers = 2
+		}
+		if queue.maxAttempts <= 0 {
+			queue.maxAttempts = 5
+		}
+		if queue.pollInterval <= 0 {
+			queue.pollInterval = 5 * time.Second
+		}
+		if queue.lease <= 0 {
+			queue.lease = 10 * time.Minute
+		}
+		if queue.retryBackoff <= 0 {
+			queue.retryBackoff = 30 * time.Second
+		}
+	})
+	return queue
+}
+
+// notifyConvertQueue 在队列已创建时唤醒 worker，队列未创建（例如单元测试中）时什么也不做.
+func notifyConvertQueue() {
+	if queue != nil {
+		queue.Notify()
+	}
+}
+
+func (q *convertQueue) Start() {
+	q.startOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		q.cancel = cancel
+		for n := 0; n < q.workers; n++ {
+			q.wg.Add(1)
+			go q.work(ctx)
+		}
+		log.Infow("Convert queue started", "workers", q.workers)
+	})
+}
+
+func (q *convertQueue) Stop() {
+	if q.cancel == nil {
+		return
+	}
+	q.cancel()
+	q.wg.Wait()
+}
+
+func (q *convertQueue) Notify() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// work 循环领取并执行任务，没有任务时等待通知或轮询间隔.
+func (q *convertQueue) work(ctx context.Context) {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := q.db.ConvertJob().Claim(ctx, q.lease)
+		if err == nil {
+			q.process(ctx, job)
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) && ctx.Err() == nil {
+			log.Errorw("Failed to claim convert job", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// process 执行一个任务并保存结果. 失败的任务按指数退避重试，原图已不存在或重试次数用尽时标记为 failed.
+func (q *convertQueue) process(ctx context.Context, job *model.ConvertJobM) {
+	size, err := q.imageFileStore.Convert(ctx, job.Hash, job.Format)
+	// 即使 worker 正在退出也要保存结果，否则任务要等租约过期才能被重新领取
+	ctx = context.WithoutCancel(ctx)
+	switch {
+	case err == nil:
+		job.Status = model.ConvertJobReady
+		job.Size = size
+		job.LastError = ""
+	case errors.Is(err, fs.ErrNotExist) || job.Attempts >= q.maxAttempts:
+		job.Status = model.ConvertJobFailed
+		job.LastError = truncateError(err)
+		log.Errorw("Convert job failed", "hash", job.Hash, "format", job.Format, "attempts", job.Attempts, "err", err)
+	default:
+		job.Status = model.ConvertJobPending
+		job.LastError = truncateError(err)
+		backoff := q.retryBackoff
+		for n := 1; n < job.Attempts && backoff < time.Hour; n++ {
+			backoff *= 2
+		}
+		job.NextRunAt = time.Now().Add(backoff)
+		log.Warnw("Convert job will be retried", "hash", job.Hash, "format", job.Format, "attempts", job.Attempts, "err", err)
+	}
+	if err := q.db.ConvertJob().Update(ctx, job); err != nil {
+		log.Errorw("Failed to update convert job", "hash", job.Hash, "format", job.Format, "err", err)
+	}
+}
+
+// truncateError 截断错误信息，使其能够写入 last_error 列.
+func truncateError(err error) string {
+	msg := err.Error()
+	if len(msg) > 512 {
+		msg = msg[:512]
+	}
+	return msg
+}
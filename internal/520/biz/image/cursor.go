@@ -0,0 +1,265 @@
+package image
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+)
+
+// cursorSort 返回 sort 实际使用的排序方式.
+func cursorSort(sort string) string {
+	if sort == "" {
+		return store.ImageSortNewest
+	}
+	return sort
+}
+
+const (
+	// CursorSearch、CursorFavorites、CursorAlbumImages 和 CursorAlbums 分别是搜索结果、收藏、合集中的图片
+	// 和合集列表的游标类型，用户图片列表的游标类型是排序方式.
+	CursorSearch      = "search"
+	CursorFavorites   = "favorites"
+	CursorAlbumImages = "album_images"
+	CursorAlbums      = "albums"
+)
+
+// FormatCursor 将图片或合集在列表中的位置编码为不透明的游标，key 是排序键，uuid 是图片或合集的 UUID.
+// 游标中记录 kind，以免被用于其他列表或排序方式.
+func FormatCursor(kind string, key int64, uuid string) string {
+	raw := kind + ":" + strconv.FormatInt(key, 10) + ":" + uuid
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor 解析 FormatCursor 为 kind 生成的游标，返回排序键和 UUID.
+func ParseCursor(kind, cursor string) (int64, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %v", errno.ErrInvalidCursor, err)
+	}
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 || !govalidator.IsUUID(parts[2]) {
+		return 0, "", errno.ErrInvalidCursor
+	}
+	if parts[0] != kind {
+		return 0, "", fmt.Errorf("%w: cursor was created for %q", errno.ErrInvalidCursor, parts[0])
+	}
+	key, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %v", errno.ErrInvalidCursor, err)
+	}
+	return key, parts[2], nil
+}
+
+// parseTimeCursor 解析排序键为时间的游标，r.Cursor 为空时返回 nil.
+func parseTimeCursor(kind string, r *api.CursorRange) (*store.ImageCursor, error) {
+	if r.Cursor == "" {
+		return nil, nil
+	}
+	key, imageUUID, err := ParseCursor(kind, r.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	return &store.ImageCursor{Time: time.Unix(0, key), ImageUUID: imageUUID}, nil
+}
+
+// NormalizeLimit 校验按 limit 分页的列表每页的数量并填充默认值，各列表的 limit 都在这里校验.
+func NormalizeLimit(limit int) (int, error) {
+	if limit == 0 {
+		return defaultPageSize, nil
+	}
+	if limit < 0 || limit > maxPageSize {
+		return 0, fmt.Errorf("%w: limit must be between 1 and %d", errno.ErrInvalidParameter, maxPageSize)
+	}
+	return limit, nil
+}
+
+// IsCursorPage 判断按页码分页的列表是否改为按游标分页. 过渡期间这些列表只在指定 cursor 或 limit 时按游标分页，
+// 两种分页方式不能同时使用.
+func IsCursorPage(page *api.PageRequest, r *api.CursorRange) (bool, error) {
+	if r.Cursor == "" && r.Limit == 0 {
+		return false, nil
+	}
+	if page.Page != 0 || page.PageSize != 0 {
+		return false, fmt.Errorf("%w: page and cursor cannot be used together", errno.ErrInvalidParameter)
+	}
+	return true, nil
+}
+
+// encodeCursor 将图片在 sort 排序中的位置编码为游标.
+func encodeCursor(sort string, image *model.ImageM) string {
+	var key int64
+	switch sort {
+	case store.ImageSortMostFavorited:
+		key = image.FavoriteCount
+	case store.ImageSortUpdated:
+		key = image.UpdatedAt.UnixNano()
+	default:
+		key = image.CreatedAt.UnixNano()
+	}
+	return FormatCursor(sort, key, image.ImageUUID)
+}
+
+// decodeCursor 解析 encodeCursor 为 sort 排序生成的游标.
+func decodeCursor(sort, cursor string) (*store.ImageCursor, error) {
+	key, imageUUID, err := ParseCursor(sort, cursor)
+	if err != nil {
+		return nil, err
+	}
+	ret := &store.ImageCursor{ImageUUID: imageUUID}
+	if sort == store.ImageSortMostFavorited {
+		ret.FavoriteCount = key
+	} else {
+		ret.Time = time.Unix(0, key)
+	}
+	return ret, nil
+}
+
+// listUserImagesByCursor 按游标分页列出 userUUID 符合过滤条件的图片，publicOnly 为 true 时只列出公开图片.
+func (i *imageBiz) listUserImagesByCursor(ctx context.Context, userUUID string, publicOnly bool, r *api.CursorPageRequest) (*api.ListImageResponse, error) {
+	if !govalidator.IsUUID(userUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	limit, err := NormalizeLimit(r.Limit)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := listOptions(&r.ImageListFilter)
+	if err != nil {
+		return nil, err
+	}
+	sort := cursorSort(r.Sort)
+	var after *store.ImageCursor
+	if r.Cursor != "" {
+		if after, err = decodeCursor(sort, r.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	// 多取一张以判断是否还有下一页
+	images, err := i.db.Image().ListUserImagesAfter(ctx, userUUID, publicOnly, opts, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	ret := &api.ListImageResponse{}
+	if len(images) > limit {
+		images = images[:limit]
+		ret.NextCursor = encodeCursor(sort, images[limit-1])
+	}
+	if ret.ImageList, err = i.buildImageInfos(ctx, images); err != nil {
+		return nil, err
+	}
+	ret.Count = len(images)
+	if r.WithTotal {
+		total, err := i.db.Image().CountUserImages(ctx, userUUID, publicOnly, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count images: %w", err)
+		}
+		ret.Total = &total
+	}
+	return ret, nil
+}
+
+// ListUserOwnImagesByCursor 按游标分页列出 userUUID 自己的全部图片.
+func (i *imageBiz) ListUserOwnImagesByCursor(ctx context.Context, userUUID string, r *api.CursorPageRequest) (*api.ListImageResponse, error) {
+	return i.listUserImagesByCursor(ctx, userUUID, false, r)
+}
+
+// ListUserOwnPublicImagesByCursor 按游标分页列出 userUUID 的公开图片.
+func (i *imageBiz) ListUserOwnPublicImagesByCursor(ctx context.Context, userUUID string, r *api.CursorPageRequest) (*api.ListImageResponse, error) {
+	return i.listUserImagesByCursor(ctx, userUUID, true, r)
+}
+
+// SearchByCursor 与 Search 相同，但按游标分页.
+func (i *imageBiz) SearchByCursor(ctx context.Context, userUUID string, r *api.SearchImagesRequest) (*api.ListImageResponse, error) {
+	if userUUID != "" && !govalidator.IsUUID(userUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	limit, err := NormalizeLimit(r.Limit)
+	if err != nil {
+		return nil, err
+	}
+	after, err := parseTimeCursor(CursorSearch, &r.CursorRange)
+	if err != nil {
+		return nil, err
+	}
+	q, err := parseQuery(r.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	// 多取一张以判断是否还有下一页
+	images, err := i.db.Image().SearchAfter(ctx, userUUID, q, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search images: %w", err)
+	}
+	ret := &api.ListImageResponse{}
+	if len(images) > limit {
+		images = images[:limit]
+		last := images[limit-1]
+		ret.NextCursor = FormatCursor(CursorSearch, last.CreatedAt.UnixNano(), last.ImageUUID)
+	}
+	if ret.ImageList, err = i.buildImageInfos(ctx, images); err != nil {
+		return nil, err
+	}
+	ret.Count = len(images)
+	if r.WithTotal {
+		total, err := i.db.Image().CountSearch(ctx, userUUID, q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count images: %w", err)
+		}
+		ret.Total = &total
+	}
+	return ret, nil
+}
+
+// ListFavoritesByCursor 与 ListFavorites 相同，但按游标分页. 游标记录收藏时间，翻页期间新增的收藏不会让结果错位.
+func (i *imageBiz) ListFavoritesByCursor(ctx context.Context, userUUID string, r *api.CursorRange) (*api.ListImageResponse, error) {
+	if !govalidator.IsUUID(userUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	limit, err := NormalizeLimit(r.Limit)
+	if err != nil {
+		return nil, err
+	}
+	after, err := parseTimeCursor(CursorFavorites, r)
+	if err != nil {
+		return nil, err
+	}
+
+	// 多取一条以判断是否还有下一页
+	favorites, err := i.db.Favorite().ListVisibleAfter(ctx, userUUID, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list favorites: %w", err)
+	}
+	ret := &api.ListImageResponse{}
+	if len(favorites) > limit {
+		favorites = favorites[:limit]
+		last := favorites[limit-1]
+		ret.NextCursor = FormatCursor(CursorFavorites, last.CreatedAt.UnixNano(), last.ImageUUID)
+	}
+	imageUUIDs := make([]string, len(favorites))
+	for idx, favorite := range favorites {
+		imageUUIDs[idx] = favorite.ImageUUID
+	}
+	if ret.ImageList, err = i.ListByUUIDs(ctx, userUUID, imageUUIDs); err != nil {
+		return nil, err
+	}
+	ret.Count = len(ret.ImageList)
+	if r.WithTotal {
+		total, err := i.db.Favorite().CountVisible(ctx, userUUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count favorites: %w", err)
+		}
+		ret.Total = &total
+	}
+	return ret, nil
+}
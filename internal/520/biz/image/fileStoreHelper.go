@@ -5,7 +5,7 @@ import (
 	"io"
 	"mime/multipart"
 	"os"
-	"path/filepath"
+	"path"
 )
 
 func isHexString(s string) bool {
@@ -30,7 +30,7 @@ func genPathDir(hash string) (string, error) {
 	if !isHexString(hash) {
 		return "", fmt.Errorf("invalid hash format")
 	}
-	return filepath.Join(hash[:2], hash[2:]), nil
+	return path.Join(hash[:2], hash[2:]), nil
 }
 
 func saveFile(srcFile multipart.File, hash, fullPath string) error {
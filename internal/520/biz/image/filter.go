@@ -0,0 +1,81 @@
+package image
+
+import (
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxFilterTags 是列表过滤条件中包含和排除的标签数量上限.
+const maxFilterTags = 10
+
+// listOptions 校验图片列表的过滤条件并转换为 store 的查询选项，f 为 nil 时不过滤.
+func listOptions(f *api.ImageListFilter) (*store.ImageListOptions, error) {
+	if f == nil {
+		return nil, nil
+	}
+	if f.Visibility != "" && !model.IsValidVisibility(f.Visibility) {
+		return nil, fmt.Errorf("%w: invalid visibility %q", errno.ErrInvalidParameter, f.Visibility)
+	}
+	if !store.IsValidImageSort(f.Sort) {
+		return nil, fmt.Errorf("%w: invalid sort %q", errno.ErrInvalidParameter, f.Sort)
+	}
+	opts := &store.ImageListOptions{
+		Visibility: f.Visibility,
+		MimeType:   strings.TrimSpace(f.MimeType),
+		Sort:       f.Sort,
+	}
+	var err error
+	if opts.IncludeTags, err = filterTags(f.Tags); err != nil {
+		return nil, err
+	}
+	if opts.ExcludeTags, err = filterTags(f.ExcludeTags); err != nil {
+		return nil, err
+	}
+	if opts.CreatedAfter, err = filterTime("created_after", f.CreatedAfter); err != nil {
+		return nil, err
+	}
+	if opts.CreatedBefore, err = filterTime("created_before", f.CreatedBefore); err != nil {
+		return nil, err
+	}
+	if opts.CreatedAfter != nil && opts.CreatedBefore != nil && !opts.CreatedAfter.Before(*opts.CreatedBefore) {
+		return nil, fmt.Errorf("%w: created_after must be before created_before", errno.ErrInvalidParameter)
+	}
+	return opts, nil
+}
+
+// filterTags 按 NormalizeTag 规范化过滤条件中的标签并去重，与写入时的规范化保持一致.
+func filterTags(tags []string) ([]string, error) {
+	if len(tags) > maxFilterTags {
+		return nil, fmt.Errorf("%w: at most %d tags can be filtered", errno.ErrInvalidParameter, maxFilterTags)
+	}
+	var ret []string
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[tag]; !ok {
+			seen[tag] = struct{}{}
+			ret = append(ret, tag)
+		}
+	}
+	return ret, nil
+}
+
+// filterTime 解析 RFC 3339 格式的时间，value 为空时返回 nil.
+func filterTime(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s: %v", errno.ErrInvalidParameter, name, err)
+	}
+	return &t, nil
+}
@@ -3,13 +3,18 @@ package image
 import (
 	"context"
 	"demo520/internal/520/store"
+	"demo520/internal/pkg/convert"
 	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/log"
 	"demo520/internal/pkg/model"
 	"demo520/pkg/api"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"mime/multipart"
-	"strings"
+	"os"
+	"slices"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/google/uuid"
@@ -21,12 +26,42 @@ import (
 type ImageBiz interface {
 	Create(ctx context.Context, userUUID string, r *api.CreateImageRequest, fileHeader *multipart.FileHeader) (*api.CreateImageResponse, error)
 	UpdateTags(ctx context.Context, userUUID string, imageUUID string, r *api.UpdateImageTagsRequest) error
+	DeleteTags(ctx context.Context, userUUID string, imageUUID string, r *api.UpdateImageTagsRequest) error
+	ReplaceTags(ctx context.Context, userUUID string, imageUUID string, r *api.UpdateImageTagsRequest) error
+	Patch(ctx context.Context, userUUID string, imageUUID string, r *api.PatchImageRequest) (*api.GetImageInfoResponse, error)
 	Delete(ctx context.Context, userUUID string, imageUUID string) error
-	DeleteCollection(ctx context.Context, userUUID string, imageUUIDs []string) error
+	DeleteCollection(ctx context.Context, userUUID string, imageUUIDs []string) (*api.DeleteImagesResponse, error)
+	CreateBulkJob(ctx context.Context, userUUID string, r *api.BulkEditImagesRequest) (*api.BulkJobInfo, error)
+	GetBulkJob(ctx context.Context, userUUID string, id uint) (*api.BulkJobInfo, error)
 	Get(ctx context.Context, userUUID string, imageUUID string) (*api.GetImageInfoResponse, error)
-	ListUserOwnImages(ctx context.Context, userUUID string, offset, limit int) (*api.ListImageResponse, error)
-	ListUserOwnPublicImages(ctx context.Context, userUUID string, offset, limit int) (*api.ListImageResponse, error)
+	ListByUUIDs(ctx context.Context, userUUID string, imageUUIDs []string) ([]api.ImageInfo, error)
+	GetFile(ctx context.Context, userUUID string, imageUUID string, variants []Variant) (*ImageFileInfo, error)
+	GetDerivedFile(ctx context.Context, userUUID string, imageUUID string, t convert.Transform) (*ImageFileInfo, error)
+	ListUserOwnImages(ctx context.Context, userUUID string, offset, limit int, filter *api.ImageListFilter) (*api.ListImageResponse, error)
+	ListUserOwnPublicImages(ctx context.Context, userUUID string, offset, limit int, filter *api.ImageListFilter) (*api.ListImageResponse, error)
+	ListUserOwnImagesByCursor(ctx context.Context, userUUID string, r *api.CursorPageRequest) (*api.ListImageResponse, error)
+	ListUserOwnPublicImagesByCursor(ctx context.Context, userUUID string, r *api.CursorPageRequest) (*api.ListImageResponse, error)
 	ListRandomPublicImages(ctx context.Context, limit int) (*api.ListImageResponse, error)
+	Search(ctx context.Context, userUUID string, r *api.SearchImagesRequest) (*api.SearchImagesResponse, error)
+	SearchByCursor(ctx context.Context, userUUID string, r *api.SearchImagesRequest) (*api.ListImageResponse, error)
+	AddFavorite(ctx context.Context, userUUID string, imageUUID string) error
+	RemoveFavorite(ctx context.Context, userUUID string, imageUUID string) error
+	ListFavorites(ctx context.Context, userUUID string, r *api.PageRequest) (*api.ListFavoritesResponse, error)
+	ListFavoritesByCursor(ctx context.Context, userUUID string, r *api.CursorRange) (*api.ListImageResponse, error)
+	Similar(ctx context.Context, userUUID string, imageUUID string, r *api.SimilarImagesRequest) (*api.SimilarImagesResponse, error)
+	CreateShare(ctx context.Context, userUUID string, imageUUID string, r *api.CreateShareRequest) (*api.CreateShareResponse, error)
+	GetShare(ctx context.Context, userUUID string, imageUUID string) (*api.GetShareResponse, error)
+	RevokeShare(ctx context.Context, userUUID string, imageUUID string) error
+	GetShared(ctx context.Context, token string, client ShareClient) (*api.GetImageInfoResponse, error)
+	GetSharedFile(ctx context.Context, token string, variants []Variant, client ShareClient) (*ImageFileInfo, error)
+}
+
+// ImageFileInfo 是 GetFile 返回的图片文件及其缓存相关的属性. IsPublic 表示图片不是私有图片，文件可以被共享缓存.
+type ImageFileInfo struct {
+	*ImageFile
+	Variant  Variant
+	ETag     string
+	IsPublic bool
 }
 
 type imageBiz struct {
@@ -47,10 +82,27 @@ func copyImageInfo(info *api.ImageInfo, imageM *model.ImageM) error {
 	if err := copier.Copy(info, imageM); err != nil {
 		return fmt.Errorf("failed to copy image data: %w", err)
 	}
+	// 分享令牌只通过分享接口返回给图片所有者
+	info.Token = ""
+	info.IsPublic = imageM.Visibility == model.VisibilityPublic
+	info.Metadata = imageM.Metadata
+	if imageM.CameraMake != "" || imageM.CameraModel != "" || imageM.LensModel != "" ||
+		imageM.CapturedAt != nil || imageM.Orientation > 1 {
+		info.Exif = &api.ExifInfo{
+			CameraMake:  imageM.CameraMake,
+			CameraModel: imageM.CameraModel,
+			LensModel:   imageM.LensModel,
+			Orientation: imageM.Orientation,
+		}
+		if imageM.CapturedAt != nil {
+			info.Exif.CapturedAt = imageM.CapturedAt.Format(time.RFC3339)
+		}
+	}
 	if len(imageM.Tags) > 0 {
 		info.Tags = make([]string, len(imageM.Tags))
 		for i, t := range imageM.Tags {
-			info.Tags[i] = t.Tag
+			info.Tags[i] = t.FullTag()
+			info.TagGroups.Add(t.Namespace, t.Tag)
 		}
 	}
 	if !imageM.CreatedAt.IsZero() {
@@ -62,6 +114,72 @@ func copyImageInfo(info *api.ImageInfo, imageM *model.ImageM) error {
 	return nil
 }
 
+// imageHashes 返回 images 的 hash 列表.
+func imageHashes(images []*model.ImageM) []string {
+	hashes := make([]string, len(images))
+	for i, image := range images {
+		hashes[i] = image.Hash
+	}
+	return hashes
+}
+
+// formatStatus 查询 hashes 对应的转换任务，返回 hash 到各格式状态的映射，以及 hash 到已生成格式字节数的映射.
+func (i *imageBiz) formatStatus(ctx context.Context, hashes ...string) (map[string]map[string]string, map[string]map[string]int64, error) {
+	jobs, err := i.db.ConvertJob().ListByHashes(ctx, hashes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list convert jobs: %w", err)
+	}
+	statuses := make(map[string]map[string]string)
+	sizes := make(map[string]map[string]int64)
+	for _, job := range jobs {
+		if statuses[job.Hash] == nil {
+			statuses[job.Hash] = make(map[string]string)
+		}
+		switch job.Status {
+		case model.ConvertJobReady:
+			statuses[job.Hash][job.Format] = api.FormatStatusReady
+			// 转换队列记录大小之前完成的任务由 backfill 补齐
+			if job.Size > 0 {
+				if sizes[job.Hash] == nil {
+					sizes[job.Hash] = make(map[string]int64)
+				}
+				sizes[job.Hash][job.Format] = job.Size
+			}
+		case model.ConvertJobFailed:
+			statuses[job.Hash][job.Format] = api.FormatStatusFailed
+		default:
+			statuses[job.Hash][job.Format] = api.FormatStatusProcessing
+		}
+	}
+	return statuses, sizes, nil
+}
+
+// buildImageInfos 将 images 转换为 api.ImageInfo，并填充各格式的转换状态、字节数和收藏数.
+func (i *imageBiz) buildImageInfos(ctx context.Context, images []*model.ImageM) ([]api.ImageInfo, error) {
+	statuses, sizes, err := i.formatStatus(ctx, imageHashes(images)...)
+	if err != nil {
+		return nil, err
+	}
+	imageUUIDs := make([]string, len(images))
+	for idx, image := range images {
+		imageUUIDs[idx] = image.ImageUUID
+	}
+	favoriteCounts, err := i.db.Favorite().CountByImages(ctx, imageUUIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count favorites: %w", err)
+	}
+	infos := make([]api.ImageInfo, len(images))
+	for idx, image := range images {
+		if err := copyImageInfo(&infos[idx], image); err != nil {
+			return nil, err
+		}
+		infos[idx].FormatStatus = statuses[image.Hash]
+		infos[idx].VariantSizes = sizes[image.Hash]
+		infos[idx].FavoriteCount = favoriteCounts[image.ImageUUID]
+	}
+	return infos, nil
+}
+
 func (i *imageBiz) Create(ctx context.Context, userUUID string, r *api.CreateImageRequest, fileHeader *multipart.FileHeader) (*api.CreateImageResponse, error) {
 	if fileHeader == nil {
 		return nil, fmt.Errorf("%w: file header", errno.ErrInvalidParameter)
@@ -70,6 +188,28 @@ func (i *imageBiz) Create(ctx context.Context, userUUID string, r *api.CreateIma
 		return nil, fmt.Errorf("%w: request", errno.ErrInvalidParameter)
 	}
 
+	visibility, err := createVisibility(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDescription(r.Description); err != nil {
+		return nil, err
+	}
+	if err := validateMetadata(r.Metadata); err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(r.Tags)
+	if err != nil {
+		return nil, err
+	}
+	// 别名改写为规范标签，并补齐被蕴含的标签
+	if tags, err = i.db.TagRule().Expand(ctx, tags); err != nil {
+		return nil, fmt.Errorf("failed to apply tag rules: %w", err)
+	}
+	if len(tags) > maxImageTags {
+		return nil, fmt.Errorf("%w: at most %d tags", errno.ErrTooManyTags, maxImageTags)
+	}
+
 	imageMaxSize := viper.GetInt64("ImageMaxSize")
 	if fileHeader.Size > imageMaxSize {
 		return nil, errno.ErrImageFileTooLarge
@@ -81,72 +221,105 @@ func (i *imageBiz) Create(ctx context.Context, userUUID string, r *api.CreateIma
 		return nil, errno.ErrImageFileInvalid
 	}
 
-	hash, err := i.imageFileStore.Hash(fileHeader)
+	// 旋转为正向并去除 GPS 信息后再计算 hash，保存的原图不再包含拍摄位置
+	prepared, err := i.imageFileStore.Prepare(ctx, fileHeader, r.KeepGPS)
 	if err != nil {
-		return nil, fmt.Errorf("failed to calculate image hash: %w", err)
+		return nil, fmt.Errorf("failed to prepare image: %w", err)
 	}
+	hash := prepared.Hash
 
 	imageUUID := uuid.New().String()
-	if err := i.imageFileStore.Save(fileHeader, hash); err != nil {
+	// 先登记 blob 再检查和写入文件，与垃圾回收互斥，防止文件在写入图片记录前被回收
+	if err := i.db.Blob().Touch(ctx, hash); err != nil {
+		return nil, fmt.Errorf("failed to register image blob: %w", err)
+	}
+	props, err := i.imageFileStore.Save(ctx, prepared)
+	if err != nil {
 		return nil, fmt.Errorf("failed to save image file: %w", err)
 	}
+	// WebP/AVIF 由转换队列异步生成，上传请求只保存原图
+	if err := i.db.ConvertJob().Enqueue(ctx, hash, convertFormats); err != nil {
+		return nil, fmt.Errorf("failed to enqueue convert jobs: %w", err)
+	}
+	notifyConvertQueue()
 	var imageTags []model.ImageTagM
-	for _, tag := range r.Tags {
-		imageTags = append(imageTags, model.ImageTagM{
-			Tag:       tag,
-			ImageUUID: imageUUID,
-		})
+	for _, tag := range tags {
+		imageTags = append(imageTags, model.NewImageTag(imageUUID, tag))
 	}
 	imageM := model.ImageM{
-		ImageUUID: imageUUID,
-		Hash:      hash,
-		Token:     "",
-		UserUUID:  r.UserUUID,
-		IsPublic:  r.IsPublic,
-		Tags:      imageTags,
-	}
+		ImageUUID:   imageUUID,
+		Hash:        hash,
+		Token:       "",
+		UserUUID:    r.UserUUID,
+		Visibility:  visibility,
+		Description: r.Description,
+		Metadata:    r.Metadata,
+		Width:       props.Width,
+		Height:      props.Height,
+		MimeType:    props.MimeType,
+		Size:        props.Size,
+		CameraMake:  truncateRunes(prepared.EXIF.Make, 64),
+		CameraModel: truncateRunes(prepared.EXIF.Model, 64),
+		LensModel:   truncateRunes(prepared.EXIF.LensModel, 128),
+		CapturedAt:  prepared.EXIF.CapturedAt,
+		Orientation: prepared.EXIF.Orientation,
+		PHash:       &props.PHash,
+		Tags:        imageTags,
+	}
+	// 在写入新图片之前查找，结果中不会包含新图片本身
+	nearDuplicates := i.findNearDuplicates(ctx, r.UserUUID, props.PHash)
 	if err := i.db.Image().Create(ctx, &imageM); err != nil {
 		return nil, fmt.Errorf("failed to create image record: %w", err)
 	}
 
-	var ret api.CreateImageResponse
-	if err := copyImageInfo((*api.ImageInfo)(&ret), &imageM); err != nil {
-		return nil, fmt.Errorf("failed to copy image data: %w", err)
+	infos, err := i.buildImageInfos(ctx, []*model.ImageM{&imageM})
+	if err != nil {
+		return nil, err
 	}
-	return &ret, nil
+	return &api.CreateImageResponse{ImageInfo: infos[0], NearDuplicates: nearDuplicates}, nil
 }
 
-func (i *imageBiz) UpdateTags(ctx context.Context, userUUID string, imageUUID string, r *api.UpdateImageTagsRequest) error {
+// Patch 修改图片的描述和元数据，只有图片所有者可以修改，返回修改后的图片信息.
+func (i *imageBiz) Patch(ctx context.Context, userUUID string, imageUUID string, r *api.PatchImageRequest) (*api.GetImageInfoResponse, error) {
 	if !govalidator.IsUUID(imageUUID) {
-		return fmt.Errorf("%w: invalid image UUID", errno.ErrInvalidParameter)
+		return nil, fmt.Errorf("%w: invalid image UUID", errno.ErrInvalidParameter)
 	}
 	if !govalidator.IsUUID(userUUID) {
-		return fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
-	}
-	if len(r.Tags) == 0 {
-		return fmt.Errorf("%w: empty tags", errno.ErrInvalidParameter)
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
 	}
-	imageM, getImageErr := i.db.Image().Get(ctx, imageUUID)
-	if getImageErr != nil {
-		return getImageErr
+	updates := make(map[string]interface{})
+	if r.Visibility != nil {
+		if !model.IsValidVisibility(*r.Visibility) {
+			return nil, fmt.Errorf("%w: invalid visibility %q", errno.ErrInvalidParameter, *r.Visibility)
+		}
+		updates["visibility"] = *r.Visibility
 	}
-	if imageM.UserUUID != userUUID {
-		return fmt.Errorf("%w: unauthorized operation", errno.ErrUnauthorized)
+	if r.Description != nil {
+		if err := validateDescription(*r.Description); err != nil {
+			return nil, err
+		}
+		updates["description"] = *r.Description
 	}
-	tagSet := make(map[string]struct{})
-	var uniqueTags []string
-	for _, tag := range r.Tags {
-		normalized := strings.TrimSpace(tag)
-		if _, ok := tagSet[normalized]; !ok {
-			tagSet[tag] = struct{}{}
-			uniqueTags = append(uniqueTags, normalized)
+	if len(r.Metadata) > 0 {
+		var metadata model.JSONMap
+		if err := json.Unmarshal(r.Metadata, &metadata); err != nil {
+			return nil, fmt.Errorf("%w: metadata must be an object or null", errno.ErrImageMetadataInvalid)
 		}
+		if err := validateMetadata(metadata); err != nil {
+			return nil, err
+		}
+		updates["metadata"] = metadata
 	}
-	addTagsErr := i.db.Image().AddTagsToImage(ctx, imageUUID, uniqueTags)
-	if addTagsErr != nil {
-		return addTagsErr
+
+	if _, err := i.getOwnedImage(ctx, userUUID, imageUUID); err != nil {
+		return nil, err
 	}
-	return nil
+	if len(updates) > 0 {
+		if err := i.db.Image().Update(ctx, imageUUID, updates); err != nil {
+			return nil, fmt.Errorf("failed to update image: %w", err)
+		}
+	}
+	return i.Get(ctx, userUUID, imageUUID)
 }
 
 func (i *imageBiz) Delete(ctx context.Context, userUUID string, imageUUID string) error {
@@ -171,16 +344,58 @@ func (i *imageBiz) Delete(ctx context.Context, userUUID string, imageUUID string
 	return nil
 }
 
-func (i *imageBiz) DeleteCollection(ctx context.Context, userUUID string, imageUUIDs []string) error {
-	//TODO implement me
-	panic("implement me")
+// maxDeleteCollectionSize 是一次批量删除允许的最大图片数.
+const maxDeleteCollectionSize = 1000
+
+// DeleteCollection 在一个事务中删除 imageUUIDs 中属于 userUUID 的图片，并按请求顺序返回每张图片的处理结果.
+func (i *imageBiz) DeleteCollection(ctx context.Context, userUUID string, imageUUIDs []string) (*api.DeleteImagesResponse, error) {
+	if !govalidator.IsUUID(userUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	if len(imageUUIDs) == 0 {
+		return nil, fmt.Errorf("%w: empty image list", errno.ErrInvalidParameter)
+	}
+	if len(imageUUIDs) > maxDeleteCollectionSize {
+		return nil, fmt.Errorf("%w: at most %d images per request", errno.ErrInvalidParameter, maxDeleteCollectionSize)
+	}
+	// 去重并保持请求中的顺序
+	seen := make(map[string]struct{}, len(imageUUIDs))
+	uniqueUUIDs := make([]string, 0, len(imageUUIDs))
+	for _, imageUUID := range imageUUIDs {
+		if !govalidator.IsUUID(imageUUID) {
+			return nil, fmt.Errorf("%w: invalid image UUID %q", errno.ErrInvalidParameter, imageUUID)
+		}
+		if _, ok := seen[imageUUID]; ok {
+			continue
+		}
+		seen[imageUUID] = struct{}{}
+		uniqueUUIDs = append(uniqueUUIDs, imageUUID)
+	}
+
+	owners, err := i.db.Image().DeleteCollection(ctx, userUUID, uniqueUUIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete images: %w", err)
+	}
+	ret := &api.DeleteImagesResponse{Results: make([]api.DeleteImageResult, len(uniqueUUIDs))}
+	for idx, imageUUID := range uniqueUUIDs {
+		result := api.DeleteResultDeleted
+		if owner, ok := owners[imageUUID]; !ok {
+			result = api.DeleteResultNotFound
+		} else if owner != userUUID {
+			result = api.DeleteResultForbidden
+		}
+		ret.Results[idx] = api.DeleteImageResult{ImageUUID: imageUUID, Result: result}
+	}
+	return ret, nil
 }
 
-func (i *imageBiz) Get(ctx context.Context, userUUID string, imageUUID string) (*api.GetImageInfoResponse, error) {
+// getVisibleImage 读取图片记录并校验 userUUID 是否有权查看该图片.
+func (i *imageBiz) getVisibleImage(ctx context.Context, userUUID string, imageUUID string) (*model.ImageM, error) {
 	if !govalidator.IsUUID(imageUUID) {
 		return nil, fmt.Errorf("%w: invalid image UUID", errno.ErrInvalidParameter)
 	}
-	if !govalidator.IsUUID(userUUID) {
+	// userUUID 为空表示匿名访问，只允许读取公开图片
+	if userUUID != "" && !govalidator.IsUUID(userUUID) {
 		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
 	}
 	imageM, getImageErr := i.db.Image().Get(ctx, imageUUID)
@@ -190,69 +405,192 @@ func (i *imageBiz) Get(ctx context.Context, userUUID string, imageUUID string) (
 		}
 		return nil, getImageErr
 	}
-	if !imageM.IsPublic && imageM.UserUUID != userUUID {
+	// 不公开列出的图片持有链接即可访问
+	if imageM.Visibility == model.VisibilityPrivate && imageM.UserUUID != userUUID {
+		return nil, fmt.Errorf("%w: unauthorized operation", errno.ErrUnauthorized)
+	}
+	return imageM, nil
+}
+
+// createVisibility 返回上传请求指定的可见性，未指定 Visibility 时按旧版本的 IsPublic 决定.
+func createVisibility(r *api.CreateImageRequest) (string, error) {
+	if r.Visibility == "" {
+		if r.IsPublic {
+			return model.VisibilityPublic, nil
+		}
+		return model.VisibilityPrivate, nil
+	}
+	if !model.IsValidVisibility(r.Visibility) {
+		return "", fmt.Errorf("%w: invalid visibility %q", errno.ErrInvalidParameter, r.Visibility)
+	}
+	return r.Visibility, nil
+}
+
+// getOwnedImage 读取图片记录并校验 userUUID 是否为图片的所有者.
+func (i *imageBiz) getOwnedImage(ctx context.Context, userUUID string, imageUUID string) (*model.ImageM, error) {
+	if !govalidator.IsUUID(userUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	imageM, err := i.getVisibleImage(ctx, userUUID, imageUUID)
+	if err != nil {
+		return nil, err
+	}
+	if imageM.UserUUID != userUUID {
 		return nil, fmt.Errorf("%w: unauthorized operation", errno.ErrUnauthorized)
 	}
-	var ret api.GetImageInfoResponse
-	if err := copyImageInfo((*api.ImageInfo)(&ret), imageM); err != nil {
-		return nil, fmt.Errorf("failed to copy image data: %w", err)
+	return imageM, nil
+}
+
+// ListByUUIDs 按 imageUUIDs 的顺序返回其中 userUUID 可见的图片，不存在或不可见的图片被跳过.
+func (i *imageBiz) ListByUUIDs(ctx context.Context, userUUID string, imageUUIDs []string) ([]api.ImageInfo, error) {
+	images, err := i.db.Image().ListVisibleByUUIDs(ctx, userUUID, imageUUIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	order := make(map[string]int, len(imageUUIDs))
+	for idx, imageUUID := range imageUUIDs {
+		if _, ok := order[imageUUID]; !ok {
+			order[imageUUID] = idx
+		}
+	}
+	slices.SortFunc(images, func(a, b *model.ImageM) int { return order[a.ImageUUID] - order[b.ImageUUID] })
+	return i.buildImageInfos(ctx, images)
+}
+
+func (i *imageBiz) Get(ctx context.Context, userUUID string, imageUUID string) (*api.GetImageInfoResponse, error) {
+	imageM, err := i.getVisibleImage(ctx, userUUID, imageUUID)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := i.buildImageInfos(ctx, []*model.ImageM{imageM})
+	if err != nil {
+		return nil, err
 	}
+	ret := api.GetImageInfoResponse(infos[0])
 	return &ret, nil
 }
 
-func (i *imageBiz) ListUserOwnImages(ctx context.Context, userUUID string, offset, limit int) (*api.ListImageResponse, error) {
+// GetFile 按 variants 的顺序查找第一个已存储的图片版本并打开它，调用方负责关闭返回的文件.
+func (i *imageBiz) GetFile(ctx context.Context, userUUID string, imageUUID string, variants []Variant) (*ImageFileInfo, error) {
+	imageM, err := i.getVisibleImage(ctx, userUUID, imageUUID)
+	if err != nil {
+		return nil, err
+	}
+	return i.openVariant(ctx, imageM, variants)
+}
+
+// openVariant 按 variants 的优先级打开图片第一个已存储的版本，调用方负责关闭返回的文件.
+func (i *imageBiz) openVariant(ctx context.Context, imageM *model.ImageM, variants []Variant) (*ImageFileInfo, error) {
+	for _, variant := range variants {
+		file, err := i.imageFileStore.Open(ctx, imageM.Hash, variant)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to open image file: %w", err)
+		}
+		return &ImageFileInfo{
+			ImageFile: file,
+			Variant:   variant,
+			ETag:      fmt.Sprintf(`"%s-%s"`, imageM.Hash, variant),
+			IsPublic:  imageM.Visibility != model.VisibilityPrivate,
+		}, nil
+	}
+	log.C(ctx).Warnw("No stored variant found for image", "imageUUID", imageM.ImageUUID, "hash", imageM.Hash)
+	return nil, fmt.Errorf("%w: image=%s", errno.ErrImageFileNotFound, imageM.ImageUUID)
+}
+
+// defaultThumbnailSizes 是未配置 ThumbnailSizes 时允许的缩略图边长.
+var defaultThumbnailSizes = []int{160, 320, 640, 1280}
+
+// isAllowedThumbnailSize 检查 size 是否在允许的缩略图边长列表中.
+func isAllowedThumbnailSize(size int) bool {
+	sizes := viper.GetIntSlice("ThumbnailSizes")
+	if len(sizes) == 0 {
+		sizes = defaultThumbnailSizes
+	}
+	return slices.Contains(sizes, size)
+}
+
+// GetDerivedFile 返回按 t 缩放后的图片，只允许生成配置中列出的尺寸，调用方负责关闭返回的文件.
+func (i *imageBiz) GetDerivedFile(ctx context.Context, userUUID string, imageUUID string, t convert.Transform) (*ImageFileInfo, error) {
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errno.ErrInvalidParameter, err)
+	}
+	if !isAllowedThumbnailSize(t.Width) || (t.Height != 0 && !isAllowedThumbnailSize(t.Height)) {
+		return nil, fmt.Errorf("%w: %dx%d", errno.ErrImageSizeNotAllowed, t.Width, t.Height)
+	}
+	imageM, err := i.getVisibleImage(ctx, userUUID, imageUUID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := i.imageFileStore.OpenDerived(ctx, imageM.Hash, t)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: image=%s", errno.ErrImageFileNotFound, imageUUID)
+		}
+		return nil, fmt.Errorf("failed to open derived image file: %w", err)
+	}
+	return &ImageFileInfo{
+		ImageFile: file,
+		Variant:   Variant(t.Format),
+		ETag:      fmt.Sprintf(`"%s-%s"`, imageM.Hash, t.Key()),
+		IsPublic:  imageM.Visibility != model.VisibilityPrivate,
+	}, nil
+}
+
+// ListUserOwnImages 按 offset 分页列出 userUUID 符合 filter 的图片，filter 为 nil 时按创建时间倒序列出全部图片.
+func (i *imageBiz) ListUserOwnImages(ctx context.Context, userUUID string, offset, limit int, filter *api.ImageListFilter) (*api.ListImageResponse, error) {
 	if offset < 0 {
 		return nil, fmt.Errorf("%w: invalid offset", errno.ErrInvalidParameter)
 	}
-	if limit < 0 {
-		return nil, fmt.Errorf("%w: invalid limit", errno.ErrInvalidParameter)
+	limit, err := NormalizeLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := listOptions(filter)
+	if err != nil {
+		return nil, err
 	}
-	count, imageList, getImageErr := i.db.Image().GetUserImages(ctx, userUUID, offset, limit)
+	// 超出最后一页时 imageList 为空，count 仍是符合条件的图片总数
+	count, imageList, getImageErr := i.db.Image().ListUserImages(ctx, userUUID, false, opts, offset, limit)
 	if getImageErr != nil {
 		return nil, getImageErr
 	}
-	if count > 0 && imageList[0].UserUUID != userUUID {
-		return nil, fmt.Errorf("%w: unauthorized operation", errno.ErrUnauthorized)
-	}
-	if count == 0 {
-		return &api.ListImageResponse{}, nil
+	imageInfos, err := i.buildImageInfos(ctx, imageList)
+	if err != nil {
+		return nil, err
 	}
 	var ret api.ListImageResponse
 	ret.Count = int(count)
-	imageInfos := make([]api.ImageInfo, len(imageList))
-	for i, image := range imageList {
-		if err := copyImageInfo(&imageInfos[i], image); err != nil {
-			return nil, err
-		}
-	}
 	ret.ImageList = imageInfos
 	return &ret, nil
 }
 
-func (i *imageBiz) ListUserOwnPublicImages(ctx context.Context, userUUID string, offset, limit int) (*api.ListImageResponse, error) {
+// ListUserOwnPublicImages 按 offset 分页列出 userUUID 符合 filter 的公开图片.
+func (i *imageBiz) ListUserOwnPublicImages(ctx context.Context, userUUID string, offset, limit int, filter *api.ImageListFilter) (*api.ListImageResponse, error) {
 	if offset < 0 {
 		return nil, fmt.Errorf("%w: invalid offset", errno.ErrInvalidParameter)
 	}
-	if limit < 0 {
-		return nil, fmt.Errorf("%w: invalid limit", errno.ErrInvalidParameter)
+	limit, err := NormalizeLimit(limit)
+	if err != nil {
+		return nil, err
 	}
-	count, imageList, getImageErr := i.db.Image().GetUserImages(ctx, userUUID, offset, limit)
+	opts, err := listOptions(filter)
+	if err != nil {
+		return nil, err
+	}
+	// 不公开列出的图片同样不出现在用户的公开列表中
+	count, imageList, getImageErr := i.db.Image().ListUserImages(ctx, userUUID, true, opts, offset, limit)
 	if getImageErr != nil {
 		return nil, getImageErr
 	}
+	imageInfos, err := i.buildImageInfos(ctx, imageList)
+	if err != nil {
+		return nil, err
+	}
 	var ret api.ListImageResponse
 	ret.Count = int(count)
-	imageInfos := make([]api.ImageInfo, 0)
-	for _, image := range imageList {
-		if !image.IsPublic {
-			continue
-		}
-		var temp_image api.ImageInfo
-		if err := copyImageInfo(&temp_image, image); err != nil {
-			return nil, err
-		}
-		imageInfos = append(imageInfos, temp_image)
-	}
 	ret.ImageList = imageInfos
 	return &ret, nil
 }
@@ -265,14 +603,117 @@ func (i *imageBiz) ListRandomPublicImages(ctx context.Context, limit int) (*api.
 	if getImageErr != nil {
 		return nil, getImageErr
 	}
+	imageInfos, err := i.buildImageInfos(ctx, imageList)
+	if err != nil {
+		return nil, err
+	}
 	var ret api.ListImageResponse
 	ret.Count = int(count)
-	imageInfos := make([]api.ImageInfo, len(imageList))
-	for i, image := range imageList {
-		if err := copyImageInfo(&imageInfos[i], image); err != nil {
-			return nil, err
-		}
-	}
 	ret.ImageList = imageInfos
 	return &ret, nil
 }
+
+const (
+	// defaultPageSize 是分页查询未指定 page_size 时每页的图片数.
+	defaultPageSize = 20
+	// maxPageSize 是分页查询允许的最大 page_size.
+	maxPageSize = 100
+)
+
+// NormalizePage 校验分页参数并填充默认值，返回页码、每页数量和对应的 offset.
+func NormalizePage(r *api.PageRequest) (page, pageSize, offset int, err error) {
+	page, pageSize = r.Page, r.PageSize
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	if page < 0 || pageSize < 0 || pageSize > maxPageSize {
+		return 0, 0, 0, fmt.Errorf("%w: invalid page or page_size", errno.ErrInvalidParameter)
+	}
+	return page, pageSize, (page - 1) * pageSize, nil
+}
+
+// Search 按标签搜索公开图片和 userUUID 自己的图片，userUUID 为空时只搜索公开图片.
+func (i *imageBiz) Search(ctx context.Context, userUUID string, r *api.SearchImagesRequest) (*api.SearchImagesResponse, error) {
+	if userUUID != "" && !govalidator.IsUUID(userUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	page, pageSize, offset, err := NormalizePage(&r.PageRequest)
+	if err != nil {
+		return nil, err
+	}
+	q, err := parseQuery(r.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	total, imageList, err := i.db.Image().Search(ctx, userUUID, q, offset, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search images: %w", err)
+	}
+	imageInfos, err := i.buildImageInfos(ctx, imageList)
+	if err != nil {
+		return nil, err
+	}
+	return &api.SearchImagesResponse{
+		Total:     total,
+		Page:      page,
+		PageSize:  pageSize,
+		ImageList: imageInfos,
+	}, nil
+}
+
+// AddFavorite 收藏 userUUID 可见的图片，重复收藏不会报错.
+func (i *imageBiz) AddFavorite(ctx context.Context, userUUID string, imageUUID string) error {
+	if !govalidator.IsUUID(userUUID) {
+		return fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	if _, err := i.getVisibleImage(ctx, userUUID, imageUUID); err != nil {
+		return err
+	}
+	if err := i.db.Favorite().Add(ctx, userUUID, imageUUID); err != nil {
+		return fmt.Errorf("failed to add favorite: %w", err)
+	}
+	return nil
+}
+
+// RemoveFavorite 取消收藏. 图片被删除或设为私有后仍然可以取消收藏.
+func (i *imageBiz) RemoveFavorite(ctx context.Context, userUUID string, imageUUID string) error {
+	if !govalidator.IsUUID(imageUUID) {
+		return fmt.Errorf("%w: invalid image UUID", errno.ErrInvalidParameter)
+	}
+	if !govalidator.IsUUID(userUUID) {
+		return fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	if err := i.db.Favorite().Remove(ctx, userUUID, imageUUID); err != nil {
+		return fmt.Errorf("failed to remove favorite: %w", err)
+	}
+	return nil
+}
+
+// ListFavorites 按收藏时间倒序列出 userUUID 收藏且当前仍可见的图片.
+func (i *imageBiz) ListFavorites(ctx context.Context, userUUID string, r *api.PageRequest) (*api.ListFavoritesResponse, error) {
+	if !govalidator.IsUUID(userUUID) {
+		return nil, fmt.Errorf("%w: invalid user UUID", errno.ErrInvalidParameter)
+	}
+	page, pageSize, offset, err := NormalizePage(r)
+	if err != nil {
+		return nil, err
+	}
+	total, imageList, err := i.db.Favorite().ListVisibleImages(ctx, userUUID, offset, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list favorites: %w", err)
+	}
+	imageInfos, err := i.buildImageInfos(ctx, imageList)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ListFavoritesResponse{
+		Total:     total,
+		Page:      page,
+		PageSize:  pageSize,
+		ImageList: imageInfos,
+	}, nil
+}
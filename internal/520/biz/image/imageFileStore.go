@@ -1,93 +1,300 @@
 package image
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"demo520/internal/pkg/convert"
-	"demo520/internal/pkg/helper"
 	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/storage"
+	"errors"
 	"fmt"
 	"github.com/gabriel-vasile/mimetype"
-	"github.com/spf13/viper"
 	"io"
+	"io/fs"
 	"mime/multipart"
-	"os"
-	"path/filepath"
+	"path"
+	"strings"
 	"sync"
+	"time"
 )
 
+// derivedDirName 是 hash 目录下存放缩略图缓存的子目录名.
+const derivedDirName = "derived"
+
 var (
 	imageFileStoreOnce sync.Once
 	this               *imageFileStore
 )
 
+// Variant 表示同一张图片在存储后端中的不同编码版本.
+type Variant string
+
+const (
+	// VariantOriginal 表示用户上传的原图.
+	VariantOriginal Variant = "original"
+	// VariantWebP 表示转换生成的 WebP 版本.
+	VariantWebP Variant = "webp"
+	// VariantAVIF 表示转换生成的 AVIF 版本.
+	VariantAVIF Variant = "avif"
+)
+
+// ImageFile 是一个已打开的图片文件，调用方负责 Close.
+type ImageFile struct {
+	io.ReadSeekCloser
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// PreparedImage 是经过方向校正和隐私信息处理、准备保存的原图，Hash 是 Data 的 SHA-256，
+// Ext 是按 Data 的实际格式得到的扩展名.
+type PreparedImage struct {
+	Data []byte
+	Ext  string
+	Hash string
+	EXIF *convert.EXIF
+}
+
 type ImageFileStore interface {
-	Save(fileHeader *multipart.FileHeader, hash string) error
+	Prepare(ctx context.Context, fileHeader *multipart.FileHeader, keepGPS bool) (*PreparedImage, error)
+	Save(ctx context.Context, image *PreparedImage) (*convert.Properties, error)
+	Convert(ctx context.Context, hash string, format string) (int64, error)
+	Probe(ctx context.Context, hash string) (*convert.Properties, error)
+	Open(ctx context.Context, hash string, variant Variant) (*ImageFile, error)
+	OpenDerived(ctx context.Context, hash string, t convert.Transform) (*ImageFile, error)
 	Validate(fileHeader *multipart.FileHeader) (bool, error)
-	IsContainerImage(hash string) (bool, error)
-	Remove(fileHeader *multipart.FileHeader) error
-	Hash(fileHeader *multipart.FileHeader) (string, error)
+	IsContainerImage(ctx context.Context, hash string) (bool, error)
+	Remove(ctx context.Context, hash string) error
+	Usage(ctx context.Context, hash string) (int64, error)
 }
 
 type imageFileStore struct {
-	baseDir        string
+	backend        storage.Backend
 	imageConverter convert.ImageConverter
 }
 
 var _ ImageFileStore = (*imageFileStore)(nil)
 
+// NewImageFileStore 返回使用全局存储后端的 ImageFileStore.
 func NewImageFileStore() ImageFileStore {
 	imageFileStoreOnce.Do(func() {
-		this = &imageFileStore{baseDir: viper.GetString("image_dir"), imageConverter: convert.InitImageConverter()}
+		this = &imageFileStore{backend: storage.Default(), imageConverter: convert.InitImageConverter()}
 	})
 	return this
 }
 
-func (i *imageFileStore) Save(fileHeader *multipart.FileHeader, hash string) error {
-	// 参数校验
+// NewImageFileStoreWithBackend 返回使用指定存储后端的 ImageFileStore.
+func NewImageFileStoreWithBackend(backend storage.Backend) ImageFileStore {
+	return &imageFileStore{backend: backend, imageConverter: convert.InitImageConverter()}
+}
+
+// hashPrefix 返回 hash 对应对象 key 的公共前缀，以 '/' 结尾.
+func (i *imageFileStore) hashPrefix(hash string) (string, error) {
+	pathDir, err := genPathDir(hash)
+	if err != nil {
+		return "", fmt.Errorf("generate pathDir failed: %w", err)
+	}
+	return pathDir + "/", nil
+}
+
+// Prepare 读取上传的文件，解析 EXIF 信息，将图片旋转为正向，keepGPS 为 false 时去除 GPS 信息，
+// 并按处理后的内容计算 hash. 相同的上传和选项总是得到相同的 hash.
+func (i *imageFileStore) Prepare(ctx context.Context, fileHeader *multipart.FileHeader, keepGPS bool) (*PreparedImage, error) {
 	if fileHeader == nil {
-		return fmt.Errorf("fileHeader cannot be nil")
+		return nil, fmt.Errorf("fileHeader cannot be nil")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open uploaded file failed: %w", err)
+	}
+	defer file.Close()
+	buf, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("read uploaded file failed: %w", err)
+	}
+
+	data, exif, err := i.imageConverter.Sanitize(ctx, buf, keepGPS)
+	if err != nil {
+		return nil, fmt.Errorf("sanitize uploaded file failed: %w", err)
+	}
+	// 扩展名按内容检测，不信任上传的文件名：以 .webp/.avif 命名的其他格式图片会被当作转换结果
+	return &PreparedImage{
+		Data: data,
+		Ext:  mimetype.Detect(data).Extension(),
+		Hash: fmt.Sprintf("%x", sha256.Sum256(data)),
+		EXIF: exif,
+	}, nil
+}
+
+// Save 保存处理后的原图并返回其固有属性，原图已经存在时只读取属性.
+func (i *imageFileStore) Save(ctx context.Context, image *PreparedImage) (*convert.Properties, error) {
+	// 参数校验
+	if image == nil {
+		return nil, fmt.Errorf("image cannot be nil")
 	}
+	hash := image.Hash
 	if hash == "" {
-		return fmt.Errorf("hash cannot be empty")
+		return nil, fmt.Errorf("hash cannot be empty")
 	}
 	// 检查是否已存在
-	exists, err := i.IsContainerImage(hash)
+	exists, err := i.IsContainerImage(ctx, hash)
 	if err != nil {
-		return fmt.Errorf("check image existence failed: %w", err)
+		return nil, fmt.Errorf("check image existence failed: %w", err)
 	}
 	if exists {
-		return nil
+		return i.Probe(ctx, hash)
 	}
-	// 生成存储路径
-	pathDir, err := genPathDir(hash)
+	prefix, err := i.hashPrefix(hash)
 	if err != nil {
-		return fmt.Errorf("generate pathDir failed: %w", err)
+		return nil, err
 	}
-	// 创建目录（更严格的权限）
-	fullDirPath := filepath.Join(i.baseDir, pathDir)
-	if err := os.MkdirAll(fullDirPath, 0750); err != nil { // 750更安全
-		return fmt.Errorf("create directory %s failed: %w", fullDirPath, err)
+
+	// 创建目标对象（使用更安全的文件名）
+	key := prefix + hash + image.Ext
+	if err := i.backend.Put(ctx, key, bytes.NewReader(image.Data), int64(len(image.Data))); err != nil {
+		return nil, fmt.Errorf("save uploaded file failed: %w", err)
+	}
+	props, err := i.imageConverter.Probe(ctx, i.backend, key)
+	if err != nil {
+		return nil, fmt.Errorf("probe uploaded file failed: %w", err)
 	}
+	return props, nil
+}
 
-	// 打开源文件
-	srcFile, err := fileHeader.Open()
+// Convert 从 hash 对应的原图生成 format 格式的版本并返回其字节数，原图不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist).
+func (i *imageFileStore) Convert(ctx context.Context, hash string, format string) (int64, error) {
+	prefix, err := i.hashPrefix(hash)
+	if err != nil {
+		return 0, err
+	}
+	key, err := i.originalKey(ctx, prefix, hash)
 	if err != nil {
-		return fmt.Errorf("open uploaded file failed: %w", err)
+		return 0, err
 	}
-	defer srcFile.Close()
-	// 创建目标文件（使用更安全的文件名）
-	fileName := fmt.Sprintf("%s%s", hash, filepath.Ext(fileHeader.Filename))
-	filePath := filepath.Join(fullDirPath, fileName)
-	saveSrcFileErr := helper.WriteFile(filePath, srcFile)
-	if saveSrcFileErr != nil {
-		return saveSrcFileErr
+	size, err := i.imageConverter.ConvertImage(ctx, i.backend, key, format)
+	if err != nil {
+		log.C(ctx).Errorw("Convert image file failed", "key", key, "format", format, "err", err)
+		return 0, err
 	}
-	convertErr := i.imageConverter.ConvertImage(filePath)
-	if convertErr != nil {
-		log.Errorw("Convert image file failed", "filePath", filePath, "err", convertErr)
-		return convertErr
+	return size, nil
+}
+
+// Probe 读取 hash 对应原图的宽高、MIME 类型和字节数，原图不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist).
+func (i *imageFileStore) Probe(ctx context.Context, hash string) (*convert.Properties, error) {
+	prefix, err := i.hashPrefix(hash)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	key, err := i.originalKey(ctx, prefix, hash)
+	if err != nil {
+		return nil, err
+	}
+	return i.imageConverter.Probe(ctx, i.backend, key)
+}
+
+// Open 打开 hash 对应图片的指定版本，文件不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist).
+func (i *imageFileStore) Open(ctx context.Context, hash string, variant Variant) (*ImageFile, error) {
+	prefix, err := i.hashPrefix(hash)
+	if err != nil {
+		return nil, err
+	}
+	var key string
+	switch variant {
+	case VariantWebP, VariantAVIF:
+		key = prefix + hash + "." + string(variant)
+	case VariantOriginal:
+		if key, err = i.originalKey(ctx, prefix, hash); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported image variant: %s", variant)
+	}
+
+	return i.openImageFile(ctx, key)
+}
+
+// OpenDerived 打开 hash 对应图片按 t 变换后的缩略图. 缩略图缓存在 hash 前缀下的 derived 子目录中，
+// 文件名由变换参数决定；缓存不存在时从原图生成并写入缓存.
+func (i *imageFileStore) OpenDerived(ctx context.Context, hash string, t convert.Transform) (*ImageFile, error) {
+	prefix, err := i.hashPrefix(hash)
+	if err != nil {
+		return nil, err
+	}
+	key := prefix + derivedDirName + "/" + t.Key()
+	file, err := i.openImageFile(ctx, key)
+	if err == nil {
+		return file, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	srcKey, err := i.originalKey(ctx, prefix, hash)
+	if err != nil {
+		return nil, err
+	}
+	data, err := i.imageConverter.Thumbnail(ctx, i.backend, srcKey, t)
+	if err != nil {
+		log.C(ctx).Errorw("Generate thumbnail failed", "key", srcKey, "transform", t.Key(), "err", err)
+		return nil, err
+	}
+	if err := i.backend.Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, err
+	}
+	return i.openImageFile(ctx, key)
+}
+
+// openImageFile 打开 key 对应的对象并探测其 MIME 类型.
+func (i *imageFileStore) openImageFile(ctx context.Context, key string) (*ImageFile, error) {
+	file, info, err := i.backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	mtype, err := mimetype.DetectReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &ImageFile{
+		ReadSeekCloser: file,
+		ContentType:    mtype.String(),
+		Size:           info.Size,
+		ModTime:        info.ModTime,
+	}, nil
+}
+
+// originalKey 在 hash 前缀下查找原图. 原图以检测到的格式的扩展名保存，
+// 除 .webp/.avif 转换结果以外的对象即为原图；若找不到则说明原图本身就是 WebP 或 AVIF.
+func (i *imageFileStore) originalKey(ctx context.Context, prefix, hash string) (string, error) {
+	objects, err := i.backend.List(ctx, prefix+hash)
+	if err != nil {
+		return "", err
+	}
+	var fallback string
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj.Key, prefix)
+		// 跳过 derived 子目录中的缩略图
+		if strings.Contains(name, "/") {
+			continue
+		}
+		switch path.Ext(name) {
+		case ".webp", ".avif":
+			if fallback == "" || path.Ext(name) == ".webp" {
+				fallback = obj.Key
+			}
+		default:
+			return obj.Key, nil
+		}
+	}
+	if fallback == "" {
+		return "", fmt.Errorf("original of %s: %w", hash, fs.ErrNotExist)
+	}
+	return fallback, nil
 }
 
 func (i *imageFileStore) Validate(fileHeader *multipart.FileHeader) (bool, error) {
@@ -111,40 +318,55 @@ func (i *imageFileStore) Validate(fileHeader *multipart.FileHeader) (bool, error
 	return isImage, nil
 }
 
-func (i *imageFileStore) IsContainerImage(hash string) (bool, error) {
+// IsContainerImage 判断 hash 对应的原图是否已经保存.
+func (i *imageFileStore) IsContainerImage(ctx context.Context, hash string) (bool, error) {
 	if hash == "" {
 		return false, fmt.Errorf("hash cannot be empty")
 	}
-	fileDir, genFileDirErr := genPathDir(hash)
-	if genFileDirErr != nil {
-		return false, fmt.Errorf("generate pathDir failed: %w", genFileDirErr)
-	}
-	fileInfo, err := os.Stat(fileDir)
+	prefix, err := i.hashPrefix(hash)
 	if err != nil {
-		if os.IsNotExist(err) {
+		return false, err
+	}
+	if _, err := i.originalKey(ctx, prefix, hash); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
 			return false, nil
 		}
 		return false, err
 	}
-	return fileInfo.IsDir(), nil
+	return true, nil
 }
 
-func (i *imageFileStore) Remove(fileHeader *multipart.FileHeader) error {
-	//TODO implement me
+// Remove 删除 hash 对应的全部文件，包括原图、WebP、AVIF 以及缩略图缓存.
+func (i *imageFileStore) Remove(ctx context.Context, hash string) error {
+	prefix, err := i.hashPrefix(hash)
+	if err != nil {
+		return err
+	}
+	objects, err := i.backend.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := i.backend.Delete(ctx, obj.Key); err != nil {
+			return fmt.Errorf("remove %s failed: %w", obj.Key, err)
+		}
+	}
 	return nil
 }
 
-func (i *imageFileStore) Hash(fileHeader *multipart.FileHeader) (string, error) {
-	file, err := fileHeader.Open()
+// Usage 返回 hash 对应的全部文件占用的字节数.
+func (i *imageFileStore) Usage(ctx context.Context, hash string) (int64, error) {
+	prefix, err := i.hashPrefix(hash)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
-	defer file.Close()
-
-	hasher := sha256.New()
-	if _, err := io.Copy(hasher, file); err != nil {
-		return "", fmt.Errorf("failed to calculate hash: %w", err)
+	objects, err := i.backend.List(ctx, prefix)
+	if err != nil {
+		return 0, err
 	}
-
-	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+	var total int64
+	for _, obj := range objects {
+		total += obj.Size
+	}
+	return total, nil
 }
@@ -0,0 +1,105 @@
+package image
+
+import (
+	"demo520/internal/pkg/errno"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxDescriptionLength 是图片描述允许的最大字符数，与 images.description 列的长度一致.
+	maxDescriptionLength = 500
+	// maxMetadataBytes 是元数据序列化为 JSON 后允许的最大字节数.
+	maxMetadataBytes = 16 << 10
+	// maxMetadataDepth 是元数据中对象和数组允许的最大嵌套层数.
+	maxMetadataDepth = 8
+	// maxMetadataKeys 是元数据中每个对象允许的最大键数.
+	maxMetadataKeys = 64
+	// maxMetadataKeyLength 是元数据中键允许的最大字符数.
+	maxMetadataKeyLength = 64
+	// maxMetadataArrayLength 是元数据中每个数组允许的最大元素数.
+	maxMetadataArrayLength = 256
+	// maxMetadataStringLength 是元数据中字符串值允许的最大字符数.
+	maxMetadataStringLength = 4096
+)
+
+// validateDescription 校验图片描述的长度.
+func validateDescription(description string) error {
+	if !utf8.ValidString(description) {
+		return fmt.Errorf("%w: description is not valid UTF-8", errno.ErrImageDescriptionInvalid)
+	}
+	if n := utf8.RuneCountInString(description); n > maxDescriptionLength {
+		return fmt.Errorf("%w: description has %d characters, at most %d", errno.ErrImageDescriptionInvalid, n, maxDescriptionLength)
+	}
+	return nil
+}
+
+// validateMetadata 校验元数据的总大小、嵌套层数以及每个对象、数组和字符串的大小.
+func validateMetadata(metadata map[string]interface{}) error {
+	if metadata == nil {
+		return nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errno.ErrImageMetadataInvalid, err)
+	}
+	if len(data) > maxMetadataBytes {
+		return fmt.Errorf("%w: metadata is %d bytes, at most %d", errno.ErrImageMetadataInvalid, len(data), maxMetadataBytes)
+	}
+	if err := validateMetadataValue("metadata", metadata, 1); err != nil {
+		return fmt.Errorf("%w: %v", errno.ErrImageMetadataInvalid, err)
+	}
+	return nil
+}
+
+// validateMetadataValue 递归校验 path 处的值，depth 是当前对象或数组的嵌套层数.
+func validateMetadataValue(path string, value interface{}, depth int) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if depth > maxMetadataDepth {
+			return fmt.Errorf("%s nests deeper than %d levels", path, maxMetadataDepth)
+		}
+		if len(v) > maxMetadataKeys {
+			return fmt.Errorf("%s has %d keys, at most %d", path, len(v), maxMetadataKeys)
+		}
+		for key, child := range v {
+			if key == "" || utf8.RuneCountInString(key) > maxMetadataKeyLength {
+				return fmt.Errorf("%s has a key with invalid length: %q", path, key)
+			}
+			if err := validateMetadataValue(path+"."+key, child, depth+1); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if depth > maxMetadataDepth {
+			return fmt.Errorf("%s nests deeper than %d levels", path, maxMetadataDepth)
+		}
+		if len(v) > maxMetadataArrayLength {
+			return fmt.Errorf("%s has %d elements, at most %d", path, len(v), maxMetadataArrayLength)
+		}
+		for idx, child := range v {
+			if err := validateMetadataValue(fmt.Sprintf("%s[%d]", path, idx), child, depth+1); err != nil {
+				return err
+			}
+		}
+	case string:
+		if utf8.RuneCountInString(v) > maxMetadataStringLength {
+			return fmt.Errorf("%s is longer than %d characters", path, maxMetadataStringLength)
+		}
+	case nil, bool, float64, json.Number:
+	default:
+		return fmt.Errorf("%s has unsupported type %T", path, value)
+	}
+	return nil
+}
+
+// truncateRunes 去除 s 中的非法 UTF-8 字节并截断到至多 n 个字符，用于保存来自 EXIF 等外部来源的字符串.
+func truncateRunes(s string, n int) string {
+	s = strings.ToValidUTF8(s, "")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
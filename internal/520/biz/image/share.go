@@ -0,0 +1,184 @@
+package image
+
+import (
+	"context"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxShareExpiresIn 是分享链接允许的最长有效期.
+	maxShareExpiresIn = 365 * 24 * time.Hour
+	// maxShareViews 是分享链接允许设置的最大访问次数.
+	maxShareViews = 1000000
+	// maxUserAgentLength 是访问记录中保存的 User-Agent 的最大字符数，与 share_accesses.user_agent 列的长度一致.
+	maxUserAgentLength = 255
+)
+
+// ShareClient 是通过分享链接访问图片的客户端信息，用于记录访问日志.
+// Continuation 为 true 表示请求只读取文件开头以外的部分，是同一次查看中的后续分段读取.
+type ShareClient struct {
+	IP           string
+	UserAgent    string
+	Continuation bool
+}
+
+// shareURL 返回分享令牌对应的访问路径.
+func shareURL(token string) string {
+	return "/s/" + token
+}
+
+func buildShareInfo(imageM *model.ImageM) api.ShareInfo {
+	info := api.ShareInfo{
+		ImageUUID: imageM.ImageUUID,
+		Token:     imageM.Token,
+		URL:       shareURL(imageM.Token),
+		MaxViews:  imageM.ShareMaxViews,
+		Views:     imageM.ShareViews,
+	}
+	if imageM.ShareExpiresAt != nil {
+		info.ExpiresAt = imageM.ShareExpiresAt.Format(time.RFC3339)
+	}
+	return info
+}
+
+// CreateShare 为图片生成新的分享令牌，原有的分享链接随之失效. 只有图片所有者可以分享图片.
+func (i *imageBiz) CreateShare(ctx context.Context, userUUID string, imageUUID string, r *api.CreateShareRequest) (*api.CreateShareResponse, error) {
+	// 按秒比较，过大的 expires_in 转换为 time.Duration 时会溢出
+	if r.ExpiresIn < 0 || r.ExpiresIn > int64(maxShareExpiresIn/time.Second) {
+		return nil, fmt.Errorf("%w: expires_in must be between 0 and %d seconds", errno.ErrInvalidParameter, int64(maxShareExpiresIn/time.Second))
+	}
+	if r.MaxViews < 0 || r.MaxViews > maxShareViews {
+		return nil, fmt.Errorf("%w: max_views must be between 0 and %d", errno.ErrInvalidParameter, maxShareViews)
+	}
+	imageM, err := i.getOwnedImage(ctx, userUUID, imageUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if r.ExpiresIn > 0 {
+		t := time.Now().Add(time.Duration(r.ExpiresIn) * time.Second).Truncate(time.Second)
+		expiresAt = &t
+	}
+	token := uuid.New().String()
+	if err := i.db.Share().Set(ctx, imageUUID, token, expiresAt, r.MaxViews); err != nil {
+		return nil, fmt.Errorf("failed to create share link: %w", err)
+	}
+	log.C(ctx).Infow("Share link created", "imageUUID", imageUUID, "expiresAt", expiresAt, "maxViews", r.MaxViews)
+
+	imageM.Token, imageM.ShareExpiresAt, imageM.ShareMaxViews, imageM.ShareViews = token, expiresAt, r.MaxViews, 0
+	ret := api.CreateShareResponse(buildShareInfo(imageM))
+	return &ret, nil
+}
+
+// GetShare 返回图片当前的分享链接及其访问次数，图片未被分享时返回 errno.ErrShareLinkNotFound.
+func (i *imageBiz) GetShare(ctx context.Context, userUUID string, imageUUID string) (*api.GetShareResponse, error) {
+	imageM, err := i.getOwnedImage(ctx, userUUID, imageUUID)
+	if err != nil {
+		return nil, err
+	}
+	if imageM.Token == "" {
+		return nil, fmt.Errorf("%w: image=%s", errno.ErrShareLinkNotFound, imageUUID)
+	}
+	ret := api.GetShareResponse(buildShareInfo(imageM))
+	return &ret, nil
+}
+
+// RevokeShare 撤销图片的分享链接，图片未被分享时不会报错.
+func (i *imageBiz) RevokeShare(ctx context.Context, userUUID string, imageUUID string) error {
+	if _, err := i.getOwnedImage(ctx, userUUID, imageUUID); err != nil {
+		return err
+	}
+	if err := i.db.Share().Revoke(ctx, imageUUID); err != nil {
+		return fmt.Errorf("failed to revoke share link: %w", err)
+	}
+	log.C(ctx).Infow("Share link revoked", "imageUUID", imageUUID)
+	return nil
+}
+
+// getSharedImage 返回分享令牌对应的图片，令牌不存在或已撤销时返回 errno.ErrShareLinkNotFound.
+func (i *imageBiz) getSharedImage(ctx context.Context, token string) (*model.ImageM, error) {
+	if !govalidator.IsUUID(token) {
+		return nil, errno.ErrShareLinkNotFound
+	}
+	imageM, err := i.db.Share().GetImage(ctx, token)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrShareLinkNotFound
+		}
+		return nil, fmt.Errorf("failed to get shared image: %w", err)
+	}
+	return imageM, nil
+}
+
+// logShareAccess 记录一次通过分享链接的访问. 写入访问记录失败只记录日志，不影响访问.
+func (i *imageBiz) logShareAccess(ctx context.Context, imageM *model.ImageM, action string, client ShareClient) {
+	log.C(ctx).Infow("Share link accessed", "imageUUID", imageM.ImageUUID, "action", action,
+		"ip", client.IP, "userAgent", client.UserAgent)
+	access := &model.ShareAccessM{
+		ImageUUID: imageM.ImageUUID,
+		Token:     imageM.Token,
+		Action:    action,
+		ClientIP:  client.IP,
+		UserAgent: truncateRunes(client.UserAgent, maxUserAgentLength),
+	}
+	if err := i.db.Share().LogAccess(ctx, access); err != nil {
+		log.C(ctx).Warnw("Failed to log share access", "imageUUID", imageM.ImageUUID, "err", err)
+	}
+}
+
+// GetShared 返回分享链接对应的图片信息，无需登录. 读取图片信息不消耗访问次数.
+func (i *imageBiz) GetShared(ctx context.Context, token string, client ShareClient) (*api.GetImageInfoResponse, error) {
+	imageM, err := i.getSharedImage(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	expired := imageM.ShareExpiresAt != nil && !time.Now().Before(*imageM.ShareExpiresAt)
+	if expired || (imageM.ShareMaxViews > 0 && imageM.ShareViews >= imageM.ShareMaxViews) {
+		log.C(ctx).Infow("Expired share link accessed", "imageUUID", imageM.ImageUUID, "ip", client.IP)
+		return nil, errno.ErrShareLinkExpired
+	}
+	infos, err := i.buildImageInfos(ctx, []*model.ImageM{imageM})
+	if err != nil {
+		return nil, err
+	}
+	i.logShareAccess(ctx, imageM, model.ShareAccessInfo, client)
+	ret := api.GetImageInfoResponse(infos[0])
+	return &ret, nil
+}
+
+// GetSharedFile 返回分享链接对应的图片文件，无需登录. 每次读取消耗一次访问次数，调用方负责关闭返回的文件.
+// 分段读取时只有包含文件开头的请求计入访问次数，后续分段只要链接未过期、已被查看过即可读取.
+func (i *imageBiz) GetSharedFile(ctx context.Context, token string, variants []Variant, client ShareClient) (*ImageFileInfo, error) {
+	imageM, err := i.getSharedImage(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	if client.Continuation {
+		expired := imageM.ShareExpiresAt != nil && !time.Now().Before(*imageM.ShareExpiresAt)
+		ok = !expired && (imageM.ShareMaxViews == 0 || imageM.ShareViews > 0)
+	} else if ok, err = i.db.Share().ConsumeView(ctx, token, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to count share view: %w", err)
+	}
+	if !ok {
+		log.C(ctx).Infow("Expired share link accessed", "imageUUID", imageM.ImageUUID, "ip", client.IP)
+		return nil, errno.ErrShareLinkExpired
+	}
+	file, err := i.openVariant(ctx, imageM, variants)
+	if err != nil {
+		return nil, err
+	}
+	i.logShareAccess(ctx, imageM, model.ShareAccessFile, client)
+	return file, nil
+}
@@ -0,0 +1,99 @@
+package image
+
+import (
+	"context"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"fmt"
+	"math/bits"
+	"slices"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// defaultSimilarDistance 是查询近似图片未指定 distance 时允许的最大汉明距离.
+	defaultSimilarDistance = 10
+	// maxSimilarDistance 是查询近似图片允许的最大 distance，更大的距离基本不再相似.
+	maxSimilarDistance = 24
+	// defaultSimilarLimit 是查询近似图片未指定 limit 时返回的图片数.
+	defaultSimilarLimit = 20
+	// maxSimilarLimit 是查询近似图片允许的最大 limit.
+	maxSimilarLimit = 100
+	// defaultNearDuplicateDistance 是上传时提示近似重复图片的默认汉明距离.
+	defaultNearDuplicateDistance = 6
+	// maxNearDuplicates 是上传时最多提示的近似重复图片数.
+	maxNearDuplicates = 10
+)
+
+// nearDuplicateDistance 返回上传时提示近似重复图片的汉明距离，配置为负数时关闭提示.
+func nearDuplicateDistance() int {
+	if !viper.IsSet("NearDuplicateDistance") {
+		return defaultNearDuplicateDistance
+	}
+	return min(viper.GetInt("NearDuplicateDistance"), maxSimilarDistance)
+}
+
+// findNearDuplicates 返回 userUUID 已上传的、与 phash 近似的图片 UUID. 查询失败只记录日志，不影响上传.
+func (i *imageBiz) findNearDuplicates(ctx context.Context, userUUID string, phash uint64) []string {
+	distance := nearDuplicateDistance()
+	if distance < 0 {
+		return nil
+	}
+	images, err := i.db.Image().ListOwnSimilar(ctx, userUUID, phash, distance, maxNearDuplicates)
+	if err != nil {
+		log.C(ctx).Warnw("Failed to find near duplicate images", "user", userUUID, "err", err)
+		return nil
+	}
+	uuids := make([]string, len(images))
+	for idx, image := range images {
+		uuids[idx] = image.ImageUUID
+	}
+	return uuids
+}
+
+// Similar 列出与 imageUUID 近似且 userUUID 可见的图片，按汉明距离从近到远排序，不包括 imageUUID 本身.
+func (i *imageBiz) Similar(ctx context.Context, userUUID string, imageUUID string, r *api.SimilarImagesRequest) (*api.SimilarImagesResponse, error) {
+	distance, limit := defaultSimilarDistance, r.Limit
+	if r.Distance != nil {
+		distance = *r.Distance
+	}
+	if limit == 0 {
+		limit = defaultSimilarLimit
+	}
+	if distance < 0 || distance > maxSimilarDistance || limit < 0 || limit > maxSimilarLimit {
+		return nil, fmt.Errorf("%w: invalid distance or limit", errno.ErrInvalidParameter)
+	}
+	imageM, err := i.getVisibleImage(ctx, userUUID, imageUUID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &api.SimilarImagesResponse{ImageList: []api.SimilarImage{}}
+	// 差异哈希尚未由 backfill 补齐
+	if imageM.PHash == nil {
+		return resp, nil
+	}
+
+	// 多取一张以便排除图片本身
+	images, err := i.db.Image().ListSimilar(ctx, userUUID, *imageM.PHash, distance, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list similar images: %w", err)
+	}
+	images = slices.DeleteFunc(images, func(image *model.ImageM) bool { return image.ImageUUID == imageUUID })
+	if len(images) > limit {
+		images = images[:limit]
+	}
+	infos, err := i.buildImageInfos(ctx, images)
+	if err != nil {
+		return nil, err
+	}
+	for idx, image := range images {
+		resp.ImageList = append(resp.ImageList, api.SimilarImage{
+			ImageInfo: infos[idx],
+			Distance:  bits.OnesCount64(*image.PHash ^ *imageM.PHash),
+		})
+	}
+	return resp, nil
+}
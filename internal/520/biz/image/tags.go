@@ -0,0 +1,136 @@
+package image
+
+import (
+	"context"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/internal/pkg/tagquery"
+	"demo520/pkg/api"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// defaultTagMaxLength 是未配置 TagMaxLength 时标签的最大字节数.
+	defaultTagMaxLength = 64
+	// maxImageTags 是一张图片最多带有的标签数.
+	maxImageTags = 100
+)
+
+// tagNormalizeOptions 读取标签规范化的配置，TagLowercase 默认开启.
+func tagNormalizeOptions() tagquery.NormalizeOptions {
+	opts := tagquery.NormalizeOptions{Lowercase: true, MaxLength: defaultTagMaxLength}
+	if viper.IsSet("TagLowercase") {
+		opts.Lowercase = viper.GetBool("TagLowercase")
+	}
+	if viper.IsSet("TagMaxLength") {
+		opts.MaxLength = viper.GetInt("TagMaxLength")
+	}
+	return opts
+}
+
+// NormalizeTag 按配置规范化单个标签. namespace:name 形式的标签只规范化名称部分，命名空间统一为小写.
+func NormalizeTag(tag string) (string, error) {
+	namespace, name := model.SplitTag(strings.TrimSpace(tag))
+	normalized, err := tagquery.Normalize(name, tagNormalizeOptions())
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errno.ErrInvalidTag, err)
+	}
+	return model.JoinTag(namespace, normalized), nil
+}
+
+// normalizeTags 规范化并去重 tags，保持首次出现的顺序.
+func normalizeTags(tags []string) ([]string, error) {
+	ret := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		normalized, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[normalized]; !ok {
+			seen[normalized] = struct{}{}
+			ret = append(ret, normalized)
+		}
+	}
+	if len(ret) > maxImageTags {
+		return nil, fmt.Errorf("%w: at most %d tags", errno.ErrTooManyTags, maxImageTags)
+	}
+	return ret, nil
+}
+
+// parseQuery 解析搜索语句，并按 NormalizeTag 规范化其中的标签，使搜索与写入时的规范化一致.
+// 前缀匹配只规范化 * 之前的部分，artist:* 这样只有命名空间的前缀保持不变.
+func parseQuery(s string) (*tagquery.Query, error) {
+	q, err := tagquery.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errno.ErrInvalidSearchQuery, err)
+	}
+	normalize := func(terms []tagquery.Term) error {
+		for i, term := range terms {
+			if _, name := model.SplitTag(term.Tag); term.Prefix && name == "" {
+				continue
+			}
+			tag, err := NormalizeTag(term.Tag)
+			if err != nil {
+				return fmt.Errorf("%w: %v", errno.ErrInvalidSearchQuery, err)
+			}
+			terms[i].Tag = tag
+		}
+		return nil
+	}
+	for _, group := range q.Include {
+		if err := normalize(group); err != nil {
+			return nil, err
+		}
+	}
+	if err := normalize(q.Exclude); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// UpdateTags 为 userUUID 自己的图片追加标签，已有的标签保持不变. 追加并按规则展开后图片的标签数
+// 不能超过 maxImageTags.
+func (i *imageBiz) UpdateTags(ctx context.Context, userUUID string, imageUUID string, r *api.UpdateImageTagsRequest) error {
+	if len(r.Tags) == 0 {
+		return fmt.Errorf("%w: empty tags", errno.ErrInvalidParameter)
+	}
+	tags, err := normalizeTags(r.Tags)
+	if err != nil {
+		return err
+	}
+	if _, err := i.getOwnedImage(ctx, userUUID, imageUUID); err != nil {
+		return err
+	}
+	return i.db.Image().AddTagsToImage(ctx, imageUUID, tags, maxImageTags)
+}
+
+// DeleteTags 从 userUUID 自己的图片上删除标签，图片没有的标签被忽略.
+func (i *imageBiz) DeleteTags(ctx context.Context, userUUID string, imageUUID string, r *api.UpdateImageTagsRequest) error {
+	if len(r.Tags) == 0 {
+		return fmt.Errorf("%w: empty tags", errno.ErrInvalidParameter)
+	}
+	tags, err := normalizeTags(r.Tags)
+	if err != nil {
+		return err
+	}
+	if _, err := i.getOwnedImage(ctx, userUUID, imageUUID); err != nil {
+		return err
+	}
+	return i.db.Image().DeleteTagFromImage(ctx, imageUUID, tags)
+}
+
+// ReplaceTags 将 userUUID 自己的图片的标签替换为 r.Tags，r.Tags 为空时清除全部标签.
+func (i *imageBiz) ReplaceTags(ctx context.Context, userUUID string, imageUUID string, r *api.UpdateImageTagsRequest) error {
+	tags, err := normalizeTags(r.Tags)
+	if err != nil {
+		return err
+	}
+	if _, err := i.getOwnedImage(ctx, userUUID, imageUUID); err != nil {
+		return err
+	}
+	return i.db.Image().ReplaceTags(ctx, imageUUID, tags, maxImageTags)
+}
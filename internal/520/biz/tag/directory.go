@@ -0,0 +1,82 @@
+package tag
+
+import (
+	"context"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"fmt"
+	"strings"
+)
+
+const (
+	// defaultTagListSize 是未指定 limit 时自动补全返回的标签数.
+	defaultTagListSize = 10
+	// maxTagListSize 是自动补全一次最多返回的标签数.
+	maxTagListSize = 50
+	// defaultPopularTagSize 是未指定 limit 时热门标签返回的标签数.
+	defaultPopularTagSize = 50
+	// maxPopularTagSize 是热门标签一次最多返回的标签数.
+	maxPopularTagSize = 200
+	// maxTagPrefixLength 与 image_tags.tag 列的长度一致.
+	maxTagPrefixLength = 255
+)
+
+// tagListLimit 检查 limit，为 0 时返回 defaultSize.
+func tagListLimit(limit, defaultSize, maxSize int) (int, error) {
+	if limit == 0 {
+		return defaultSize, nil
+	}
+	if limit < 0 || limit > maxSize {
+		return 0, fmt.Errorf("%w: limit must be between 1 and %d", errno.ErrInvalidParameter, maxSize)
+	}
+	return limit, nil
+}
+
+func tagCountInfos(tags []*model.TagCountM) *api.ListTagsResponse {
+	infos := make([]api.TagCountInfo, len(tags))
+	for i, tag := range tags {
+		namespace := tag.Namespace
+		if namespace == model.TagNamespaceGeneral {
+			namespace = "general"
+		}
+		infos[i] = api.TagCountInfo{
+			Tag:       tag.FullTag(),
+			Namespace: namespace,
+			Name:      tag.Tag,
+			Count:     tag.PublicCount,
+		}
+	}
+	return &api.ListTagsResponse{Count: len(infos), TagList: infos}
+}
+
+// List 按使用的公开图片数从多到少列出以 r.Prefix 开头的标签，供输入标签时自动补全.
+// 前缀中的空白与保存标签时一样替换为下划线，匹配不区分大小写.
+func (t *tagBiz) List(ctx context.Context, r *api.ListTagsRequest) (*api.ListTagsResponse, error) {
+	limit, err := tagListLimit(r.Limit, defaultTagListSize, maxTagListSize)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Join(strings.Fields(r.Prefix), "_")
+	if len(prefix) > maxTagPrefixLength {
+		return nil, fmt.Errorf("%w: prefix is longer than %d bytes", errno.ErrInvalidParameter, maxTagPrefixLength)
+	}
+	tags, err := t.db.TagCount().ListByPrefix(ctx, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return tagCountInfos(tags), nil
+}
+
+// ListPopular 按使用的公开图片数从多到少列出标签，供标签云使用.
+func (t *tagBiz) ListPopular(ctx context.Context, r *api.ListTagsRequest) (*api.ListTagsResponse, error) {
+	limit, err := tagListLimit(r.Limit, defaultPopularTagSize, maxPopularTagSize)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := t.db.TagCount().ListPopular(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list popular tags: %w", err)
+	}
+	return tagCountInfos(tags), nil
+}
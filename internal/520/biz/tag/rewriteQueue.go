@@ -0,0 +1,185 @@
+package tag
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/model"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	// rewriteBatchSize 是改写任务每批处理的图片数，每批处理后保存一次进度.
+	rewriteBatchSize = 100
+	// rewriteMaxAttempts 是改写任务最多尝试的次数.
+	rewriteMaxAttempts = 5
+	// rewriteRetryBackoff 是改写任务失败后重试前的等待时间.
+	rewriteRetryBackoff = time.Minute
+)
+
+var (
+	rewriteQueueOnce sync.Once
+	queue            *rewriteQueue
+)
+
+// RewriteQueue 在后台执行按当前规则改写已有图片标签的任务. 任务持久化在 tag_rewrite_jobs 表中，
+// 多个节点可以同时运行.
+type RewriteQueue interface {
+	// Start 启动 worker，重复调用无效.
+	Start()
+	// Stop 通知 worker 退出并等待正在执行的任务结束.
+	Stop()
+	// Notify 唤醒空闲的 worker 立即检查新任务.
+	Notify()
+}
+
+type rewriteQueue struct {
+	db           store.IStore
+	pollInterval time.Duration
+	lease        time.Duration
+
+	notify    chan struct{}
+	startOnce sync.Once
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+var _ RewriteQueue = (*rewriteQueue)(nil)
+
+// NewRewriteQueue 返回全局的标签改写任务队列，轮询间隔和租约与图片转换队列共用配置.
+func NewRewriteQueue(db store.IStore) RewriteQueue {
+	rewriteQueueOnce.Do(func() {
+		queue = &rewriteQueue{
+			db:           db,
+			pollInterval: viper.GetDuration("ConvertPollInterval"),
+			lease:        viper.GetDuration("ConvertLease"),
+			notify:       make(chan struct{}, 1),
+		}
+		if queue.pollInterval <= 0 {
+			queue.pollInterval = 5 * time.Second
+		}
+		if queue.lease <= 0 {
+			queue.lease = 10 * time.Minute
+		}
+	})
+	return queue
+}
+
+// notifyRewriteQueue 在队列已创建时唤醒 worker，队列未创建（例如单元测试中）时什么也不做.
+func notifyRewriteQueue() {
+	if queue != nil {
+		queue.Notify()
+	}
+}
+
+func (q *rewriteQueue) Start() {
+	q.startOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		q.cancel = cancel
+		q.wg.Add(1)
+		go q.work(ctx)
+		log.Infow("Tag rewrite queue started")
+	})
+}
+
+func (q *rewriteQueue) Stop() {
+	if q.cancel == nil {
+		return
+	}
+	q.cancel()
+	q.wg.Wait()
+}
+
+func (q *rewriteQueue) Notify() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// work 循环领取并执行任务，没有任务时等待通知或轮询间隔.
+func (q *rewriteQueue) work(ctx context.Context) {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := q.db.TagRule().ClaimRewriteJob(ctx, q.lease)
+		if err == nil {
+			q.process(ctx, job)
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) && ctx.Err() == nil {
+			log.Errorw("Failed to claim tag rewrite job", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// process 执行一个任务并保存结果. 失败的任务从保存的进度处重试，重试次数用尽时标记为 failed.
+func (q *rewriteQueue) process(ctx context.Context, job *model.TagRewriteJobM) {
+	err := RunRewriteJob(ctx, q.db, job, q.lease)
+	// 即使 worker 正在退出也要保存结果，否则任务要等租约过期才能被重新领取
+	ctx = context.WithoutCancel(ctx)
+	switch {
+	case err == nil:
+		job.Status = model.TagRewriteJobDone
+		job.LastError = ""
+	case job.Attempts >= rewriteMaxAttempts:
+		job.Status = model.TagRewriteJobFailed
+		job.LastError = truncateError(err)
+		log.Errorw("Tag rewrite job failed", "id", job.ID, "tag", job.Tag, "attempts", job.Attempts, "err", err)
+	default:
+		job.Status = model.TagRewriteJobPending
+		job.LastError = truncateError(err)
+		job.NextRunAt = time.Now().Add(rewriteRetryBackoff)
+		log.Warnw("Tag rewrite job will be retried", "id", job.ID, "tag", job.Tag, "attempts", job.Attempts, "err", err)
+	}
+	if err := q.db.TagRule().UpdateRewriteJob(ctx, job); err != nil {
+		log.Errorw("Failed to update tag rewrite job", "id", job.ID, "err", err)
+	}
+}
+
+// RunRewriteJob 从 job 保存的进度处开始，按当前规则改写带有 job.Tag 的全部图片，每批处理后保存进度
+// 并将租约延长 lease. 图片在处理前已被删除时跳过.
+func RunRewriteJob(ctx context.Context, db store.IStore, job *model.TagRewriteJobM, lease time.Duration) error {
+	for {
+		imageUUIDs, err := db.Image().ListUUIDsWithTag(ctx, job.Tag, job.LastImageUUID, rewriteBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(imageUUIDs) == 0 {
+			return nil
+		}
+		for _, imageUUID := range imageUUIDs {
+			if err := db.Image().ReapplyTagRules(ctx, imageUUID); err != nil && !errors.Is(err, errno.ErrImageNotFound) {
+				return err
+			}
+		}
+		job.LastImageUUID = imageUUIDs[len(imageUUIDs)-1]
+		job.Processed += int64(len(imageUUIDs))
+		job.NextRunAt = time.Now().Add(lease)
+		if err := db.TagRule().UpdateRewriteJob(ctx, job); err != nil {
+			return err
+		}
+	}
+}
+
+// truncateError 截断错误信息，使其能够写入 last_error 列.
+func truncateError(err error) string {
+	msg := err.Error()
+	if len(msg) > 512 {
+		msg = msg[:512]
+	}
+	return msg
+}
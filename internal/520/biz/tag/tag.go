@@ -0,0 +1,163 @@
+package tag
+
+import (
+	"context"
+	"demo520/internal/520/biz/image"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// TagBiz 提供标签目录，并管理标签的别名和蕴含规则. 修改规则后由 RewriteQueue 在后台改写已有图片的标签.
+type TagBiz interface {
+	List(ctx context.Context, r *api.ListTagsRequest) (*api.ListTagsResponse, error)
+	ListPopular(ctx context.Context, r *api.ListTagsRequest) (*api.ListTagsResponse, error)
+	ListAliases(ctx context.Context, r *api.PageRequest) (*api.ListTagAliasesResponse, error)
+	SetAlias(ctx context.Context, r *api.SetTagAliasRequest) (*api.TagRuleResponse, error)
+	DeleteAlias(ctx context.Context, alias string) error
+	ListImplications(ctx context.Context, r *api.PageRequest) (*api.ListTagImplicationsResponse, error)
+	AddImplication(ctx context.Context, r *api.AddTagImplicationRequest) (*api.TagRuleResponse, error)
+	DeleteImplication(ctx context.Context, tag string, implies string) error
+	GetRewriteJob(ctx context.Context, id uint) (*api.TagRewriteJobInfo, error)
+}
+
+type tagBiz struct {
+	db store.IStore
+}
+
+var _ TagBiz = (*tagBiz)(nil)
+
+func NewTagBiz(db store.IStore) TagBiz {
+	return &tagBiz{
+		db: db,
+	}
+}
+
+func jobInfo(job *model.TagRewriteJobM) api.TagRewriteJobInfo {
+	return api.TagRewriteJobInfo{
+		ID:        job.ID,
+		Tag:       job.Tag,
+		Status:    job.Status,
+		Processed: job.Processed,
+		LastError: job.LastError,
+		CreatedAt: job.CreatedAt.String(),
+		UpdatedAt: job.UpdatedAt.String(),
+	}
+}
+
+// normalizePair 规范化一条规则两端的标签.
+func normalizePair(a, b string) (string, string, error) {
+	a, err := image.NormalizeTag(a)
+	if err != nil {
+		return "", "", err
+	}
+	b, err = image.NormalizeTag(b)
+	if err != nil {
+		return "", "", err
+	}
+	return a, b, nil
+}
+
+func (t *tagBiz) ListAliases(ctx context.Context, r *api.PageRequest) (*api.ListTagAliasesResponse, error) {
+	page, pageSize, offset, err := image.NormalizePage(r)
+	if err != nil {
+		return nil, err
+	}
+	total, aliases, err := t.db.TagRule().ListAliases(ctx, offset, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tag aliases: %w", err)
+	}
+	infos := make([]api.TagAliasInfo, len(aliases))
+	for i, alias := range aliases {
+		infos[i] = api.TagAliasInfo{
+			Alias:     alias.Alias,
+			Tag:       alias.Tag,
+			CreatedAt: alias.CreatedAt.String(),
+			UpdatedAt: alias.UpdatedAt.String(),
+		}
+	}
+	return &api.ListTagAliasesResponse{Total: total, Page: page, PageSize: pageSize, AliasList: infos}, nil
+}
+
+// SetAlias 将别名改写为规范标签，并创建改写已有图片标签的后台任务.
+func (t *tagBiz) SetAlias(ctx context.Context, r *api.SetTagAliasRequest) (*api.TagRuleResponse, error) {
+	alias, tag, err := normalizePair(r.Alias, r.Tag)
+	if err != nil {
+		return nil, err
+	}
+	job, err := t.db.TagRule().SetAlias(ctx, alias, tag)
+	if err != nil {
+		return nil, err
+	}
+	notifyRewriteQueue()
+	return &api.TagRuleResponse{Job: jobInfo(job)}, nil
+}
+
+// DeleteAlias 删除别名规则，已改写的图片标签保持不变.
+func (t *tagBiz) DeleteAlias(ctx context.Context, alias string) error {
+	alias, err := image.NormalizeTag(alias)
+	if err != nil {
+		return err
+	}
+	return t.db.TagRule().DeleteAlias(ctx, alias)
+}
+
+func (t *tagBiz) ListImplications(ctx context.Context, r *api.PageRequest) (*api.ListTagImplicationsResponse, error) {
+	page, pageSize, offset, err := image.NormalizePage(r)
+	if err != nil {
+		return nil, err
+	}
+	total, implications, err := t.db.TagRule().ListImplications(ctx, offset, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tag implications: %w", err)
+	}
+	infos := make([]api.TagImplicationInfo, len(implications))
+	for i, implication := range implications {
+		infos[i] = api.TagImplicationInfo{
+			Tag:       implication.Tag,
+			Implies:   implication.Implies,
+			CreatedAt: implication.CreatedAt.String(),
+		}
+	}
+	return &api.ListTagImplicationsResponse{Total: total, Page: page, PageSize: pageSize, ImplicationList: infos}, nil
+}
+
+// AddImplication 添加蕴含规则，并创建为已有图片补齐标签的后台任务.
+func (t *tagBiz) AddImplication(ctx context.Context, r *api.AddTagImplicationRequest) (*api.TagRuleResponse, error) {
+	tag, implies, err := normalizePair(r.Tag, r.Implies)
+	if err != nil {
+		return nil, err
+	}
+	job, err := t.db.TagRule().AddImplication(ctx, tag, implies)
+	if err != nil {
+		return nil, err
+	}
+	notifyRewriteQueue()
+	return &api.TagRuleResponse{Job: jobInfo(job)}, nil
+}
+
+// DeleteImplication 删除蕴含规则，已补齐的标签保持不变.
+func (t *tagBiz) DeleteImplication(ctx context.Context, tag string, implies string) error {
+	tag, implies, err := normalizePair(tag, implies)
+	if err != nil {
+		return err
+	}
+	return t.db.TagRule().DeleteImplication(ctx, tag, implies)
+}
+
+func (t *tagBiz) GetRewriteJob(ctx context.Context, id uint) (*api.TagRewriteJobInfo, error) {
+	job, err := t.db.TagRule().GetRewriteJob(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrTagRewriteJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get rewrite job: %w", err)
+	}
+	info := jobInfo(job)
+	return &info, nil
+}
@@ -19,7 +19,7 @@ import (
 )
 
 type UserBiz interface {
-	ChangePassword(ctx context.Context, email string, r *api.ChangePasswordRequest) error
+	ChangePassword(ctx context.Context, userUUID, email string, r *api.ChangePasswordRequest) error
 	Login(ctx context.Context, r *api.LoginRequest) (*api.LoginResponse, error)
 	Create(ctx context.Context, r *api.CreateUserRequest) error
 	Get(ctx context.Context, email string) (*api.GetUserInfoResponse, error)
@@ -39,7 +39,15 @@ func NewUserBiz(db store.IStore) UserBiz {
 	}
 }
 
-func (u *userBiz) ChangePassword(ctx context.Context, email string, r *api.ChangePasswordRequest) error {
+// ChangePassword 修改 email 对应用户的密码，只有该用户本人可以修改.
+func (u *userBiz) ChangePassword(ctx context.Context, userUUID, email string, r *api.ChangePasswordRequest) error {
+	userM, err := u.db.User().Get(ctx, email)
+	if err != nil {
+		return err
+	}
+	if userUUID != userM.UserUUID {
+		return fmt.Errorf("%w: userUUID mismatch", errno.ErrUnauthorized)
+	}
 	return u.db.User().ChangePassword(ctx, email, r.OldPassword, r.NewPassword)
 }
 
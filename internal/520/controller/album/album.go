@@ -0,0 +1,14 @@
+package album
+
+import (
+	"demo520/internal/520/biz"
+	"demo520/internal/520/store"
+)
+
+type AlbumController struct {
+	b biz.IBiz
+}
+
+func NewAlbumController(db store.IStore) *AlbumController {
+	return &AlbumController{biz.NewIBiz(db)}
+}
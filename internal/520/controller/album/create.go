@@ -0,0 +1,30 @@
+package album
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Create 为当前用户创建合集.
+func (ctrl *AlbumController) Create(ctx *gin.Context) {
+	log.C(ctx).Infow("Create Album")
+	var req api.CreateAlbumRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Albums().Create(ctx, userUUID, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
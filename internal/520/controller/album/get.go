@@ -0,0 +1,36 @@
+package album
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// Get 返回合集信息及按顺序分页的图片，匿名用户只能读取公开合集中的公开图片.
+func (ctrl *AlbumController) Get(ctx *gin.Context) {
+	log.C(ctx).Infow("Get Album")
+	var req api.PageRequest
+
+	albumUUID := ctx.Param("albumUUID")
+	if !govalidator.IsUUIDv4(albumUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Albums().Get(ctx, userUUID, albumUUID, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
@@ -0,0 +1,100 @@
+package album
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// ListImages 按合集中的顺序以游标分页列出当前用户可见的图片.
+func (ctrl *AlbumController) ListImages(ctx *gin.Context) {
+	log.C(ctx).Infow("List Album Images")
+	var req api.CursorRange
+
+	albumUUID := ctx.Param("albumUUID")
+	if !govalidator.IsUUIDv4(albumUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Albums().ListImagesByCursor(ctx, userUUID, albumUUID, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
+
+// AddImages 按顺序将图片追加到合集末尾.
+func (ctrl *AlbumController) AddImages(ctx *gin.Context) {
+	log.C(ctx).Infow("Add Album Images")
+	var req api.AddAlbumImagesRequest
+
+	albumUUID := ctx.Param("albumUUID")
+	if !govalidator.IsUUIDv4(albumUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	if err := ctrl.b.Albums().AddImages(ctx, userUUID, albumUUID, &req); err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, nil)
+}
+
+// RemoveImage 从合集中移除一张图片.
+func (ctrl *AlbumController) RemoveImage(ctx *gin.Context) {
+	log.C(ctx).Infow("Remove Album Image")
+
+	albumUUID, imageUUID := ctx.Param("albumUUID"), ctx.Param("imageUUID")
+	if !govalidator.IsUUIDv4(albumUUID) || !govalidator.IsUUIDv4(imageUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	if err := ctrl.b.Albums().RemoveImage(ctx, userUUID, albumUUID, imageUUID); err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, nil)
+}
+
+// Reorder 按请求中的顺序重新排列合集.
+func (ctrl *AlbumController) Reorder(ctx *gin.Context) {
+	log.C(ctx).Infow("Reorder Album")
+	var req api.ReorderAlbumRequest
+
+	albumUUID := ctx.Param("albumUUID")
+	if !govalidator.IsUUIDv4(albumUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	if err := ctrl.b.Albums().Reorder(ctx, userUUID, albumUUID, &req); err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, nil)
+}
@@ -0,0 +1,67 @@
+package album
+
+import (
+	imagebiz "demo520/internal/520/biz/image"
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// ListMine 分页列出当前用户的全部合集.
+func (ctrl *AlbumController) ListMine(ctx *gin.Context) {
+	log.C(ctx).Infow("List My Albums")
+	var req api.ListAlbumsRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	ctrl.listAlbums(ctx, userUUID, userUUID, &req)
+}
+
+// ListUserAlbums 分页列出指定用户的合集，非所有者只能看到公开合集.
+func (ctrl *AlbumController) ListUserAlbums(ctx *gin.Context) {
+	log.C(ctx).Infow("List User Albums")
+	var req api.ListAlbumsRequest
+
+	ownerUUID := ctx.Param("userUUID")
+	if !govalidator.IsUUID(ownerUUID) {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	ctrl.listAlbums(ctx, userUUID, ownerUUID, &req)
+}
+
+// listAlbums 列出 ownerUUID 的合集并写回响应，指定 cursor 或 limit 时按游标分页，否则按页码分页.
+func (ctrl *AlbumController) listAlbums(ctx *gin.Context, userUUID, ownerUUID string, req *api.ListAlbumsRequest) {
+	isCursor, err := imagebiz.IsCursorPage(&req.PageRequest, &req.CursorRange)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+
+	var resp interface{}
+	if isCursor {
+		resp, err = ctrl.b.Albums().ListUserAlbumsByCursor(ctx, userUUID, ownerUUID, &req.CursorRange)
+	} else {
+		resp, err = ctrl.b.Albums().ListUserAlbums(ctx, userUUID, ownerUUID, &req.PageRequest)
+	}
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
@@ -0,0 +1,54 @@
+package album
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// Update 修改合集信息，请求中未出现的字段保持不变.
+func (ctrl *AlbumController) Update(ctx *gin.Context) {
+	log.C(ctx).Infow("Update Album")
+	var req api.UpdateAlbumRequest
+
+	albumUUID := ctx.Param("albumUUID")
+	if !govalidator.IsUUIDv4(albumUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Albums().Update(ctx, userUUID, albumUUID, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
+
+// Delete 删除合集，合集中的图片不受影响.
+func (ctrl *AlbumController) Delete(ctx *gin.Context) {
+	log.C(ctx).Infow("Delete Album")
+
+	albumUUID := ctx.Param("albumUUID")
+	if !govalidator.IsUUIDv4(albumUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	if err := ctrl.b.Albums().Delete(ctx, userUUID, albumUUID); err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, nil)
+}
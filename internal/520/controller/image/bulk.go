@@ -0,0 +1,50 @@
+package image
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BulkEdit 创建批量修改当前用户图片标签和可见性的后台任务，返回任务及其进度.
+func (ctrl *ImageController) BulkEdit(ctx *gin.Context) {
+	log.C(ctx).Infow("BulkEdit")
+	var req api.BulkEditImagesRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Images().CreateBulkJob(ctx, userUUID, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
+
+// GetBulkJob 返回当前用户的批量修改任务的进度.
+func (ctrl *ImageController) GetBulkJob(ctx *gin.Context) {
+	log.C(ctx).Infow("GetBulkJob")
+
+	id, err := strconv.ParseUint(ctx.Param("jobID"), 10, 32)
+	if err != nil {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Images().GetBulkJob(ctx, userUUID, uint(id))
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
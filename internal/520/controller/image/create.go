@@ -3,6 +3,7 @@ package image
 import (
 	"demo520/internal/pkg/core"
 	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
 	"demo520/internal/pkg/log"
 	"demo520/pkg/api"
 	"encoding/json"
@@ -35,9 +36,9 @@ func (ctrl *ImageController) Create(ctx *gin.Context) {
 		return
 	}
 
-	jwtUserUUID, err := pareseJwtAndEqualReqUUID(ctx, &req)
-	if err != nil {
-		core.WriteResponse(ctx, err, nil)
+	userUUID := ctx.GetString(known.XUsernameKey)
+	if req.GetUserUUID() != userUUID {
+		core.WriteResponse(ctx, errno.ErrUnauthorized, nil)
 		return
 	}
 
@@ -46,7 +47,7 @@ func (ctrl *ImageController) Create(ctx *gin.Context) {
 		core.WriteResponse(ctx, err, nil)
 		return
 	}
-	respo, err := ctrl.b.Images().Create(ctx, jwtUserUUID, &req, file)
+	respo, err := ctrl.b.Images().Create(ctx, userUUID, &req, file)
 	if err != nil {
 		core.WriteResponse(ctx, err, nil)
 		return
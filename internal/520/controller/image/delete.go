@@ -3,6 +3,7 @@ package image
 import (
 	"demo520/internal/pkg/core"
 	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
 	"demo520/internal/pkg/log"
 
 	"github.com/asaskevich/govalidator"
@@ -12,19 +13,15 @@ import (
 func (ctrl *ImageController) DeleteImage(ctx *gin.Context) {
 	log.C(ctx).Infow("DeleteImage")
 
-	var req string = ctx.Param("imageuuid")
+	var imageUUID string = ctx.Param("imageUUID")
 
-	if _, err := govalidator.ValidateStruct(req); err != nil {
+	if !govalidator.IsUUIDv4(imageUUID) {
 		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
 		return
 	}
 
-	jwtUserUUID, err := pareseJwtAndEqualReqUUID(ctx, nil)
-	if err != nil {
-		core.WriteResponse(ctx, err, nil)
-		return
-	}
-	if err := ctrl.b.Images().Delete(ctx, jwtUserUUID, ctx.Param("imageId")); err != nil {
+	userUUID := ctx.GetString(known.XUsernameKey)
+	if err := ctrl.b.Images().Delete(ctx, userUUID, imageUUID); err != nil {
 		core.WriteResponse(ctx, err, nil)
 		return
 	}
@@ -0,0 +1,41 @@
+package image
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// DeleteCollection 批量删除当前用户的图片，返回每张图片的处理结果.
+func (ctrl *ImageController) DeleteCollection(ctx *gin.Context) {
+	log.C(ctx).Infow("DeleteCollection")
+	var req api.DeleteImagesRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+	if len(req.ImageUUIDs) == 0 {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+	for _, imageUUID := range req.ImageUUIDs {
+		if !govalidator.IsUUIDv4(imageUUID) {
+			core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+			return
+		}
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Images().DeleteCollection(ctx, userUUID, req.ImageUUIDs)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
@@ -0,0 +1,82 @@
+package image
+
+import (
+	imagebiz "demo520/internal/520/biz/image"
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// AddFavorite 收藏一张当前用户可见的图片.
+func (ctrl *ImageController) AddFavorite(ctx *gin.Context) {
+	log.C(ctx).Infow("AddFavorite")
+	var req api.AddFavoriteRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+	if _, err := govalidator.ValidateStruct(req); err != nil {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	if err := ctrl.b.Images().AddFavorite(ctx, userUUID, req.ImageUUID); err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, nil)
+}
+
+// RemoveFavorite 取消收藏.
+func (ctrl *ImageController) RemoveFavorite(ctx *gin.Context) {
+	log.C(ctx).Infow("RemoveFavorite")
+
+	imageUUID := ctx.Param("imageUUID")
+	if !govalidator.IsUUIDv4(imageUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	if err := ctrl.b.Images().RemoveFavorite(ctx, userUUID, imageUUID); err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, nil)
+}
+
+// ListFavorites 分页列出当前用户收藏且仍可见的图片，指定 cursor 或 limit 时按游标分页，否则按页码分页.
+func (ctrl *ImageController) ListFavorites(ctx *gin.Context) {
+	log.C(ctx).Infow("ListFavorites")
+	var req api.ListFavoritesRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+	isCursor, err := imagebiz.IsCursorPage(&req.PageRequest, &req.CursorRange)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	var resp interface{}
+	if isCursor {
+		resp, err = ctrl.b.Images().ListFavoritesByCursor(ctx, userUUID, &req.CursorRange)
+	} else {
+		resp, err = ctrl.b.Images().ListFavorites(ctx, userUUID, &req.PageRequest)
+	}
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
@@ -3,6 +3,7 @@ package image
 import (
 	"demo520/internal/pkg/core"
 	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
 	"demo520/internal/pkg/log"
 
 	"github.com/asaskevich/govalidator"
@@ -11,19 +12,16 @@ import (
 
 func (ctrl *ImageController) Get(ctx *gin.Context) {
 	log.C(ctx).Infow("Get image")
-	var imageUUID string = ctx.Param("imageuuid")
+	var imageUUID string = ctx.Param("imageUUID")
 
 	if govalidator.IsUUIDv4(imageUUID) == false {
 		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
 		return
 	}
 
-	jwtUserUUID, err := pareseJwtAndEqualReqUUID(ctx, nil)
-	if err != nil {
-		core.WriteResponse(ctx, err, nil)
-		return
-	}
-	resp, err := ctrl.b.Images().Get(ctx, jwtUserUUID, imageUUID)
+	// 匿名用户的 userUUID 为空，只能访问公开图片
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Images().Get(ctx, userUUID, imageUUID)
 	if err != nil {
 		core.WriteResponse(ctx, err, nil)
 		return
@@ -0,0 +1,152 @@
+package image
+
+import (
+	imagebiz "demo520/internal/520/biz/image"
+	"demo520/internal/pkg/convert"
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// immutableMaxAge 是图片文件的缓存时间. 同一个 hash 的内容永远不会改变，因此可以长期缓存.
+const immutableMaxAge = "max-age=31536000, immutable"
+
+func (ctrl *ImageController) GetFile(ctx *gin.Context) {
+	log.C(ctx).Infow("Get image file")
+	var imageUUID string = ctx.Param("imageUUID")
+
+	if !govalidator.IsUUIDv4(imageUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+
+	var query fileQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	// 匿名用户的 userUUID 为空，只能访问公开图片
+	userUUID := ctx.GetString(known.XUsernameKey)
+	variants := negotiateVariants(ctx.GetHeader("Accept"))
+	var file *imagebiz.ImageFileInfo
+	var err error
+	if query.isTransform() {
+		// 请求缩略图时必须指定宽度
+		if query.Width == 0 {
+			core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+			return
+		}
+		file, err = ctrl.b.Images().GetDerivedFile(ctx, userUUID, imageUUID, query.transform(variants))
+	} else {
+		file, err = ctrl.b.Images().GetFile(ctx, userUUID, imageUUID, variants)
+	}
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	defer file.Close()
+
+	if file.IsPublic {
+		serveImageFile(ctx, file, "public, "+immutableMaxAge)
+	} else {
+		serveImageFile(ctx, file, "private, "+immutableMaxAge)
+	}
+}
+
+// serveImageFile 将图片文件写入响应.
+func serveImageFile(ctx *gin.Context, file *imagebiz.ImageFileInfo, cacheControl string) {
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", file.ContentType)
+	header.Set("ETag", file.ETag)
+	header.Set("Vary", "Accept")
+	header.Set("Cache-Control", cacheControl)
+	// ServeContent 负责处理 If-None-Match、If-Modified-Since 以及 Range 请求
+	http.ServeContent(ctx.Writer, ctx.Request, "", file.ModTime, file)
+}
+
+// fileQuery 是图片文件接口的查询参数，指定 w 时返回缩略图.
+type fileQuery struct {
+	Width  int    `form:"w" binding:"gte=0"`
+	Height int    `form:"h" binding:"gte=0"`
+	Fit    string `form:"fit"`
+	Format string `form:"fmt"`
+}
+
+func (q *fileQuery) isTransform() bool {
+	return q.Width != 0 || q.Height != 0 || q.Fit != "" || q.Format != ""
+}
+
+// transform 将查询参数转换为缩略图变换. 未指定 fmt 时按 Accept 头在 AVIF/WebP 中协商，
+// 都不支持时回退到 JPEG；未指定 fit 时默认为 contain.
+func (q *fileQuery) transform(variants []imagebiz.Variant) convert.Transform {
+	t := convert.Transform{
+		Width:  q.Width,
+		Height: q.Height,
+		Fit:    q.Fit,
+		Format: q.Format,
+	}
+	if t.Fit == "" {
+		t.Fit = convert.FitContain
+	}
+	if t.Format == "" {
+		t.Format = convert.FormatJPEG
+		if variants[0] != imagebiz.VariantOriginal {
+			t.Format = string(variants[0])
+		}
+	}
+	return t
+}
+
+// negotiateVariants 根据 Accept 头返回按优先级排序的候选图片版本.
+// 只有客户端显式声明支持的 AVIF/WebP 才会被选中，通配符只匹配原图，原图总是作为兜底.
+func negotiateVariants(accept string) []imagebiz.Variant {
+	type candidate struct {
+		variant imagebiz.Variant
+		q       float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(key) != "q" {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(mediaRange)) {
+		case "image/avif":
+			candidates = append(candidates, candidate{imagebiz.VariantAVIF, q})
+		case "image/webp":
+			candidates = append(candidates, candidate{imagebiz.VariantWebP, q})
+		}
+	}
+	// q 值相同时 AVIF 优先于 WebP
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].q != candidates[j].q {
+			return candidates[i].q > candidates[j].q
+		}
+		return candidates[i].variant == imagebiz.VariantAVIF && candidates[j].variant != imagebiz.VariantAVIF
+	})
+
+	variants := make([]imagebiz.Variant, 0, len(candidates)+1)
+	for _, c := range candidates {
+		variants = append(variants, c.variant)
+	}
+	return append(variants, imagebiz.VariantOriginal)
+}
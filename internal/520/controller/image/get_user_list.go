@@ -3,7 +3,9 @@ package image
 import (
 	"demo520/internal/pkg/core"
 	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
 	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,13 +19,19 @@ func (ctrl *ImageController) GetUserImagesList(ctx *gin.Context) {
 		return
 	}
 
-	jwtUserUUID, err := pareseJwtAndEqualReqUUID(ctx, nil)
-	if err != nil {
+	if err := listRange.validate(); err != nil {
 		core.WriteResponse(ctx, err, nil)
 		return
 	}
 
-	resp, err := ctrl.b.Images().ListUserOwnImages(ctx, jwtUserUUID, listRange.Offset, listRange.Limit)
+	userUUID := ctx.GetString(known.XUsernameKey)
+	var resp *api.ListImageResponse
+	var err error
+	if listRange.isOffset() {
+		resp, err = ctrl.b.Images().ListUserOwnImages(ctx, userUUID, listRange.Offset, listRange.Limit, &listRange.ImageListFilter)
+	} else {
+		resp, err = ctrl.b.Images().ListUserOwnImagesByCursor(ctx, userUUID, listRange.cursorRequest())
+	}
 	if err != nil {
 		core.WriteResponse(ctx, err, nil)
 		return
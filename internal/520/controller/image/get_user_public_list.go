@@ -4,6 +4,7 @@ import (
 	"demo520/internal/pkg/core"
 	"demo520/internal/pkg/errno"
 	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/gin-gonic/gin"
@@ -25,7 +26,18 @@ func (ctrl *ImageController) GetUserPublicList(ctx *gin.Context) {
 		return
 	}
 
-	resp, err := ctrl.b.Images().ListUserOwnPublicImages(ctx, userUUID, listRange.Offset, listRange.Limit)
+	if err := listRange.validate(); err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+
+	var resp *api.ListImageResponse
+	var err error
+	if listRange.isOffset() {
+		resp, err = ctrl.b.Images().ListUserOwnPublicImages(ctx, userUUID, listRange.Offset, listRange.Limit, &listRange.ImageListFilter)
+	} else {
+		resp, err = ctrl.b.Images().ListUserOwnPublicImagesByCursor(ctx, userUUID, listRange.cursorRequest())
+	}
 	if err != nil {
 		core.WriteResponse(ctx, err, nil)
 		return
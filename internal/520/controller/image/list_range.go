@@ -1,6 +1,39 @@
 package image
 
+import (
+	"demo520/internal/pkg/errno"
+	"demo520/pkg/api"
+	"fmt"
+)
+
+// ListRange 是图片列表的分页参数. 过渡期间同时支持两种分页方式：指定 offset 时按 offset 分页，
+// 否则按游标分页，cursor 为上一页返回的 next_cursor，为空时从第一页开始. 两种方式都支持相同的过滤和排序条件.
+// limit 的范围与其他游标分页的列表相同，由 biz 层统一校验.
+// limit 的范围与其他游标分页的列表相同，由 biz 层统一校验.
 type ListRange struct {
-	Offset int `form:"offset" binding:"required,gt=0"`
-	Limit  int `form:"limit" binding:"required,gte=10,lte=30"`
+	Offset    int    `form:"offset" binding:"gte=0"`
+	Cursor    string `form:"cursor"`
+	Limit     int    `form:"limit"`
+	WithTotal bool   `form:"with_total"`
+	api.ImageListFilter
+}
+
+// validate 检查分页参数没有同时指定两种分页方式.
+func (r *ListRange) validate() error {
+	if r.Offset > 0 && r.Cursor != "" {
+		return fmt.Errorf("%w: offset and cursor cannot be used together", errno.ErrInvalidParameter)
+	}
+	return nil
+}
+
+// isOffset 判断是否按 offset 分页.
+func (r *ListRange) isOffset() bool {
+	return r.Offset > 0
+}
+
+func (r *ListRange) cursorRequest() *api.CursorPageRequest {
+	return &api.CursorPageRequest{
+		CursorRange:     api.CursorRange{Cursor: r.Cursor, Limit: r.Limit, WithTotal: r.WithTotal},
+		ImageListFilter: r.ImageListFilter,
+	}
 }
@@ -0,0 +1,36 @@
+package image
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// Patch 修改图片的描述和元数据，请求中未出现的字段保持不变.
+func (ctrl *ImageController) Patch(ctx *gin.Context) {
+	log.C(ctx).Infow("Patch Image")
+	var req api.PatchImageRequest
+
+	imageUUID := ctx.Param("imageUUID")
+	if !govalidator.IsUUIDv4(imageUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Images().Patch(ctx, userUUID, imageUUID, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
@@ -0,0 +1,43 @@
+package image
+
+import (
+	imagebiz "demo520/internal/520/biz/image"
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Search 按标签搜索图片，匿名用户只能搜索到公开图片，登录用户还能搜索到自己的私有图片.
+// 指定 cursor 或 limit 时按游标分页，否则按页码分页.
+func (ctrl *ImageController) Search(ctx *gin.Context) {
+	log.C(ctx).Infow("Search")
+	var req api.SearchImagesRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	isCursor, err := imagebiz.IsCursorPage(&req.PageRequest, &req.CursorRange)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	var resp interface{}
+	if isCursor {
+		resp, err = ctrl.b.Images().SearchByCursor(ctx, userUUID, &req)
+	} else {
+		resp, err = ctrl.b.Images().Search(ctx, userUUID, &req)
+	}
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
@@ -0,0 +1,133 @@
+package image
+
+import (
+	imagebiz "demo520/internal/520/biz/image"
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateShare 为图片生成新的分享链接，原有的分享链接随之失效.
+func (ctrl *ImageController) CreateShare(ctx *gin.Context) {
+	log.C(ctx).Infow("Create Share")
+	var req api.CreateShareRequest
+
+	imageUUID := ctx.Param("imageUUID")
+	if !govalidator.IsUUIDv4(imageUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+	// 请求体可以省略，表示不限制有效期和访问次数
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			core.WriteResponse(ctx, errno.ErrBind, nil)
+			return
+		}
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Images().CreateShare(ctx, userUUID, imageUUID, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
+
+// GetShare 返回图片当前的分享链接及其访问次数.
+func (ctrl *ImageController) GetShare(ctx *gin.Context) {
+	log.C(ctx).Infow("Get Share")
+
+	imageUUID := ctx.Param("imageUUID")
+	if !govalidator.IsUUIDv4(imageUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Images().GetShare(ctx, userUUID, imageUUID)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
+
+// RevokeShare 撤销图片的分享链接.
+func (ctrl *ImageController) RevokeShare(ctx *gin.Context) {
+	log.C(ctx).Infow("Revoke Share")
+
+	imageUUID := ctx.Param("imageUUID")
+	if !govalidator.IsUUIDv4(imageUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	if err := ctrl.b.Images().RevokeShare(ctx, userUUID, imageUUID); err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, nil)
+}
+
+func shareClient(ctx *gin.Context) imagebiz.ShareClient {
+	return imagebiz.ShareClient{
+		IP:           ctx.ClientIP(),
+		UserAgent:    ctx.Request.UserAgent(),
+		Continuation: isRangeContinuation(ctx.Request),
+	}
+}
+
+// isRangeContinuation 判断请求是否只读取文件开头以外的部分. 后缀区间可能覆盖整个文件，
+// If-Range 不匹配时会返回完整文件，这两种情况都不算后续分段.
+func isRangeContinuation(r *http.Request) bool {
+	spec, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes=")
+	if !ok || r.Header.Get("If-Range") != "" {
+		return false
+	}
+	for _, part := range strings.Split(spec, ",") {
+		start, _, _ := strings.Cut(strings.TrimSpace(part), "-")
+		n, err := strconv.ParseInt(start, 10, 64)
+		if err != nil || n <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// GetShared 通过分享链接读取图片信息，无需登录.
+func (ctrl *ImageController) GetShared(ctx *gin.Context) {
+	log.C(ctx).Infow("Get Shared Image")
+
+	resp, err := ctrl.b.Images().GetShared(ctx, ctx.Param("token"), shareClient(ctx))
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
+
+// GetSharedFile 通过分享链接读取图片文件，无需登录. 每次读取都会计入访问次数，因此响应不允许缓存；
+// 分段读取时不包含文件开头的后续分段不计入.
+func (ctrl *ImageController) GetSharedFile(ctx *gin.Context) {
+	log.C(ctx).Infow("Get Shared Image file")
+
+	variants := negotiateVariants(ctx.GetHeader("Accept"))
+	file, err := ctrl.b.Images().GetSharedFile(ctx, ctx.Param("token"), variants, shareClient(ctx))
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	defer file.Close()
+
+	serveImageFile(ctx, file, "no-store")
+}
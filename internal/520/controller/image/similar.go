@@ -0,0 +1,36 @@
+package image
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// Similar 列出与指定图片近似的图片，匿名用户只能看到公开图片.
+func (ctrl *ImageController) Similar(ctx *gin.Context) {
+	log.C(ctx).Infow("Similar Images")
+	var req api.SimilarImagesRequest
+
+	imageUUID := ctx.Param("imageUUID")
+	if !govalidator.IsUUIDv4(imageUUID) {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	userUUID := ctx.GetString(known.XUsernameKey)
+	resp, err := ctrl.b.Images().Similar(ctx, userUUID, imageUUID, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
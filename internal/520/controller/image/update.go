@@ -1,8 +1,10 @@
 package image
 
 import (
+	"context"
 	"demo520/internal/pkg/core"
 	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
 	"demo520/internal/pkg/log"
 	"demo520/pkg/api"
 
@@ -10,8 +12,11 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func (ctrl *ImageController) UpdateImageTags(ctx *gin.Context) {
-	log.C(ctx).Infow("UpdateImageTags")
+// tagsHandler 是修改图片标签的 biz 方法.
+type tagsHandler func(ctx context.Context, userUUID string, imageUUID string, r *api.UpdateImageTagsRequest) error
+
+// handleTags 解析请求中的标签列表并调用 handler 修改当前用户图片的标签.
+func handleTags(ctx *gin.Context, handler tagsHandler) {
 	var req api.UpdateImageTagsRequest
 
 	imageUUID := ctx.Param("imageUUID")
@@ -25,15 +30,29 @@ func (ctrl *ImageController) UpdateImageTags(ctx *gin.Context) {
 		return
 	}
 
-	userUUID, ok := ctx.Value("useruuid").(string)
-	if !ok {
-		core.WriteResponse(ctx, errno.ErrUnauthorized, nil)
-		return
-	}
+	userUUID := ctx.GetString(known.XUsernameKey)
 
-	if err := ctrl.b.Images().UpdateTags(ctx, userUUID, imageUUID, &req); err != nil {
+	if err := handler(ctx, userUUID, imageUUID, &req); err != nil {
 		core.WriteResponse(ctx, err, nil)
 		return
 	}
 	core.WriteResponse(ctx, nil, nil)
 }
+
+// UpdateImageTags 为图片追加标签.
+func (ctrl *ImageController) UpdateImageTags(ctx *gin.Context) {
+	log.C(ctx).Infow("UpdateImageTags")
+	handleTags(ctx, ctrl.b.Images().UpdateTags)
+}
+
+// ReplaceImageTags 将图片的标签替换为请求中的标签列表.
+func (ctrl *ImageController) ReplaceImageTags(ctx *gin.Context) {
+	log.C(ctx).Infow("ReplaceImageTags")
+	handleTags(ctx, ctrl.b.Images().ReplaceTags)
+}
+
+// DeleteImageTags 从图片上删除请求中的标签.
+func (ctrl *ImageController) DeleteImageTags(ctx *gin.Context) {
+	log.C(ctx).Infow("DeleteImageTags")
+	handleTags(ctx, ctrl.b.Images().DeleteTags)
+}
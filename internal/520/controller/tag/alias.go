@@ -0,0 +1,57 @@
+package tag
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAliases 分页列出别名规则.
+func (ctrl *TagController) ListAliases(ctx *gin.Context) {
+	log.C(ctx).Infow("List Tag Aliases")
+	var req api.PageRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	resp, err := ctrl.b.Tags().ListAliases(ctx, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
+
+// SetAlias 添加或修改别名规则，返回改写已有图片的后台任务.
+func (ctrl *TagController) SetAlias(ctx *gin.Context) {
+	log.C(ctx).Infow("Set Tag Alias")
+	var req api.SetTagAliasRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	resp, err := ctrl.b.Tags().SetAlias(ctx, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
+
+// DeleteAlias 删除查询参数 alias 指定的别名规则. 标签中可能含有 /，因此不放在路径中.
+func (ctrl *TagController) DeleteAlias(ctx *gin.Context) {
+	log.C(ctx).Infow("Delete Tag Alias")
+
+	if err := ctrl.b.Tags().DeleteAlias(ctx, ctx.Query("alias")); err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, nil)
+}
@@ -0,0 +1,46 @@
+package tag
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// List 按前缀列出标签，供自动补全使用.
+func (ctrl *TagController) List(ctx *gin.Context) {
+	log.C(ctx).Infow("List Tags")
+	var req api.ListTagsRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	resp, err := ctrl.b.Tags().List(ctx, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
+
+// ListPopular 列出最常用的标签，供标签云使用.
+func (ctrl *TagController) ListPopular(ctx *gin.Context) {
+	log.C(ctx).Infow("List Popular Tags")
+	var req api.ListTagsRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	resp, err := ctrl.b.Tags().ListPopular(ctx, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
@@ -0,0 +1,57 @@
+package tag
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/log"
+	"demo520/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListImplications 分页列出蕴含规则.
+func (ctrl *TagController) ListImplications(ctx *gin.Context) {
+	log.C(ctx).Infow("List Tag Implications")
+	var req api.PageRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	resp, err := ctrl.b.Tags().ListImplications(ctx, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
+
+// AddImplication 添加蕴含规则，返回为已有图片补齐标签的后台任务.
+func (ctrl *TagController) AddImplication(ctx *gin.Context) {
+	log.C(ctx).Infow("Add Tag Implication")
+	var req api.AddTagImplicationRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		core.WriteResponse(ctx, errno.ErrBind, nil)
+		return
+	}
+
+	resp, err := ctrl.b.Tags().AddImplication(ctx, &req)
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
+
+// DeleteImplication 删除查询参数 tag 和 implies 指定的蕴含规则.
+func (ctrl *TagController) DeleteImplication(ctx *gin.Context) {
+	log.C(ctx).Infow("Delete Tag Implication")
+
+	if err := ctrl.b.Tags().DeleteImplication(ctx, ctx.Query("tag"), ctx.Query("implies")); err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, nil)
+}
@@ -0,0 +1,28 @@
+package tag
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRewriteJob 返回标签改写任务的进度.
+func (ctrl *TagController) GetRewriteJob(ctx *gin.Context) {
+	log.C(ctx).Infow("Get Tag Rewrite Job")
+
+	id, err := strconv.ParseUint(ctx.Param("jobID"), 10, 32)
+	if err != nil {
+		core.WriteResponse(ctx, errno.ErrInvalidParameter, nil)
+		return
+	}
+
+	resp, err := ctrl.b.Tags().GetRewriteJob(ctx, uint(id))
+	if err != nil {
+		core.WriteResponse(ctx, err, nil)
+		return
+	}
+	core.WriteResponse(ctx, nil, resp)
+}
@@ -0,0 +1,15 @@
+package tag
+
+import (
+	"demo520/internal/520/biz"
+	"demo520/internal/520/store"
+)
+
+// TagController 处理标签目录和管理员维护标签别名、蕴含规则的请求.
+type TagController struct {
+	b biz.IBiz
+}
+
+func NewTagController(db store.IStore) *TagController {
+	return &TagController{biz.NewIBiz(db)}
+}
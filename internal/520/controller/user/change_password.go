@@ -3,6 +3,7 @@ package user
 import (
 	"demo520/internal/pkg/core"
 	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
 	"demo520/internal/pkg/log"
 	"demo520/pkg/api"
 	"github.com/asaskevich/govalidator"
@@ -23,7 +24,8 @@ func (ctrl *UserController) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.b.Users().ChangePassword(c, c.Param("email"), &r); err != nil {
+	userUUID := c.GetString(known.XUsernameKey)
+	if err := ctrl.b.Users().ChangePassword(c, userUUID, c.Param("email"), &r); err != nil {
 		core.WriteResponse(c, err, nil)
 		return
 	}
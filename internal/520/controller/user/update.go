@@ -3,9 +3,9 @@ package user
 import (
 	"demo520/internal/pkg/core"
 	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
 	"demo520/internal/pkg/log"
 	"demo520/pkg/api"
-	"demo520/pkg/token"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/gin-gonic/gin"
@@ -25,11 +25,7 @@ func (ctrl *UserController) Update(c *gin.Context) {
 		return
 	}
 
-	userUUID, err := token.ParseRequest(c)
-	if err != nil {
-		core.WriteResponse(c, errno.ErrTokenInvalid, nil)
-		return
-	}
+	userUUID := c.GetString(known.XUsernameKey)
 	if err := ctrl.b.Users().Update(c, userUUID, c.Param("email"), &r); err != nil {
 		core.WriteResponse(c, err, nil)
 		return
@@ -0,0 +1,38 @@
+package demo520
+
+import (
+	"context"
+	"demo520/internal/520/biz"
+	"demo520/internal/pkg/convert"
+	"encoding/json"
+	"flag"
+	"os"
+)
+
+// runGC 执行一次图片文件垃圾回收，并将回收报告以 JSON 格式输出到标准输出.
+func runGC(args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Only report the blobs that would be collected.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ds, err := initStore()
+	if err != nil {
+		return err
+	}
+	if err := initStorage(); err != nil {
+		return err
+	}
+	defer convert.InitImageConverter().Shutdown()
+
+	report, err := biz.NewIBiz(ds).Blobs().Collect(context.Background(), *dryRun)
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(report); encodeErr != nil && err == nil {
+			err = encodeErr
+		}
+	}
+	return err
+}
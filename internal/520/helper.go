@@ -0,0 +1,117 @@
+package demo520
+
+import (
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/db"
+	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/storage"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// defaultConfigDir 指定了服务的默认配置目录.
+	defaultConfigDir = ".520"
+
+	// defaultConfigName 指定了服务的默认配置文件名.
+	defaultConfigName = "520.yaml"
+)
+
+// initConfig 读取配置文件和环境变量.
+func initConfig(cfgFile string) error {
+	if cfgFile != "" {
+		// 从命令行选项指定的配置文件中读取
+		viper.SetConfigFile(cfgFile)
+	} else {
+		// 查找用户主目录
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		// 依次在 $HOME/.520、当前目录和 configs 目录中查找配置文件
+		viper.AddConfigPath(filepath.Join(home, defaultConfigDir))
+		viper.AddConfigPath(".")
+		viper.AddConfigPath("configs")
+		viper.SetConfigType("yaml")
+		viper.SetConfigName(defaultConfigName)
+	}
+
+	// 读取匹配的环境变量, 例如 DEMO520_DB_PASSWORD 对应 db.password
+	viper.AutomaticEnv()
+	viper.SetEnvPrefix("DEMO520")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("read config file failed: %w", err)
+	}
+	return nil
+}
+
+// logOptions 从 viper 中读取日志配置，构建 *log.LogConfig.
+func logOptions() *log.LogConfig {
+	opts := log.NewLogConfig()
+	if viper.IsSet("log.disable-caller") {
+		opts.DisableCaller = viper.GetBool("log.disable-caller")
+	}
+	if viper.IsSet("log.disable-stacktrace") {
+		opts.DisableStacktrace = viper.GetBool("log.disable-stacktrace")
+	}
+	if viper.IsSet("log.level") {
+		opts.Level = viper.GetString("log.level")
+	}
+	if viper.IsSet("log.encoding") {
+		opts.Encoding = viper.GetString("log.encoding")
+	}
+	if viper.IsSet("log.output-paths") {
+		opts.OutputPaths = viper.GetStringSlice("log.output-paths")
+	}
+	return opts
+}
+
+// initStore 读取 db 配置，创建 gorm.DB 实例，迁移数据表并初始化 store 层.
+func initStore() (store.IStore, error) {
+	dbOptions := &db.MySQLOptions{
+		Host:                  viper.GetString("db.host"),
+		Username:              viper.GetString("db.username"),
+		Password:              viper.GetString("db.password"),
+		Database:              viper.GetString("db.database"),
+		MaxIdleConnections:    viper.GetInt("db.max-idle-connections"),
+		MaxOpenConnections:    viper.GetInt("db.max-open-connections"),
+		MaxConnectionLifeTime: viper.GetDuration("db.max-connection-life-time"),
+		LogLevel:              viper.GetInt("db.log-level"),
+	}
+
+	ins, err := db.NewMySQL(dbOptions)
+	if err != nil {
+		return nil, fmt.Errorf("open database failed: %w", err)
+	}
+	if err := store.Migrate(ins); err != nil {
+		return nil, fmt.Errorf("migrate database failed: %w", err)
+	}
+	return store.NewStore(ins), nil
+}
+
+// initStorage 读取 storage 配置，初始化图片文件的存储后端.
+func initStorage() error {
+	opts := &storage.Options{
+		Backend:  viper.GetString("storage.backend"),
+		LocalDir: viper.GetString("image_dir"),
+		S3: storage.S3Options{
+			Endpoint:  viper.GetString("storage.s3.endpoint"),
+			Bucket:    viper.GetString("storage.s3.bucket"),
+			AccessKey: viper.GetString("storage.s3.access-key"),
+			SecretKey: viper.GetString("storage.s3.secret-key"),
+			Region:    viper.GetString("storage.s3.region"),
+			UseSSL:    viper.GetBool("storage.s3.use-ssl"),
+			PathStyle: viper.GetBool("storage.s3.path-style"),
+		},
+	}
+	if err := storage.Init(opts); err != nil {
+		return fmt.Errorf("init storage failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package demo520
+
+import (
+	"demo520/internal/520/controller/album"
+	"demo520/internal/520/controller/image"
+	"demo520/internal/520/controller/tag"
+	"demo520/internal/520/controller/user"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// installRouters 安装 520 接口路由.
+func installRouters(g *gin.Engine, ds store.IStore) error {
+	// 注册 404 Handler.
+	g.NoRoute(func(c *gin.Context) {
+		core.WriteResponse(c, errno.ErrPageNotFound, nil)
+	})
+
+	// 注册 /healthz handler.
+	g.GET("/healthz", func(c *gin.Context) {
+		log.C(c).Infow("Healthz function called")
+
+		core.WriteResponse(c, nil, map[string]string{"status": "ok"})
+	})
+
+	uc := user.NewUserController(ds)
+	ic := image.NewUserController(ds)
+	ac := album.NewAlbumController(ds)
+	tc := tag.NewTagController(ds)
+
+	// 分享链接无需认证，持有令牌即可读取图片
+	g.GET("/s/:token", ic.GetShared)
+	g.GET("/s/:token/file", ic.GetSharedFile)
+
+	// 创建 v1 路由分组
+	v1 := g.Group("/v1")
+	{
+		v1.POST("/login", uc.Login)
+
+		// 创建 users 路由分组
+		userv1 := v1.Group("/users")
+		{
+			userv1.POST("", uc.Create)
+			userv1.GET(":email", uc.Get)
+		}
+		authUserv1 := v1.Group("/users", middleware.Authn())
+		{
+			authUserv1.PUT(":email", uc.Update)
+			authUserv1.PUT(":email/change-password", uc.ChangePassword)
+
+			// 当前用户的收藏夹
+			authUserv1.GET("me/favorites", ic.ListFavorites)
+			authUserv1.POST("me/favorites", ic.AddFavorite)
+			authUserv1.DELETE("me/favorites/:imageUUID", ic.RemoveFavorite)
+		}
+
+		// 创建 images 路由分组，公开接口无需认证
+		imagev1 := v1.Group("/images")
+		{
+			imagev1.GET("", ic.GetPublicList)
+			imagev1.GET("users/:userUUID", ic.GetUserPublicList)
+		}
+		// 可选认证：匿名用户只能读取公开图片，登录用户还能读取自己的私有图片
+		optAuthImagev1 := v1.Group("/images", middleware.OptionalAuthn())
+		{
+			optAuthImagev1.GET("search", ic.Search)
+			optAuthImagev1.GET(":imageUUID", ic.Get)
+			optAuthImagev1.GET(":imageUUID/file", ic.GetFile)
+			optAuthImagev1.GET(":imageUUID/similar", ic.Similar)
+		}
+		// 需要认证的图片接口
+		authImagev1 := v1.Group("/images", middleware.Authn())
+		{
+			authImagev1.POST("", ic.Create)
+			authImagev1.GET("mine", ic.GetUserImagesList)
+			authImagev1.DELETE("", ic.DeleteCollection)
+			authImagev1.POST("bulk", ic.BulkEdit)
+			authImagev1.GET("bulk/:jobID", ic.GetBulkJob)
+			authImagev1.PATCH(":imageUUID", ic.Patch)
+			authImagev1.DELETE(":imageUUID", ic.DeleteImage)
+			authImagev1.POST(":imageUUID/tags", ic.UpdateImageTags)
+			authImagev1.PUT(":imageUUID/tags", ic.ReplaceImageTags)
+			authImagev1.DELETE(":imageUUID/tags", ic.DeleteImageTags)
+			authImagev1.POST(":imageUUID/share", ic.CreateShare)
+			authImagev1.GET(":imageUUID/share", ic.GetShare)
+			authImagev1.DELETE(":imageUUID/share", ic.RevokeShare)
+		}
+
+		// 创建 albums 路由分组，可选认证：非所有者只能读取公开合集及其中的公开图片
+		optAuthAlbumv1 := v1.Group("/albums", middleware.OptionalAuthn())
+		{
+			optAuthAlbumv1.GET(":albumUUID", ac.Get)
+			optAuthAlbumv1.GET(":albumUUID/images", ac.ListImages)
+			optAuthAlbumv1.GET("users/:userUUID", ac.ListUserAlbums)
+		}
+		// 需要认证的合集接口，只有合集所有者可以修改合集
+		authAlbumv1 := v1.Group("/albums", middleware.Authn())
+		{
+			authAlbumv1.POST("", ac.Create)
+			authAlbumv1.GET("mine", ac.ListMine)
+			authAlbumv1.PATCH(":albumUUID", ac.Update)
+			authAlbumv1.DELETE(":albumUUID", ac.Delete)
+			authAlbumv1.POST(":albumUUID/images", ac.AddImages)
+			authAlbumv1.DELETE(":albumUUID/images/:imageUUID", ac.RemoveImage)
+			authAlbumv1.PUT(":albumUUID/order", ac.Reorder)
+		}
+
+		// 标签目录，只统计公开图片，不需要认证
+		tagv1 := v1.Group("/tags")
+		{
+			tagv1.GET("", tc.List)
+			tagv1.GET("popular", tc.ListPopular)
+		}
+
+		// 管理员接口，admins 配置项中列出的用户才能访问
+		adminv1 := v1.Group("/admin", middleware.Authn(), middleware.RequireAdmin(viper.GetStringSlice("admins")))
+		{
+			adminv1.GET("tags/aliases", tc.ListAliases)
+			adminv1.POST("tags/aliases", tc.SetAlias)
+			adminv1.DELETE("tags/aliases", tc.DeleteAlias)
+			adminv1.GET("tags/implications", tc.ListImplications)
+			adminv1.POST("tags/implications", tc.AddImplication)
+			adminv1.DELETE("tags/implications", tc.DeleteImplication)
+			adminv1.GET("tags/jobs/:jobID", tc.GetRewriteJob)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AlbumStore interface {
+	Create(ctx context.Context, album *model.AlbumM) error
+	Get(ctx context.Context, albumUUID string) (*model.AlbumM, error)
+	Update(ctx context.Context, albumUUID string, updates map[string]interface{}) error
+	Delete(ctx context.Context, albumUUID string) error
+	ListByUser(ctx context.Context, userUUID string, publicOnly bool, offset, limit int) (int64, []*model.AlbumM, error)
+	ListByUserAfter(ctx context.Context, userUUID string, publicOnly bool, after *AlbumCursor, limit int) ([]*model.AlbumM, error)
+	CountByUser(ctx context.Context, userUUID string, publicOnly bool) (int64, error)
+	AddImages(ctx context.Context, albumUUID string, imageUUIDs []string, maxImages int) error
+	RemoveImage(ctx context.Context, albumUUID string, imageUUID string) error
+	Reorder(ctx context.Context, albumUUID string, imageUUIDs []string) error
+	ListVisibleImageUUIDs(ctx context.Context, albumUUID string, userUUID string, offset, limit int) (int64, []string, error)
+	ListVisibleImagesAfter(ctx context.Context, albumUUID string, userUUID string, after *ImageCursor, limit int) ([]*model.AlbumImageM, error)
+	CountVisibleImages(ctx context.Context, albumUUID string, userUUID string) (int64, error)
+}
+
+type albumStore struct {
+	db *gorm.DB
+}
+
+var _ AlbumStore = (*albumStore)(nil)
+
+func newAlbumStore(db *gorm.DB) AlbumStore {
+	return &albumStore{
+		db: db,
+	}
+}
+
+func (a *albumStore) Create(ctx context.Context, album *model.AlbumM) error {
+	return a.db.WithContext(ctx).Create(album).Error
+}
+
+func (a *albumStore) Get(ctx context.Context, albumUUID string) (*model.AlbumM, error) {
+	var album model.AlbumM
+	if err := a.db.WithContext(ctx).Where("albumUUID = ?", albumUUID).First(&album).Error; err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+// Update 更新合集的指定列，updates 的键为列名.
+func (a *albumStore) Update(ctx context.Context, albumUUID string, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	return a.db.WithContext(ctx).Model(&model.AlbumM{}).Where("albumUUID = ?", albumUUID).Updates(updates).Error
+}
+
+// Delete 软删除合集并删除其全部图片记录，图片本身不受影响.
+func (a *albumStore) Delete(ctx context.Context, albumUUID string) error {
+	return a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("albumUUID = ?", albumUUID).Delete(&model.AlbumImageM{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("albumUUID = ?", albumUUID).Delete(&model.AlbumM{}).Error
+	})
+}
+
+// userAlbums 返回 userUUID 的合集的查询，publicOnly 为 true 时只包括公开合集.
+func (a *albumStore) userAlbums(ctx context.Context, userUUID string, publicOnly bool) *gorm.DB {
+	tx := a.db.WithContext(ctx).Model(&model.AlbumM{}).Where("userUUID = ?", userUUID)
+	if publicOnly {
+		tx = tx.Where("is_public = ?", true)
+	}
+	return tx
+}
+
+// ListByUser 按创建时间倒序列出 userUUID 的合集，publicOnly 为 true 时只列出公开合集.
+func (a *albumStore) ListByUser(ctx context.Context, userUUID string, publicOnly bool, offset, limit int) (int64, []*model.AlbumM, error) {
+	tx := a.userAlbums(ctx, userUUID, publicOnly).Session(&gorm.Session{})
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	var ret []*model.AlbumM
+	if count == 0 {
+		return 0, ret, nil
+	}
+	err := tx.Order("created_at DESC").Order("albumUUID DESC").Offset(offset).Limit(limit).Find(&ret).Error
+	return count, ret, err
+}
+
+// AlbumCursor 是合集列表游标分页时上一页最后一个合集的位置.
+type AlbumCursor struct {
+	Time      time.Time
+	AlbumUUID string
+}
+
+// ListByUserAfter 按创建时间倒序列出 after 之后 userUUID 的最多 limit 个合集，after 为 nil 时从第一个开始.
+// publicOnly 为 true 时只列出公开合集.
+func (a *albumStore) ListByUserAfter(ctx context.Context, userUUID string, publicOnly bool, after *AlbumCursor, limit int) ([]*model.AlbumM, error) {
+	tx := a.userAlbums(ctx, userUUID, publicOnly)
+	if after != nil {
+		tx = tx.Where("(created_at < ? OR (created_at = ? AND albumUUID < ?))", after.Time, after.Time, after.AlbumUUID)
+	}
+	var ret []*model.AlbumM
+	err := tx.Order("created_at DESC").Order("albumUUID DESC").Limit(limit).Find(&ret).Error
+	return ret, err
+}
+
+// CountByUser 返回 userUUID 的合集数，publicOnly 为 true 时只统计公开合集.
+func (a *albumStore) CountByUser(ctx context.Context, userUUID string, publicOnly bool) (int64, error) {
+	var count int64
+	err := a.userAlbums(ctx, userUUID, publicOnly).Count(&count).Error
+	return count, err
+}
+
+// lockAlbum 在事务中锁定合集记录，使同一合集的成员变更串行执行.
+func lockAlbum(tx *gorm.DB, albumUUID string) error {
+	var album model.AlbumM
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("albumUUID = ?", albumUUID).First(&album).Error
+}
+
+// AddImages 按顺序将图片追加到合集末尾，已在合集中的图片保持原位置.
+// 追加后合集的图片数超过 maxImages 时不做任何修改并返回 errno.ErrAlbumFull.
+func (a *albumStore) AddImages(ctx context.Context, albumUUID string, imageUUIDs []string, maxImages int) error {
+	return a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAlbum(tx, albumUUID); err != nil {
+			return err
+		}
+		var existing []string
+		if err := tx.Model(&model.AlbumImageM{}).Where("albumUUID = ?", albumUUID).Pluck("imageUUID", &existing).Error; err != nil {
+			return err
+		}
+		var maxPosition int
+		if err := tx.Model(&model.AlbumImageM{}).Where("albumUUID = ?", albumUUID).
+			Select("COALESCE(MAX(position), 0)").Scan(&maxPosition).Error; err != nil {
+			return err
+		}
+
+		var added []model.AlbumImageM
+		for _, imageUUID := range imageUUIDs {
+			if slices.Contains(existing, imageUUID) {
+				continue
+			}
+			existing = append(existing, imageUUID)
+			maxPosition++
+			added = append(added, model.AlbumImageM{AlbumUUID: albumUUID, ImageUUID: imageUUID, Position: maxPosition})
+		}
+		if len(existing) > maxImages {
+			return fmt.Errorf("%w: at most %d images", errno.ErrAlbumFull, maxImages)
+		}
+		if len(added) == 0 {
+			return nil
+		}
+		return tx.Create(&added).Error
+	})
+}
+
+// RemoveImage 从合集中移除图片，图片不在合集中时不会报错.
+func (a *albumStore) RemoveImage(ctx context.Context, albumUUID string, imageUUID string) error {
+	return a.db.WithContext(ctx).Where("albumUUID = ? AND imageUUID = ?", albumUUID, imageUUID).Delete(&model.AlbumImageM{}).Error
+}
+
+// Reorder 按 imageUUIDs 的顺序重新排列合集. imageUUIDs 必须恰好包含合集中的每张图片一次，
+// 否则返回 errno.ErrAlbumOrderMismatch.
+func (a *albumStore) Reorder(ctx context.Context, albumUUID string, imageUUIDs []string) error {
+	return a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAlbum(tx, albumUUID); err != nil {
+			return err
+		}
+		var existing []string
+		if err := tx.Model(&model.AlbumImageM{}).Where("albumUUID = ?", albumUUID).Pluck("imageUUID", &existing).Error; err != nil {
+			return err
+		}
+		sorted := slices.Clone(imageUUIDs)
+		slices.Sort(sorted)
+		slices.Sort(existing)
+		if !slices.Equal(sorted, existing) {
+			return errno.ErrAlbumOrderMismatch
+		}
+		for i, imageUUID := range imageUUIDs {
+			if err := tx.Model(&model.AlbumImageM{}).
+				Where("albumUUID = ? AND imageUUID = ?", albumUUID, imageUUID).
+				Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// albumImageSort 按合集中的顺序排列图片，游标比较 Position.
+var albumImageSort = imageSort{key: "album_images.position", cursorValue: func(c *ImageCursor) interface{} { return c.Position }}
+
+// visibleAlbumImages 返回合集中 userUUID 可见的图片的查询.
+// 其他用户的私有图片和已删除的图片不可见，不公开列出的图片由合集所有者选入，同样可见.
+func (a *albumStore) visibleAlbumImages(ctx context.Context, albumUUID string, userUUID string) *gorm.DB {
+	return a.db.WithContext(ctx).Model(&model.ImageM{}).
+		Joins("JOIN album_images ON album_images.imageUUID = images.imageUUID").
+		Where("album_images.albumUUID = ?", albumUUID).
+		Scopes(accessibleImages(userUUID))
+}
+
+// ListVisibleImageUUIDs 按合集中的顺序列出 userUUID 可见的图片，返回可见图片的总数.
+func (a *albumStore) ListVisibleImageUUIDs(ctx context.Context, albumUUID string, userUUID string, offset, limit int) (int64, []string, error) {
+	// 同一组条件要分别用于 Count 和 Pluck
+	tx := a.visibleAlbumImages(ctx, albumUUID, userUUID).Session(&gorm.Session{})
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	var ret []string
+	if count == 0 {
+		return 0, ret, nil
+	}
+	err := albumImageSort.order(tx).Offset(offset).Limit(limit).Pluck("images.imageUUID", &ret).Error
+	return count, ret, err
+}
+
+// ListVisibleImagesAfter 按合集中的顺序列出 after 之后 userUUID 可见的最多 limit 张图片，after 为 nil 时从第一张开始.
+// 返回合集的图片记录以便调用方用位置生成游标.
+func (a *albumStore) ListVisibleImagesAfter(ctx context.Context, albumUUID string, userUUID string, after *ImageCursor, limit int) ([]*model.AlbumImageM, error) {
+	tx := a.visibleAlbumImages(ctx, albumUUID, userUUID)
+	if after != nil {
+		tx = albumImageSort.after(tx, after)
+	}
+	var ret []*model.AlbumImageM
+	err := albumImageSort.order(tx.Select("album_images.*")).Limit(limit).Find(&ret).Error
+	return ret, err
+}
+
+// CountVisibleImages 返回合集中 userUUID 可见的图片数.
+func (a *albumStore) CountVisibleImages(ctx context.Context, albumUUID string, userUUID string) (int64, error) {
+	var count int64
+	err := a.visibleAlbumImages(ctx, albumUUID, userUUID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"demo520/internal/pkg/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BlobStore interface {
+	Touch(ctx context.Context, hash string) error
+	ListUnreferenced(ctx context.Context, afterHash string, recoverableAfter, touchedBefore time.Time, limit int) ([]*model.BlobM, error)
+	DeleteIfUnreferenced(ctx context.Context, hash string, recoverableAfter, touchedBefore time.Time, removeFiles func() error) (bool, error)
+}
+
+type blobStore struct {
+	db *gorm.DB
+}
+
+var _ BlobStore = (*blobStore)(nil)
+
+func newBlobStore(db *gorm.DB) BlobStore {
+	return &blobStore{
+		db: db,
+	}
+}
+
+// unreferencedSQL 判断 blob 没有被任何存活或仍可恢复（软删除时间晚于给定时间）的图片引用.
+const unreferencedSQL = "NOT EXISTS (SELECT 1 FROM images WHERE images.hash = blobs.hash AND (images.deleted_at IS NULL OR images.deleted_at > ?))"
+
+// Touch 创建 hash 对应的 blob 记录，已存在时刷新其更新时间.
+// 如果垃圾回收正持有该行的锁，Touch 会阻塞到回收结束，调用方随后需要重新检查文件是否存在.
+func (b *blobStore) Touch(ctx context.Context, hash string) error {
+	return b.db.WithContext(ctx).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at"}),
+	}).Create(&model.BlobM{Hash: hash}).Error
+}
+
+// ListUnreferenced 按 hash 顺序列出 afterHash 之后、在 touchedBefore 之前更新且未被引用的 blob.
+func (b *blobStore) ListUnreferenced(ctx context.Context, afterHash string, recoverableAfter, touchedBefore time.Time, limit int) ([]*model.BlobM, error) {
+	var blobs []*model.BlobM
+	err := b.db.WithContext(ctx).Model(&model.BlobM{}).
+		Where("hash > ? AND updated_at < ?", afterHash, touchedBefore).
+		Where(unreferencedSQL, recoverableAfter).
+		Order("hash").Limit(limit).Find(&blobs).Error
+	return blobs, err
+}
+
+// DeleteIfUnreferenced 在事务中锁定 blob 记录并重新确认它未被引用，随后清除已过恢复期的软删除图片记录，
+// 调用 removeFiles 删除文件并删除 blob 记录及其转换任务. 返回值表示 blob 是否被回收.
+func (b *blobStore) DeleteIfUnreferenced(ctx context.Context, hash string, recoverableAfter, touchedBefore time.Time, removeFiles func() error) (bool, error) {
+	deleted := false
+	err := b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var blob model.BlobM
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&blob, "hash = ?", hash).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		// 最近有同 hash 的上传，图片记录可能尚未写入
+		if !blob.UpdatedAt.Before(touchedBefore) {
+			return nil
+		}
+		var refs int64
+		if err := tx.Unscoped().Model(&model.ImageM{}).
+			Where("hash = ? AND (deleted_at IS NULL OR deleted_at > ?)", hash, recoverableAfter).
+			Count(&refs).Error; err != nil {
+			return err
+		}
+		if refs > 0 {
+			return nil
+		}
+
+		// 清除已经无法恢复的软删除图片及其标签、收藏、合集和分享访问记录
+		var expired []string
+		if err := tx.Unscoped().Model(&model.ImageM{}).Where("hash = ?", hash).Pluck("imageUUID", &expired).Error; err != nil {
+			return err
+		}
+		if len(expired) > 0 {
+			if err := tx.Unscoped().Where("imageUUID IN ?", expired).Delete(&model.ImageTagM{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("imageUUID IN ?", expired).Delete(&model.FavoriteM{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("imageUUID IN ?", expired).Delete(&model.AlbumImageM{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("imageUUID IN ?", expired).Delete(&model.ShareAccessM{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("imageUUID IN ?", expired).Delete(&model.ImageM{}).Error; err != nil {
+				return err
+			}
+		}
+
+		// 持有行锁期间删除文件，同 hash 的上传会在 Touch 处等待
+		if err := removeFiles(); err != nil {
+			return err
+		}
+		if err := tx.Where("hash = ?", hash).Delete(&model.ConvertJobM{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&blob).Error; err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
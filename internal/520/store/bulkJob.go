@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"demo520/internal/pkg/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BulkJobStore interface {
+	Create(ctx context.Context, job *model.BulkJobM) error
+	Get(ctx context.Context, id uint) (*model.BulkJobM, error)
+	Claim(ctx context.Context, lease time.Duration) (*model.BulkJobM, error)
+	Update(ctx context.Context, job *model.BulkJobM) error
+}
+
+type bulkJobStore struct {
+	db *gorm.DB
+}
+
+var _ BulkJobStore = (*bulkJobStore)(nil)
+
+func newBulkJobStore(db *gorm.DB) BulkJobStore {
+	return &bulkJobStore{
+		db: db,
+	}
+}
+
+// Create 保存一个待执行的任务.
+func (s *bulkJobStore) Create(ctx context.Context, job *model.BulkJobM) error {
+	job.Status = model.BulkJobPending
+	job.NextRunAt = time.Now()
+	return s.db.WithContext(ctx).Create(job).Error
+}
+
+func (s *bulkJobStore) Get(ctx context.Context, id uint) (*model.BulkJobM, error) {
+	var job model.BulkJobM
+	err := s.db.WithContext(ctx).First(&job, id).Error
+	return &job, err
+}
+
+// Claim 领取一个到期的任务，将其标记为 processing 并把租约设置为 lease 之后过期.
+// 没有可领取的任务时返回 gorm.ErrRecordNotFound.
+func (s *bulkJobStore) Claim(ctx context.Context, lease time.Duration) (*model.BulkJobM, error) {
+	var job model.BulkJobM
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_run_at <= ?", []string{model.BulkJobPending, model.BulkJobProcessing}, now).
+			Order("next_run_at").First(&job).Error; err != nil {
+			return err
+		}
+		job.Status = model.BulkJobProcessing
+		job.Attempts++
+		job.NextRunAt = now.Add(lease)
+		return tx.Model(&job).Select("status", "attempts", "next_run_at").Updates(&job).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Update 保存任务的进度和执行结果.
+func (s *bulkJobStore) Update(ctx context.Context, job *model.BulkJobM) error {
+	return s.db.WithContext(ctx).Model(job).
+		Select("status", "attempts", "last_error", "last_image_uuid", "processed", "skipped", "next_run_at").Updates(job).Error
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"demo520/internal/pkg/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ConvertJobStore interface {
+	Enqueue(ctx context.Context, hash string, formats []string) error
+	Claim(ctx context.Context, lease time.Duration) (*model.ConvertJobM, error)
+	Update(ctx context.Context, job *model.ConvertJobM) error
+	ListByHashes(ctx context.Context, hashes []string) ([]*model.ConvertJobM, error)
+	ListReadyWithoutSize(ctx context.Context, afterID uint, limit int) ([]*model.ConvertJobM, error)
+}
+
+type convertJobStore struct {
+	db *gorm.DB
+}
+
+var _ ConvertJobStore = (*convertJobStore)(nil)
+
+func newConvertJobStore(db *gorm.DB) ConvertJobStore {
+	return &convertJobStore{
+		db: db,
+	}
+}
+
+// Enqueue 为 hash 的每种格式创建一个待执行的转换任务. 已失败的任务重置为待执行，
+// 使重新上传同一图片时可以重试转换，待执行、执行中和已完成的任务保持不变.
+func (c *convertJobStore) Enqueue(ctx context.Context, hash string, formats []string) error {
+	now := time.Now()
+	jobs := make([]model.ConvertJobM, len(formats))
+	for i, format := range formats {
+		jobs[i] = model.ConvertJobM{
+			Hash:      hash,
+			Format:    format,
+			Status:    model.ConvertJobPending,
+			NextRunAt: now,
+		}
+	}
+	// MySQL 按顺序执行赋值，status 必须最后修改，前面的条件才能看到原来的状态
+	retry := func(column string) clause.Assignment {
+		return clause.Assignment{
+			Column: clause.Column{Name: column},
+			Value:  gorm.Expr("IF(status = ?, VALUES("+column+"), "+column+")", model.ConvertJobFailed),
+		}
+	}
+	return c.db.WithContext(ctx).Clauses(clause.OnConflict{DoUpdates: clause.Set{
+		retry("attempts"),
+		retry("last_error"),
+		retry("next_run_at"),
+		retry("updated_at"),
+		retry("status"),
+	}}).Create(&jobs).Error
+}
+
+// Claim 领取一个到期的任务，将其标记为 processing 并把租约设置为 lease 之后过期.
+// 没有可领取的任务时返回 gorm.ErrRecordNotFound. 多个节点同时领取时通过 SKIP LOCKED 互不阻塞.
+func (c *convertJobStore) Claim(ctx context.Context, lease time.Duration) (*model.ConvertJobM, error) {
+	var job model.ConvertJobM
+	err := c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_run_at <= ?", []string{model.ConvertJobPending, model.ConvertJobProcessing}, now).
+			Order("next_run_at").First(&job).Error; err != nil {
+			return err
+		}
+		job.Status = model.ConvertJobProcessing
+		job.Attempts++
+		job.NextRunAt = now.Add(lease)
+		return tx.Model(&job).Select("status", "attempts", "next_run_at").Updates(&job).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Update 保存任务的执行结果.
+func (c *convertJobStore) Update(ctx context.Context, job *model.ConvertJobM) error {
+	return c.db.WithContext(ctx).Model(job).
+		Select("status", "attempts", "last_error", "next_run_at", "size").Updates(job).Error
+}
+
+// ListByHashes 列出 hashes 对应的全部转换任务.
+func (c *convertJobStore) ListByHashes(ctx context.Context, hashes []string) ([]*model.ConvertJobM, error) {
+	var jobs []*model.ConvertJobM
+	if len(hashes) == 0 {
+		return jobs, nil
+	}
+	err := c.db.WithContext(ctx).Where("hash IN ?", hashes).Find(&jobs).Error
+	return jobs, err
+}
+
+// ListReadyWithoutSize 按 ID 顺序列出 afterID 之后已完成但未记录文件大小的任务.
+func (c *convertJobStore) ListReadyWithoutSize(ctx context.Context, afterID uint, limit int) ([]*model.ConvertJobM, error) {
+	var jobs []*model.ConvertJobM
+	err := c.db.WithContext(ctx).Where("id > ? AND status = ? AND size = 0", afterID, model.ConvertJobReady).
+		Order("id").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
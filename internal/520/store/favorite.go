@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"demo520/internal/pkg/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FavoriteStore interface {
+	Add(ctx context.Context, userUUID string, imageUUID string) error
+	Remove(ctx context.Context, userUUID string, imageUUID string) error
+	ListVisibleImages(ctx context.Context, userUUID string, offset, limit int) (int64, []*model.ImageM, error)
+	ListVisibleAfter(ctx context.Context, userUUID string, after *ImageCursor, limit int) ([]*model.FavoriteM, error)
+	CountVisible(ctx context.Context, userUUID string) (int64, error)
+	CountByImages(ctx context.Context, imageUUIDs []string) (map[string]int64, error)
+}
+
+type favoriteStore struct {
+	db *gorm.DB
+}
+
+var _ FavoriteStore = (*favoriteStore)(nil)
+
+func newFavoriteStore(db *gorm.DB) FavoriteStore {
+	return &favoriteStore{
+		db: db,
+	}
+}
+
+// Add 收藏图片，重复收藏不会报错.
+func (f *favoriteStore) Add(ctx context.Context, userUUID string, imageUUID string) error {
+	return f.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.FavoriteM{UserUUID: userUUID, ImageUUID: imageUUID}).Error
+}
+
+// Remove 取消收藏，未收藏时不会报错.
+func (f *favoriteStore) Remove(ctx context.Context, userUUID string, imageUUID string) error {
+	return f.db.WithContext(ctx).Delete(&model.FavoriteM{}, "userUUID = ? AND imageUUID = ?", userUUID, imageUUID).Error
+}
+
+// favoriteSort 按收藏时间倒序排列收藏的图片，游标比较 Time.
+var favoriteSort = imageSort{key: "favorites.created_at", desc: true, cursorValue: cursorTime}
+
+// visibleFavorites 返回 userUUID 收藏且当前仍可见的图片的查询.
+func (f *favoriteStore) visibleFavorites(ctx context.Context, userUUID string) *gorm.DB {
+	return f.db.WithContext(ctx).Model(&model.ImageM{}).
+		Joins("JOIN favorites ON favorites.imageUUID = images.imageUUID").
+		Where("favorites.userUUID = ?", userUUID).
+		Scopes(accessibleImages(userUUID))
+}
+
+// ListVisibleImages 按收藏时间倒序列出 userUUID 收藏且当前仍可见的图片，返回可见收藏的总数.
+// 收藏后被删除或被所有者设为私有的图片不会出现在结果中.
+func (f *favoriteStore) ListVisibleImages(ctx context.Context, userUUID string, offset, limit int) (int64, []*model.ImageM, error) {
+	// 同一组条件要分别用于 Count 和 Find
+	tx := f.visibleFavorites(ctx, userUUID).Session(&gorm.Session{})
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	var ret []*model.ImageM
+	if count == 0 {
+		return 0, ret, nil
+	}
+	err := favoriteSort.order(tx).Preload("Tags").Offset(offset).Limit(limit).Find(&ret).Error
+	return count, ret, err
+}
+
+// ListVisibleAfter 按收藏时间倒序列出 after 之后 userUUID 收藏且当前仍可见的最多 limit 条收藏，
+// after 为 nil 时从第一条开始. 返回收藏记录以便调用方用收藏时间生成游标.
+func (f *favoriteStore) ListVisibleAfter(ctx context.Context, userUUID string, after *ImageCursor, limit int) ([]*model.FavoriteM, error) {
+	tx := f.visibleFavorites(ctx, userUUID)
+	if after != nil {
+		tx = favoriteSort.after(tx, after)
+	}
+	var ret []*model.FavoriteM
+	err := favoriteSort.order(tx.Select("favorites.*")).Limit(limit).Find(&ret).Error
+	return ret, err
+}
+
+// CountVisible 返回 userUUID 收藏且当前仍可见的图片数.
+func (f *favoriteStore) CountVisible(ctx context.Context, userUUID string) (int64, error) {
+	var count int64
+	err := f.visibleFavorites(ctx, userUUID).Count(&count).Error
+	return count, err
+}
+
+// CountByImages 返回 imageUUIDs 中每张图片被收藏的次数，没有被收藏的图片不在结果中.
+func (f *favoriteStore) CountByImages(ctx context.Context, imageUUIDs []string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(imageUUIDs))
+	if len(imageUUIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		ImageUUID string `gorm:"column:imageUUID"`
+		Count     int64  `gorm:"column:count"`
+	}
+	if err := f.db.WithContext(ctx).Model(&model.FavoriteM{}).
+		Select("imageUUID, COUNT(*) AS count").
+		Where("imageUUID IN ?", imageUUIDs).
+		Group("imageUUID").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.ImageUUID] = row.Count
+	}
+	return counts, nil
+}
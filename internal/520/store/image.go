@@ -4,9 +4,13 @@ import (
 	"context"
 	"demo520/internal/pkg/errno"
 	"demo520/internal/pkg/model"
+	"demo520/internal/pkg/tagquery"
 	"errors"
 	"fmt"
 	"math/rand"
+	"slices"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -15,11 +19,53 @@ import (
 type ImageStore interface {
 	Create(ctx context.Context, image *model.ImageM) error
 	Get(ctx context.Context, imageUUID string) (*model.ImageM, error)
+	Update(ctx context.Context, imageUUID string, updates map[string]interface{}) error
+	UpdateByHash(ctx context.Context, hash string, updates map[string]interface{}) error
+	ListHashesWithoutProperties(ctx context.Context, afterHash string, limit int) ([]string, error)
 	Delete(ctx context.Context, imageUUID string) error
-	AddTagsToImage(ctx context.Context, imageUUID string, tags []string) error
+	DeleteCollection(ctx context.Context, userUUID string, imageUUIDs []string) (map[string]string, error)
+	BulkEdit(ctx context.Context, userUUID string, imageUUIDs []string, edit *BulkImageEdit) ([]string, error)
+	ListOwnUUIDsMatching(ctx context.Context, userUUID string, q *tagquery.Query, afterUUID string, limit int) ([]string, error)
+	CountOwnMatching(ctx context.Context, userUUID string, q *tagquery.Query) (int64, error)
+	AddTagsToImage(ctx context.Context, imageUUID string, tags []string, maxTags int) error
 	DeleteTagFromImage(ctx context.Context, imageUUID string, tag []string) error
+	ReplaceTags(ctx context.Context, imageUUID string, tags []string, maxTags int) error
+	ReapplyTagRules(ctx context.Context, imageUUID string) error
+	ListUUIDsWithTag(ctx context.Context, tag string, afterUUID string, limit int) ([]string, error)
 	GetUserImages(ctx context.Context, UserUUID string, offset, limit int) (int64, []*model.ImageM, error)
+	GetUserPublicImages(ctx context.Context, userUUID string, offset, limit int) (int64, []*model.ImageM, error)
+	ListUserImages(ctx context.Context, userUUID string, publicOnly bool, opts *ImageListOptions, offset, limit int) (int64, []*model.ImageM, error)
+	ListUserImagesAfter(ctx context.Context, userUUID string, publicOnly bool, opts *ImageListOptions, after *ImageCursor, limit int) ([]*model.ImageM, error)
+	CountUserImages(ctx context.Context, userUUID string, publicOnly bool, opts *ImageListOptions) (int64, error)
 	GetRandomPublicImages(ctx context.Context, limit int) (int, []*model.ImageM, error)
+	Search(ctx context.Context, userUUID string, q *tagquery.Query, offset, limit int) (int64, []*model.ImageM, error)
+	SearchAfter(ctx context.Context, userUUID string, q *tagquery.Query, after *ImageCursor, limit int) ([]*model.ImageM, error)
+	CountSearch(ctx context.Context, userUUID string, q *tagquery.Query) (int64, error)
+	ListVisibleByUUIDs(ctx context.Context, userUUID string, imageUUIDs []string) ([]*model.ImageM, error)
+	ListSimilar(ctx context.Context, userUUID string, phash uint64, maxDistance, limit int) ([]*model.ImageM, error)
+	ListOwnSimilar(ctx context.Context, userUUID string, phash uint64, maxDistance, limit int) ([]*model.ImageM, error)
+}
+
+// listedImages 限定为 userUUID 在列表、搜索中能看到的图片：公开图片和自己的全部图片.
+// 不公开列出的图片只能通过直接链接访问，userUUID 为空时只包括公开图片.
+func listedImages(userUUID string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if userUUID == "" {
+			return tx.Where("images.visibility = ?", model.VisibilityPublic)
+		}
+		return tx.Where("(images.visibility = ? OR images.userUUID = ?)", model.VisibilityPublic, userUUID)
+	}
+}
+
+// accessibleImages 限定为 userUUID 能通过直接链接访问的图片：公开和不公开列出的图片以及自己的全部图片.
+func accessibleImages(userUUID string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		visible := []string{model.VisibilityPublic, model.VisibilityUnlisted}
+		if userUUID == "" {
+			return tx.Where("images.visibility IN ?", visible)
+		}
+		return tx.Where("(images.visibility IN ? OR images.userUUID = ?)", visible, userUUID)
+	}
 }
 
 type imageStore struct {
@@ -35,87 +81,447 @@ func newImageStore(db *gorm.DB) ImageStore {
 }
 
 func (u *imageStore) Create(ctx context.Context, image *model.ImageM) error {
-	return u.db.Create(image).Error
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(image).Error; err != nil {
+			return err
+		}
+		if image.Visibility != model.VisibilityPublic {
+			return nil
+		}
+		return adjustTagCounts(tx, image.Tags, 1)
+	})
 }
 
 func (u *imageStore) Get(ctx context.Context, imageUUID string) (*model.ImageM, error) {
 	var image model.ImageM
-	err := u.db.Preload("Tags").First(&image, "imageUUID = ?", imageUUID).Error
+	err := u.db.WithContext(ctx).Preload("Tags").First(&image, "imageUUID = ?", imageUUID).Error
 	return &image, err
 }
 
-func (u *imageStore) Delete(ctx context.Context, imageUUID string) error {
-	err := u.db.Delete(&model.ImageM{}, "imageUUID = ?", imageUUID).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
-		}
-		return err
+// Update 更新图片的指定列，updates 的键为列名，值为零值时同样会被写入.
+// 图片因修改 visibility 公开或取消公开时，同步更新其标签的公开图片数.
+func (u *imageStore) Update(ctx context.Context, imageUUID string, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
 	}
-	u.db.Delete(&model.ImageTagM{}, "imageUUID = ?", imageUUID)
-	return nil
+	visibility, ok := updates["visibility"]
+	if !ok {
+		return u.db.WithContext(ctx).Model(&model.ImageM{}).Where("imageUUID = ?", imageUUID).Updates(updates).Error
+	}
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		image, err := lockImage(tx, imageUUID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&model.ImageM{}).Where("imageUUID = ?", imageUUID).Updates(updates).Error; err != nil {
+			return err
+		}
+		v, _ := visibility.(string)
+		return visibilityChanged(tx, image, v)
+	})
 }
 
-func (u *imageStore) AddTagsToImage(ctx context.Context, imageUUID string, tags []string) error {
-	if len(tags) == 0 {
+// UpdateByHash 更新 hash 相同的全部图片（包括已软删除的图片）的指定列.
+func (u *imageStore) UpdateByHash(ctx context.Context, hash string, updates map[string]interface{}) error {
+	if len(updates) == 0 {
 		return nil
 	}
-	err := u.db.Transaction(func(tx *gorm.DB) error {
-		var image model.ImageM
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&image, "imageUUID = ?", imageUUID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return fmt.Errorf("%w: image not found", errno.ErrImageNotFound)
+	return u.db.WithContext(ctx).Unscoped().Model(&model.ImageM{}).Where("hash = ?", hash).Updates(updates).Error
+}
+
+// ListHashesWithoutProperties 按 hash 顺序列出 afterHash 之后尚未记录固有属性或差异哈希的图片 hash，包括已软删除的图片.
+func (u *imageStore) ListHashesWithoutProperties(ctx context.Context, afterHash string, limit int) ([]string, error) {
+	var hashes []string
+	err := u.db.WithContext(ctx).Unscoped().Model(&model.ImageM{}).
+		Where("hash > ? AND (mime_type = '' OR phash IS NULL)", afterHash).
+		Distinct("hash").Order("hash").Limit(limit).Pluck("hash", &hashes).Error
+	return hashes, err
+}
+
+func (u *imageStore) Delete(ctx context.Context, imageUUID string) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁定图片，避免与修改可见性的事务交错而算错标签计数
+		if _, err := lockImage(tx, imageUUID); err != nil {
+			if errors.Is(err, errno.ErrImageNotFound) {
+				return nil
 			}
-			return fmt.Errorf("failed to lock image: %w", err)
+			return err
 		}
-		var existingTags []model.ImageTagM
-		if err := tx.Where("imageUUID = ?", imageUUID).Find(&existingTags).Error; err != nil {
-			return fmt.Errorf("failed to find existing tags: %w", err)
+		tags, err := publicImageTags(tx, []string{imageUUID})
+		if err != nil {
+			return fmt.Errorf("failed to find image tags: %w", err)
 		}
-		existingTagMap := make(map[string]struct{}, len(existingTags))
-		for _, tag := range existingTags {
-			existingTagMap[tag.Tag] = struct{}{}
+		if err := tx.Delete(&model.ImageM{}, "imageUUID = ?", imageUUID).Error; err != nil {
+			return err
 		}
-		uniqueTags := make([]model.ImageTagM, 0)
-		for _, tag := range tags {
-			if _, exists := existingTagMap[tag]; !exists {
-				uniqueTags = append(uniqueTags, model.ImageTagM{Tag: tag})
+		if err := tx.Delete(&model.ImageTagM{}, "imageUUID = ?", imageUUID).Error; err != nil {
+			return err
+		}
+		return adjustTagCounts(tx, tags, -1)
+	})
+}
+
+// DeleteCollection 在一个事务中锁定 imageUUIDs 对应的图片，删除其中属于 userUUID 的图片及其标签.
+// 返回存在的图片 UUID 到所有者 UUID 的映射，调用方据此区分已删除、不存在和无权删除的图片.
+func (u *imageStore) DeleteCollection(ctx context.Context, userUUID string, imageUUIDs []string) (map[string]string, error) {
+	owners := make(map[string]string, len(imageUUIDs))
+	if len(imageUUIDs) == 0 {
+		return owners, nil
+	}
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var images []model.ImageM
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("imageUUID", "userUUID").
+			Where("imageUUID IN ?", imageUUIDs).Find(&images).Error; err != nil {
+			return fmt.Errorf("failed to lock images: %w", err)
+		}
+		var owned []string
+		for _, image := range images {
+			owners[image.ImageUUID] = image.UserUUID
+			if image.UserUUID == userUUID {
+				owned = append(owned, image.ImageUUID)
 			}
 		}
-		err := tx.Model(&image).Association("Tags").Append(uniqueTags)
+		if len(owned) == 0 {
+			return nil
+		}
+		tags, err := publicImageTags(tx, owned)
 		if err != nil {
+			return fmt.Errorf("failed to find image tags: %w", err)
+		}
+		if err := tx.Delete(&model.ImageM{}, "imageUUID IN ?", owned).Error; err != nil {
 			return err
 		}
-		return nil
+		if err := tx.Delete(&model.ImageTagM{}, "imageUUID IN ?", owned).Error; err != nil {
+			return err
+		}
+		return adjustTagCounts(tx, tags, -1)
 	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return owners, nil
+}
+
+// BulkImageEdit 是对一批图片执行的修改. AddTags 和 RemoveTags 是规范化后的标签，Visibility 为空时不修改可见性.
+// 修改后标签数超过 MaxTags 的图片被跳过，MaxTags 为 0 时不限制.
+type BulkImageEdit struct {
+	AddTags    []string
+	RemoveTags []string
+	Visibility string
+	MaxTags    int
+}
+
+// bulkEditImage 在已锁定图片的事务 tx 中对 image 执行 edit，先删除标签再添加，以便按修改后的标签数检查上限.
+func bulkEditImage(tx *gorm.DB, image *model.ImageM, edit *BulkImageEdit) error {
+	if len(edit.RemoveTags) > 0 {
+		if err := deleteTags(tx, image, edit.RemoveTags); err != nil {
+			return err
+		}
+	}
+	if len(edit.AddTags) > 0 {
+		if err := addTags(tx, image, edit.AddTags, edit.MaxTags); err != nil {
+			return err
+		}
+	}
+	if edit.Visibility != "" && edit.Visibility != image.Visibility {
+		if err := visibilityChanged(tx, image, edit.Visibility); err != nil {
+			return err
+		}
+		if err := tx.Model(image).Update("visibility", edit.Visibility).Error; err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (u *imageStore) DeleteTagFromImage(ctx context.Context, imageUUID string, tag []string) error {
-	err := u.db.Transaction(func(tx *gorm.DB) error {
-		var image model.ImageM
+// BulkEdit 在一个事务中锁定 imageUUIDs 对应的图片，对其中属于 userUUID 的图片执行 edit. 返回修改了的图片 UUID，
+// 不存在、不属于 userUUID 或修改后标签数超过上限的图片被跳过. 每张图片在各自的保存点中修改，被跳过的图片保持不变.
+func (u *imageStore) BulkEdit(ctx context.Context, userUUID string, imageUUIDs []string, edit *BulkImageEdit) ([]string, error) {
+	var edited []string
+	if len(imageUUIDs) == 0 {
+		return edited, nil
+	}
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var images []*model.ImageM
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("imageUUID=?", imageUUID).First(&image).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
+			Where("imageUUID IN ? AND userUUID = ?", imageUUIDs, userUUID).Order("imageUUID").Find(&images).Error; err != nil {
+			return fmt.Errorf("failed to lock images: %w", err)
+		}
+		for _, image := range images {
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				return bulkEditImage(tx, image, edit)
+			})
+			if errors.Is(err, errno.ErrTooManyTags) {
+				continue
 			}
+			if err != nil {
+				return err
+			}
+			edited = append(edited, image.ImageUUID)
 		}
-		if err := tx.Where("imageUUID=? AND tag IN ?", imageUUID, tag).Delete(&model.ImageTagM{}).Error; err != nil {
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return edited, nil
+}
+
+// ownImagesMatching 返回 userUUID 自己的图片中匹配 q 的查询，搜索别名时同时匹配规范标签.
+func (u *imageStore) ownImagesMatching(ctx context.Context, userUUID string, q *tagquery.Query) (*gorm.DB, error) {
+	q, err := withAliases(u.db.WithContext(ctx), q)
+	if err != nil {
+		return nil, err
+	}
+	return u.db.WithContext(ctx).Model(&model.ImageM{}).Where("images.userUUID = ?", userUUID).Scopes(matchQuery(q)), nil
+}
+
+// ListOwnUUIDsMatching 按 imageUUID 顺序列出 afterUUID 之后 userUUID 自己的图片中匹配 q 的最多 limit 张图片.
+func (u *imageStore) ListOwnUUIDsMatching(ctx context.Context, userUUID string, q *tagquery.Query, afterUUID string, limit int) ([]string, error) {
+	tx, err := u.ownImagesMatching(ctx, userUUID, q)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	err = tx.Where("images.imageUUID > ?", afterUUID).Order("images.imageUUID").Limit(limit).Pluck("images.imageUUID", &ret).Error
+	return ret, err
+}
+
+// CountOwnMatching 返回 userUUID 自己的图片中匹配 q 的图片数.
+func (u *imageStore) CountOwnMatching(ctx context.Context, userUUID string, q *tagquery.Query) (int64, error) {
+	tx, err := u.ownImagesMatching(ctx, userUUID, q)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = tx.Count(&count).Error
+	return count, err
+}
+
+// lockImage 在事务 tx 中锁定图片记录，同一图片的标签修改依次执行.
+func lockImage(tx *gorm.DB, imageUUID string) (*model.ImageM, error) {
+	var image model.ImageM
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&image, "imageUUID = ?", imageUUID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: image not found", errno.ErrImageNotFound)
+		}
+		return nil, fmt.Errorf("failed to lock image: %w", err)
+	}
+	return &image, nil
+}
+
+// checkTagLimit 检查图片带有 count 个标签时是否超过 maxTags，maxTags 为 0 时不限制.
+func checkTagLimit(count, maxTags int) error {
+	if maxTags > 0 && count > maxTags {
+		return fmt.Errorf("%w: at most %d tags", errno.ErrTooManyTags, maxTags)
+	}
+	return nil
+}
+
+// addTags 在已锁定图片的事务 tx 中为图片添加 tags 按规则展开后的结果，已有的标签保持不变.
+// 添加后图片的标签数超过 maxTags 时返回 errno.ErrTooManyTags，不做任何修改.
+func addTags(tx *gorm.DB, image *model.ImageM, tags []string, maxTags int) error {
+	// 别名改写为规范标签，并补齐被蕴含的标签
+	tags, err := expandTags(tx, tags)
+	if err != nil {
+		return err
+	}
+	var existingTags []model.ImageTagM
+	if err := tx.Where("imageUUID = ?", image.ImageUUID).Find(&existingTags).Error; err != nil {
+		return fmt.Errorf("failed to find existing tags: %w", err)
+	}
+	existingTagMap := make(map[string]struct{}, len(existingTags))
+	for _, tag := range existingTags {
+		existingTagMap[tag.FullTag()] = struct{}{}
+	}
+	uniqueTags := make([]model.ImageTagM, 0)
+	for _, tag := range tags {
+		if _, exists := existingTagMap[tag]; !exists {
+			uniqueTags = append(uniqueTags, model.NewImageTag(image.ImageUUID, tag))
+		}
+	}
+	if len(uniqueTags) == 0 {
+		return nil
+	}
+	if err := checkTagLimit(len(existingTags)+len(uniqueTags), maxTags); err != nil {
+		return err
+	}
+	if err := tx.Model(image).Association("Tags").Append(uniqueTags); err != nil {
+		return err
+	}
+	if image.Visibility != model.VisibilityPublic {
+		return nil
+	}
+	return adjustTagCounts(tx, uniqueTags, 1)
+}
+
+// deleteTags 在已锁定图片的事务 tx 中删除图片的 tags，别名删除其规范标签，图片没有的标签被忽略.
+func deleteTags(tx *gorm.DB, image *model.ImageM, tags []string) error {
+	tags, err := resolveAliases(tx, tags)
+	if err != nil {
+		return err
+	}
+	var removed []model.ImageTagM
+	cond, args := tagCondition(exactTerms(tags))
+	if err := tx.Where("imageUUID = ?", image.ImageUUID).Where(cond, args...).Find(&removed).Error; err != nil {
+		return fmt.Errorf("failed to find tags: %w", err)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	if err := tx.Delete(&removed).Error; err != nil {
+		return err
+	}
+	if image.Visibility != model.VisibilityPublic {
+		return nil
+	}
+	return adjustTagCounts(tx, removed, -1)
+}
+
+// visibilityChanged 在已锁定图片的事务 tx 中，图片可见性将由 image.Visibility 改为 visibility 时更新其标签的公开图片数.
+// 调用方负责修改 visibility 列.
+func visibilityChanged(tx *gorm.DB, image *model.ImageM, visibility string) error {
+	wasPublic, isPublic := image.Visibility == model.VisibilityPublic, visibility == model.VisibilityPublic
+	if wasPublic == isPublic {
+		return nil
+	}
+	var tags []model.ImageTagM
+	if err := tx.Where("imageUUID = ?", image.ImageUUID).Find(&tags).Error; err != nil {
+		return fmt.Errorf("failed to find image tags: %w", err)
+	}
+	if isPublic {
+		return adjustTagCounts(tx, tags, 1)
+	}
+	return adjustTagCounts(tx, tags, -1)
+}
+
+// AddTagsToImage 为图片添加 tags，别名改写为规范标签并补齐被蕴含的标签. 添加后图片的标签数超过 maxTags 时
+// 返回 errno.ErrTooManyTags.
+func (u *imageStore) AddTagsToImage(ctx context.Context, imageUUID string, tags []string, maxTags int) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		image, err := lockImage(tx, imageUUID)
+		if err != nil {
 			return err
 		}
+		return addTags(tx, image, tags, maxTags)
+	})
+}
+
+func (u *imageStore) DeleteTagFromImage(ctx context.Context, imageUUID string, tag []string) error {
+	if len(tag) == 0 {
 		return nil
+	}
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		image, err := lockImage(tx, imageUUID)
+		if err != nil {
+			return err
+		}
+		return deleteTags(tx, image, tag)
 	})
+}
+
+// replaceTags 在已锁定图片的事务 tx 中将图片的标签替换为 tags 按规则展开后的结果. 与现有标签比较，
+// 只删除不再需要的标签、添加缺少的标签，未变化的标签保留原记录. 展开后超过 maxTags 个标签时返回 errno.ErrTooManyTags.
+func replaceTags(tx *gorm.DB, image *model.ImageM, tags []string, maxTags int) error {
+	imageUUID := image.ImageUUID
+	tags, err := expandTags(tx, tags)
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := checkTagLimit(len(tags), maxTags); err != nil {
+		return err
+	}
+	var existingTags []model.ImageTagM
+	if err := tx.Where("imageUUID = ?", imageUUID).Find(&existingTags).Error; err != nil {
+		return fmt.Errorf("failed to find existing tags: %w", err)
+	}
+	wanted := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		wanted[tag] = struct{}{}
+	}
+	// 按 ID 删除，避免不区分大小写的排序规则把仅大小写不同的新标签一起删掉
+	var removed []model.ImageTagM
+	for _, tag := range existingTags {
+		if _, ok := wanted[tag.FullTag()]; ok {
+			delete(wanted, tag.FullTag())
+		} else {
+			removed = append(removed, tag)
+		}
+	}
+	if len(removed) > 0 {
+		if err := tx.Delete(&removed).Error; err != nil {
+			return fmt.Errorf("failed to delete tags: %w", err)
+		}
+	}
+	added := make([]model.ImageTagM, 0, len(wanted))
+	for _, tag := range tags {
+		if _, ok := wanted[tag]; ok {
+			added = append(added, model.NewImageTag(imageUUID, tag))
+			delete(wanted, tag)
+		}
+	}
+	if len(added) > 0 {
+		if err := tx.Create(&added).Error; err != nil {
+			return fmt.Errorf("failed to add tags: %w", err)
+		}
+	}
+	if image.Visibility != model.VisibilityPublic {
+		return nil
+	}
+	// 先减后加，仅大小写不同的标签共用一行计数
+	if err := adjustTagCounts(tx, removed, -1); err != nil {
+		return err
+	}
+	return adjustTagCounts(tx, added, 1)
+}
+
+// ReplaceTags 将图片的标签替换为 tags，别名改写为规范标签并补齐被蕴含的标签. 展开后超过 maxTags 个标签时
+// 返回 errno.ErrTooManyTags.
+func (u *imageStore) ReplaceTags(ctx context.Context, imageUUID string, tags []string, maxTags int) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		image, err := lockImage(tx, imageUUID)
+		if err != nil {
+			return err
+		}
+		return replaceTags(tx, image, tags, maxTags)
+	})
+}
+
+// ReapplyTagRules 按当前的别名和蕴含规则重新改写图片已有的标签. 规则由管理员维护，改写结果不受标签数上限限制.
+func (u *imageStore) ReapplyTagRules(ctx context.Context, imageUUID string) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		image, err := lockImage(tx, imageUUID)
+		if err != nil {
+			return err
+		}
+		var existingTags []model.ImageTagM
+		if err := tx.Where("imageUUID = ?", imageUUID).Order("id").Find(&existingTags).Error; err != nil {
+			return fmt.Errorf("failed to find existing tags: %w", err)
+		}
+		tags := make([]string, len(existingTags))
+		for i, tag := range existingTags {
+			tags[i] = tag.FullTag()
+		}
+		return replaceTags(tx, image, tags, 0)
+	})
+}
+
+// ListUUIDsWithTag 按 imageUUID 顺序列出 afterUUID 之后带有标签 tag 的最多 limit 张图片.
+func (u *imageStore) ListUUIDsWithTag(ctx context.Context, tag string, afterUUID string, limit int) ([]string, error) {
+	namespace, name := model.SplitTag(tag)
+	var ret []string
+	err := u.db.WithContext(ctx).Model(&model.ImageTagM{}).Distinct("imageUUID").
+		Where("namespace = ? AND tag = ? AND imageUUID > ?", namespace, name, afterUUID).
+		Order("imageUUID").Limit(limit).Pluck("imageUUID", &ret).Error
+	return ret, err
 }
 
 func (u *imageStore) GetRandomPublicImages(ctx context.Context, limit int) (retCount int, ret []*model.ImageM, err error) {
 	var allCount int64
-	if err := u.db.Model(&model.ImageM{}).Where("is_public = ?", true).Count(&allCount).Error; err != nil {
+	if err := u.db.WithContext(ctx).Model(&model.ImageM{}).Scopes(listedImages("")).Count(&allCount).Error; err != nil {
 		return 0, nil, err
 	}
 	if allCount == 0 {
@@ -129,11 +535,385 @@ func (u *imageStore) GetRandomPublicImages(ctx context.Context, limit int) (retC
 		retCount = limit
 		offset = rand.Intn(int(allCount) - retCount)
 	}
-	err = u.db.Model(&model.ImageM{}).Preload("Tags").Where("is_public = ?", true).Offset(offset).Limit(limit).Find(&ret).Error
+	err = u.db.WithContext(ctx).Model(&model.ImageM{}).Preload("Tags").Scopes(listedImages("")).Offset(offset).Limit(limit).Find(&ret).Error
 	return
 }
 
-func (u *imageStore) GetUserImages(ctx context.Context, UserUUID string, offset, limit int) (count int64, ret []*model.ImageM, err error) {
-	err = u.db.Model(&model.ImageM{}).Preload("Tags").Where("userUUID = ?", UserUUID).Offset(offset).Limit(limit).Find(&ret).Count(&count).Error
-	return
+// 用户图片列表支持的排序方式.
+const (
+	ImageSortNewest        = "newest"
+	ImageSortOldest        = "oldest"
+	ImageSortUpdated       = "recently_updated"
+	ImageSortMostFavorited = "most_favorited"
+)
+
+// favoriteCountSQL 统计图片被收藏的次数，利用 favorites 上的 imageUUID 索引.
+const favoriteCountSQL = "(SELECT COUNT(*) FROM favorites WHERE favorites.imageUUID = images.imageUUID)"
+
+// imageSort 描述一种排序方式的排序键，同一排序键的图片再按 imageUUID 排序保证顺序稳定.
+type imageSort struct {
+	key  string
+	desc bool
+	// cursorValue 从游标中取出与排序键比较的值
+	cursorValue func(c *ImageCursor) interface{}
+}
+
+var imageSorts = map[string]imageSort{
+	ImageSortNewest:        {key: "images.created_at", desc: true, cursorValue: cursorTime},
+	ImageSortOldest:        {key: "images.created_at", desc: false, cursorValue: cursorTime},
+	ImageSortUpdated:       {key: "images.updated_at", desc: true, cursorValue: cursorTime},
+	ImageSortMostFavorited: {key: favoriteCountSQL, desc: true, cursorValue: func(c *ImageCursor) interface{} { return c.FavoriteCount }},
+}
+
+// searchSort 是搜索结果的排序方式，与 ImageSortNewest 相同.
+var searchSort = imageSorts[ImageSortNewest]
+
+func cursorTime(c *ImageCursor) interface{} {
+	return c.Time
+}
+
+// IsValidImageSort 判断 sort 是否为支持的排序方式，空字符串表示默认的 ImageSortNewest.
+func IsValidImageSort(sort string) bool {
+	_, ok := imageSorts[sort]
+	return sort == "" || ok
+}
+
+func getImageSort(sort string) imageSort {
+	if s, ok := imageSorts[sort]; ok {
+		return s
+	}
+	return imageSorts[ImageSortNewest]
+}
+
+// order 按排序方式排列查询结果，按收藏数排序时同时查出收藏数以便生成游标.
+func (s imageSort) order(tx *gorm.DB) *gorm.DB {
+	dir := " ASC"
+	if s.desc {
+		dir = " DESC"
+	}
+	if s.key == favoriteCountSQL {
+		tx = tx.Select("images.*, " + favoriteCountSQL + " AS favorite_count")
+	}
+	return tx.Order(s.key + dir).Order("images.imageUUID" + dir)
+}
+
+// after 限定为排在游标之后的图片.
+func (s imageSort) after(tx *gorm.DB, c *ImageCursor) *gorm.DB {
+	op := " > "
+	if s.desc {
+		op = " < "
+	}
+	v := s.cursorValue(c)
+	return tx.Where("("+s.key+op+"? OR ("+s.key+" = ? AND images.imageUUID"+op+"?))", v, v, c.ImageUUID)
+}
+
+// ImageListOptions 是列出用户图片时的过滤和排序条件，零值表示不过滤并按创建时间倒序排列.
+type ImageListOptions struct {
+	// Visibility 不为空时只包括该可见性的图片
+	Visibility string
+	// IncludeTags 中的标签图片必须全部带有，ExcludeTags 中的标签图片都不能带有
+	IncludeTags []string
+	ExcludeTags []string
+	// CreatedAfter 和 CreatedBefore 限定创建时间的范围 [CreatedAfter, CreatedBefore)
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	MimeType      string
+	Sort          string
+}
+
+// tagQuery 将标签过滤条件转换为等价的搜索条件，以便与搜索一样处理别名.
+func (o *ImageListOptions) tagQuery() *tagquery.Query {
+	q := &tagquery.Query{Exclude: exactTerms(o.ExcludeTags)}
+	for _, tag := range o.IncludeTags {
+		q.Include = append(q.Include, exactTerms([]string{tag}))
+	}
+	return q
+}
+
+// filter 将过滤条件应用到查询上，全部条件都在 SQL 中完成以保证分页大小正确. q 是加上别名后的 tagQuery.
+func (o *ImageListOptions) filter(tx *gorm.DB, q *tagquery.Query) *gorm.DB {
+	if o == nil {
+		return tx
+	}
+	if o.Visibility != "" {
+		tx = tx.Where("images.visibility = ?", o.Visibility)
+	}
+	tx = tx.Scopes(matchQuery(q))
+	if o.CreatedAfter != nil {
+		tx = tx.Where("images.created_at >= ?", *o.CreatedAfter)
+	}
+	if o.CreatedBefore != nil {
+		tx = tx.Where("images.created_at < ?", *o.CreatedBefore)
+	}
+	if o.MimeType != "" {
+		tx = tx.Where("images.mime_type = ?", o.MimeType)
+	}
+	return tx
+}
+
+func (o *ImageListOptions) sort() imageSort {
+	if o == nil {
+		return getImageSort("")
+	}
+	return getImageSort(o.Sort)
+}
+
+// userImages 返回 userUUID 符合 opts 过滤条件的图片查询，publicOnly 为 true 时只包括公开图片.
+// 与搜索相同，按别名过滤时同时匹配规范标签.
+func (u *imageStore) userImages(ctx context.Context, userUUID string, publicOnly bool, opts *ImageListOptions) (*gorm.DB, error) {
+	tx := u.db.WithContext(ctx).Model(&model.ImageM{}).Where("images.userUUID = ?", userUUID)
+	if publicOnly {
+		tx = tx.Scopes(listedImages(""))
+	}
+	if opts == nil {
+		return tx, nil
+	}
+	q, err := withAliases(u.db.WithContext(ctx), opts.tagQuery())
+	if err != nil {
+		return nil, err
+	}
+	return opts.filter(tx, q), nil
+}
+
+// ListUserImages 按 opts 过滤和排序列出 userUUID 的一页图片，返回符合条件的图片总数.
+// publicOnly 为 true 时只包括公开图片.
+func (u *imageStore) ListUserImages(ctx context.Context, userUUID string, publicOnly bool, opts *ImageListOptions, offset, limit int) (int64, []*model.ImageM, error) {
+	tx, err := u.userImages(ctx, userUUID, publicOnly, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	// 同一组条件要分别用于 Count 和 Find
+	tx = tx.Session(&gorm.Session{})
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	var ret []*model.ImageM
+	if count == 0 {
+		return 0, ret, nil
+	}
+	err = opts.sort().order(tx).Preload("Tags").Offset(offset).Limit(limit).Find(&ret).Error
+	return count, ret, err
+}
+
+// GetUserImages 按创建时间倒序列出 userUUID 的图片，返回图片的总数.
+func (u *imageStore) GetUserImages(ctx context.Context, userUUID string, offset, limit int) (int64, []*model.ImageM, error) {
+	return u.ListUserImages(ctx, userUUID, false, nil, offset, limit)
+}
+
+// GetUserPublicImages 按创建时间倒序列出 userUUID 的公开图片，返回公开图片的总数.
+func (u *imageStore) GetUserPublicImages(ctx context.Context, userUUID string, offset, limit int) (int64, []*model.ImageM, error) {
+	return u.ListUserImages(ctx, userUUID, true, nil, offset, limit)
+}
+
+// ImageCursor 是游标分页时上一页最后一张图片在排序中的位置.
+// 按收藏数排序时比较 FavoriteCount，合集中按 Position 比较，其余排序方式比较 Time.
+type ImageCursor struct {
+	Time          time.Time
+	FavoriteCount int64
+	Position      int
+	ImageUUID     string
+}
+
+// ListUserImagesAfter 按 opts 过滤和排序列出 after 之后 userUUID 的最多 limit 张图片，
+// after 为 nil 时从第一张开始. 与 offset 分页不同，翻页期间新上传的图片不会让结果错位.
+func (u *imageStore) ListUserImagesAfter(ctx context.Context, userUUID string, publicOnly bool, opts *ImageListOptions, after *ImageCursor, limit int) ([]*model.ImageM, error) {
+	sort := opts.sort()
+	tx, err := u.userImages(ctx, userUUID, publicOnly, opts)
+	if err != nil {
+		return nil, err
+	}
+	if after != nil {
+		tx = sort.after(tx, after)
+	}
+	var ret []*model.ImageM
+	err = sort.order(tx).Preload("Tags").Limit(limit).Find(&ret).Error
+	return ret, err
+}
+
+// CountUserImages 返回 userUUID 符合 opts 过滤条件的图片总数，publicOnly 为 true 时只统计公开图片.
+func (u *imageStore) CountUserImages(ctx context.Context, userUUID string, publicOnly bool, opts *ImageListOptions) (int64, error) {
+	tx, err := u.userImages(ctx, userUUID, publicOnly, opts)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = tx.Count(&count).Error
+	return count, err
+}
+
+// tagExistsSQL 判断图片带有满足给定条件的未删除标签，利用 image_tags 上的 tag_image 索引.
+const tagExistsSQL = "EXISTS (SELECT 1 FROM image_tags WHERE image_tags.imageUUID = images.imageUUID AND image_tags.deleted_at IS NULL AND (%s))"
+
+// matchQuery 限定为带有 q 要求的标签的图片.
+func matchQuery(q *tagquery.Query) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		for _, group := range q.Include {
+			cond, args := tagCondition(group)
+			tx = tx.Where(fmt.Sprintf(tagExistsSQL, cond), args...)
+		}
+		if len(q.Exclude) > 0 {
+			cond, args := tagCondition(q.Exclude)
+			tx = tx.Where("NOT "+fmt.Sprintf(tagExistsSQL, cond), args...)
+		}
+		return tx
+	}
+}
+
+// tagCondition 将一组标签转换为 OR 连接的 SQL 条件. 标签按命名空间拆分后匹配，不带命名空间的标签
+// 只匹配 general 标签；artist:* 这样只有命名空间的前缀匹配该命名空间下的全部标签.
+func tagCondition(terms []tagquery.Term) (string, []interface{}) {
+	conds := make([]string, len(terms))
+	var args []interface{}
+	for i, term := range terms {
+		namespace, name := model.SplitTag(term.Tag)
+		switch {
+		case term.Prefix && name == "":
+			conds[i] = "image_tags.namespace = ?"
+			args = append(args, namespace)
+		case term.Prefix:
+			conds[i] = "(image_tags.namespace = ? AND image_tags.tag LIKE ?)"
+			args = append(args, namespace, tagquery.LikePattern(name))
+		default:
+			conds[i] = "(image_tags.namespace = ? AND image_tags.tag = ?)"
+			args = append(args, namespace, name)
+		}
+	}
+	return strings.Join(conds, " OR "), args
+}
+
+// exactTerms 将完整标签转换为精确匹配的搜索项.
+func exactTerms(tags []string) []tagquery.Term {
+	ret := make([]tagquery.Term, len(tags))
+	for i, tag := range tags {
+		ret[i] = tagquery.Term{Tag: tag}
+	}
+	return ret
+}
+
+// withAliases 返回在 q 的每个非前缀标签旁加上其规范标签的搜索条件. 搜索别名时同时匹配规范标签，
+// 改写任务完成前仍带有别名的图片也能被搜到.
+func withAliases(tx *gorm.DB, q *tagquery.Query) (*tagquery.Query, error) {
+	var tags []string
+	for _, group := range append(slices.Clone(q.Include), q.Exclude) {
+		for _, term := range group {
+			if !term.Prefix {
+				tags = append(tags, term.Tag)
+			}
+		}
+	}
+	aliases, err := aliasesOf(tx, tags)
+	if err != nil || len(aliases) == 0 {
+		return q, err
+	}
+	expand := func(terms []tagquery.Term) []tagquery.Term {
+		ret := slices.Clone(terms)
+		for _, term := range terms {
+			if c, ok := aliases[term.Tag]; ok && !term.Prefix {
+				ret = append(ret, tagquery.Term{Tag: c})
+			}
+		}
+		return ret
+	}
+	ret := &tagquery.Query{Exclude: expand(q.Exclude)}
+	for _, group := range q.Include {
+		ret.Include = append(ret.Include, expand(group))
+	}
+	return ret, nil
+}
+
+// searchImages 返回公开图片和 userUUID 自己的图片中匹配 q 的查询，搜索别名时同时匹配规范标签.
+func (u *imageStore) searchImages(ctx context.Context, userUUID string, q *tagquery.Query) (*gorm.DB, error) {
+	q, err := withAliases(u.db.WithContext(ctx), q)
+	if err != nil {
+		return nil, err
+	}
+	return u.db.WithContext(ctx).Model(&model.ImageM{}).Scopes(listedImages(userUUID), matchQuery(q)), nil
+}
+
+// Search 在公开图片和 userUUID 自己的图片中按标签搜索，返回匹配的总数和按创建时间倒序的一页结果.
+// userUUID 为空时只搜索公开图片.
+func (u *imageStore) Search(ctx context.Context, userUUID string, q *tagquery.Query, offset, limit int) (int64, []*model.ImageM, error) {
+	tx, err := u.searchImages(ctx, userUUID, q)
+	if err != nil {
+		return 0, nil, err
+	}
+	// 同一组条件要分别用于 Count 和 Find
+	tx = tx.Session(&gorm.Session{})
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	var ret []*model.ImageM
+	if count == 0 {
+		return 0, ret, nil
+	}
+	err = searchSort.order(tx).Preload("Tags").Offset(offset).Limit(limit).Find(&ret).Error
+	return count, ret, err
+}
+
+// SearchAfter 与 Search 相同，但列出 after 之后的最多 limit 张图片，after 为 nil 时从第一张开始.
+func (u *imageStore) SearchAfter(ctx context.Context, userUUID string, q *tagquery.Query, after *ImageCursor, limit int) ([]*model.ImageM, error) {
+	tx, err := u.searchImages(ctx, userUUID, q)
+	if err != nil {
+		return nil, err
+	}
+	if after != nil {
+		tx = searchSort.after(tx, after)
+	}
+	var ret []*model.ImageM
+	err = searchSort.order(tx).Preload("Tags").Limit(limit).Find(&ret).Error
+	return ret, err
+}
+
+// CountSearch 返回 Search 匹配的图片总数.
+func (u *imageStore) CountSearch(ctx context.Context, userUUID string, q *tagquery.Query) (int64, error) {
+	tx, err := u.searchImages(ctx, userUUID, q)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = tx.Count(&count).Error
+	return count, err
+}
+
+// ListVisibleByUUIDs 返回 imageUUIDs 中 userUUID 能访问的图片，结果的顺序不确定.
+func (u *imageStore) ListVisibleByUUIDs(ctx context.Context, userUUID string, imageUUIDs []string) ([]*model.ImageM, error) {
+	var ret []*model.ImageM
+	if len(imageUUIDs) == 0 {
+		return ret, nil
+	}
+	err := u.db.WithContext(ctx).Where("imageUUID IN ?", imageUUIDs).Scopes(accessibleImages(userUUID)).
+		Preload("Tags").Find(&ret).Error
+	return ret, err
+}
+
+// similarDistanceSQL 计算图片差异哈希与给定哈希之间的汉明距离.
+const similarDistanceSQL = "BIT_COUNT(phash ^ ?)"
+
+// listSimilar 返回差异哈希与 phash 的汉明距离不超过 maxDistance 的图片，按距离从近到远排序.
+// 汉明距离无法使用索引，查询会扫描全部带差异哈希的图片.
+func listSimilar(tx *gorm.DB, phash uint64, maxDistance, limit int) ([]*model.ImageM, error) {
+	var ret []*model.ImageM
+	err := tx.Where("phash IS NOT NULL").
+		Where(similarDistanceSQL+" <= ?", phash, maxDistance).
+		Preload("Tags").
+		Order(clause.Expr{SQL: similarDistanceSQL, Vars: []interface{}{phash}}).
+		Order("created_at DESC").Order("imageUUID DESC").
+		Limit(limit).Find(&ret).Error
+	return ret, err
+}
+
+// ListSimilar 列出 userUUID 在列表中能看到的近似图片，不包括其他用户不公开列出的图片.
+func (u *imageStore) ListSimilar(ctx context.Context, userUUID string, phash uint64, maxDistance, limit int) ([]*model.ImageM, error) {
+	tx := u.db.WithContext(ctx).Model(&model.ImageM{}).Scopes(listedImages(userUUID))
+	return listSimilar(tx, phash, maxDistance, limit)
+}
+
+// ListOwnSimilar 列出 userUUID 自己上传的近似图片.
+func (u *imageStore) ListOwnSimilar(ctx context.Context, userUUID string, phash uint64, maxDistance, limit int) ([]*model.ImageM, error) {
+	tx := u.db.WithContext(ctx).Model(&model.ImageM{}).Where("userUUID = ?", userUUID)
+	return listSimilar(tx, phash, maxDistance, limit)
 }
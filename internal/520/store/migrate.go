@@ -0,0 +1,85 @@
+package store
+
+import (
+	"demo520/internal/pkg/model"
+
+	"gorm.io/gorm"
+)
+
+// Migrate 自动迁移服务用到的全部数据表.
+func Migrate(db *gorm.DB) error {
+	hasBlobs := db.Migrator().HasTable(&model.BlobM{})
+	hasConvertJobs := db.Migrator().HasTable(&model.ConvertJobM{})
+	// 旧版本用布尔列 is_public 表示可见性
+	hasIsPublic := db.Migrator().HasColumn(&model.ImageM{}, "is_public")
+	if err := db.AutoMigrate(
+		&model.UserM{},
+		&model.ImageM{},
+		&model.ImageTagM{},
+		&model.BlobM{},
+		&model.ConvertJobM{},
+		&model.FavoriteM{},
+		&model.AlbumM{},
+		&model.AlbumImageM{},
+		&model.ShareAccessM{},
+		&model.TagAliasM{},
+		&model.TagImplicationM{},
+		&model.TagRewriteJobM{},
+		&model.TagCountM{},
+		&model.BulkJobM{},
+	); err != nil {
+		return err
+	}
+	// visibility 列新建时默认为 private，按 is_public 补齐公开图片后删除旧列.
+	// 中途失败时 is_public 仍然存在，重新迁移会再次执行，UPDATE 可以重复执行
+	if hasIsPublic {
+		if err := db.Exec("UPDATE images SET visibility = ? WHERE is_public = ?", model.VisibilityPublic, true).Error; err != nil {
+			return err
+		}
+		if err := db.Migrator().DropColumn(&model.ImageM{}, "is_public"); err != nil {
+			return err
+		}
+	}
+	// 旧版本没有 namespace 列，artist:foo 整体保存在 tag 列中，拆分出其中的命名空间.
+	// 新写入的 general 标签不会以已知命名空间开头，UPDATE 可以重复执行，中途失败时重新迁移即可
+	for _, ns := range model.TagNamespaces {
+		if err := db.Exec("UPDATE image_tags SET namespace = ?, tag = SUBSTRING(tag, ?) WHERE namespace = '' AND tag LIKE ?",
+			ns, len(ns)+2, ns+":_%").Error; err != nil {
+			return err
+		}
+	}
+	// tag_counts 表为空时，按已有的公开图片统计一次标签计数，此后随修改同步维护.
+	// 统计失败时表仍为空，重新迁移会再次执行；多个实例同时迁移时，已写入的计数被覆盖为统计结果
+	var tagCounts int64
+	if err := db.Model(&model.TagCountM{}).Limit(1).Count(&tagCounts).Error; err != nil {
+		return err
+	}
+	if tagCounts == 0 {
+		if err := db.Exec("INSERT INTO tag_counts (tag, namespace, public_count, updated_at) "+
+			"SELECT image_tags.tag, image_tags.namespace, COUNT(*), NOW() FROM image_tags "+
+			"JOIN images ON images.imageUUID = image_tags.imageUUID AND images.deleted_at IS NULL "+
+			"WHERE image_tags.deleted_at IS NULL AND images.visibility = ? "+
+			"GROUP BY image_tags.tag, image_tags.namespace "+
+			"ON DUPLICATE KEY UPDATE public_count = VALUES(public_count), updated_at = VALUES(updated_at)",
+			model.VisibilityPublic).Error; err != nil {
+			return err
+		}
+	}
+	// blobs 表首次创建时，为已有图片补齐 blob 记录
+	if !hasBlobs {
+		if err := db.Exec("INSERT IGNORE INTO blobs (hash, created_at, updated_at) " +
+			"SELECT DISTINCT hash, NOW(), NOW() FROM images").Error; err != nil {
+			return err
+		}
+	}
+	// convert_jobs 表首次创建前，图片都在上传时同步完成了转换，直接记为 ready
+	if !hasConvertJobs {
+		for _, format := range []string{"webp", "avif"} {
+			if err := db.Exec("INSERT IGNORE INTO convert_jobs (hash, format, status, attempts, next_run_at, created_at, updated_at) "+
+				"SELECT hash, ?, ?, 0, NOW(), NOW(), NOW() FROM blobs", format, model.ConvertJobReady).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"demo520/internal/pkg/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ShareStore interface {
+	Set(ctx context.Context, imageUUID string, token string, expiresAt *time.Time, maxViews int) error
+	Revoke(ctx context.Context, imageUUID string) error
+	GetImage(ctx context.Context, token string) (*model.ImageM, error)
+	ConsumeView(ctx context.Context, token string, now time.Time) (bool, error)
+	LogAccess(ctx context.Context, access *model.ShareAccessM) error
+}
+
+type shareStore struct {
+	db *gorm.DB
+}
+
+var _ ShareStore = (*shareStore)(nil)
+
+func newShareStore(db *gorm.DB) ShareStore {
+	return &shareStore{
+		db: db,
+	}
+}
+
+// Set 为图片设置新的分享令牌并清零访问次数，原有的令牌随之失效. 分享不算对图片的修改，不更新 updated_at.
+func (s *shareStore) Set(ctx context.Context, imageUUID string, token string, expiresAt *time.Time, maxViews int) error {
+	return s.db.WithContext(ctx).Model(&model.ImageM{}).Where("imageUUID = ?", imageUUID).UpdateColumns(map[string]interface{}{
+		"token":            token,
+		"share_expires_at": expiresAt,
+		"share_max_views":  maxViews,
+		"share_views":      0,
+	}).Error
+}
+
+// Revoke 撤销图片的分享令牌.
+func (s *shareStore) Revoke(ctx context.Context, imageUUID string) error {
+	return s.Set(ctx, imageUUID, "", nil, 0)
+}
+
+// GetImage 返回分享令牌对应的图片，不检查令牌是否过期.
+func (s *shareStore) GetImage(ctx context.Context, token string) (*model.ImageM, error) {
+	var image model.ImageM
+	err := s.db.WithContext(ctx).Preload("Tags").First(&image, "token = ?", token).Error
+	return &image, err
+}
+
+// ConsumeView 在令牌未过期且访问次数未用完时原子地记一次访问，返回是否记录成功.
+func (s *shareStore) ConsumeView(ctx context.Context, token string, now time.Time) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.ImageM{}).
+		Where("token = ?", token).
+		Where("(share_expires_at IS NULL OR share_expires_at > ?)", now).
+		Where("(share_max_views = 0 OR share_views < share_max_views)").
+		UpdateColumn("share_views", gorm.Expr("share_views + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// LogAccess 记录一次通过分享链接的访问.
+func (s *shareStore) LogAccess(ctx context.Context, access *model.ShareAccessM) error {
+	return s.db.WithContext(ctx).Create(access).Error
+}
@@ -14,6 +14,14 @@ type IStore interface {
 	DB() *gorm.DB
 	User() UserStore
 	Image() ImageStore
+	Blob() BlobStore
+	ConvertJob() ConvertJobStore
+	Favorite() FavoriteStore
+	Album() AlbumStore
+	Share() ShareStore
+	TagRule() TagRuleStore
+	TagCount() TagCountStore
+	BulkJob() BulkJobStore
 }
 
 type datastore struct {
@@ -40,3 +48,35 @@ func (s *datastore) User() UserStore {
 func (s *datastore) Image() ImageStore {
 	return newImageStore(s.db)
 }
+
+func (s *datastore) Blob() BlobStore {
+	return newBlobStore(s.db)
+}
+
+func (s *datastore) ConvertJob() ConvertJobStore {
+	return newConvertJobStore(s.db)
+}
+
+func (s *datastore) Favorite() FavoriteStore {
+	return newFavoriteStore(s.db)
+}
+
+func (s *datastore) Album() AlbumStore {
+	return newAlbumStore(s.db)
+}
+
+func (s *datastore) Share() ShareStore {
+	return newShareStore(s.db)
+}
+
+func (s *datastore) TagRule() TagRuleStore {
+	return newTagRuleStore(s.db)
+}
+
+func (s *datastore) TagCount() TagCountStore {
+	return newTagCountStore(s.db)
+}
+
+func (s *datastore) BulkJob() BulkJobStore {
+	return newBulkJobStore(s.db)
+}
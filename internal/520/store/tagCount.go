@@ -0,0 +1,85 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"demo520/internal/pkg/model"
+	"demo520/internal/pkg/tagquery"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TagCountStore interface {
+	ListByPrefix(ctx context.Context, prefix string, limit int) ([]*model.TagCountM, error)
+	ListPopular(ctx context.Context, limit int) ([]*model.TagCountM, error)
+}
+
+type tagCountStore struct {
+	db *gorm.DB
+}
+
+var _ TagCountStore = (*tagCountStore)(nil)
+
+func newTagCountStore(db *gorm.DB) TagCountStore {
+	return &tagCountStore{
+		db: db,
+	}
+}
+
+// adjustTagCounts 在事务 tx 中将 tags 的公开图片数增加 delta. 按主键顺序更新，
+// 避免并发修改同一批标签的事务互相死锁. 计数不会小于 0.
+func adjustTagCounts(tx *gorm.DB, tags []model.ImageTagM, delta int64) error {
+	if len(tags) == 0 || delta == 0 {
+		return nil
+	}
+	rows := make([]model.TagCountM, len(tags))
+	for i, tag := range tags {
+		rows[i] = model.TagCountM{Tag: tag.Tag, Namespace: tag.Namespace, PublicCount: max(delta, 0)}
+	}
+	slices.SortFunc(rows, func(a, b model.TagCountM) int {
+		return cmp.Or(cmp.Compare(a.Tag, b.Tag), cmp.Compare(a.Namespace, b.Namespace))
+	})
+	return tx.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"public_count": gorm.Expr("GREATEST(public_count + ?, 0)", delta),
+			"updated_at":   time.Now(),
+		}),
+	}).Create(&rows).Error
+}
+
+// publicImageTags 返回 imageUUIDs 中公开图片的未删除标签.
+func publicImageTags(tx *gorm.DB, imageUUIDs []string) ([]model.ImageTagM, error) {
+	var tags []model.ImageTagM
+	if len(imageUUIDs) == 0 {
+		return tags, nil
+	}
+	err := tx.Joins("JOIN images ON images.imageUUID = image_tags.imageUUID AND images.deleted_at IS NULL").
+		Where("image_tags.imageUUID IN ? AND images.visibility = ?", imageUUIDs, model.VisibilityPublic).
+		Find(&tags).Error
+	return tags, err
+}
+
+// ListByPrefix 按公开图片数从多到少列出以 prefix 开头的最多 limit 个标签. prefix 带有命名空间时
+// 只匹配该命名空间下的标签，否则匹配全部命名空间.
+func (t *tagCountStore) ListByPrefix(ctx context.Context, prefix string, limit int) ([]*model.TagCountM, error) {
+	tx := t.db.WithContext(ctx).Where("public_count > 0")
+	if namespace, name := model.SplitTag(prefix); namespace != model.TagNamespaceGeneral {
+		tx = tx.Where("namespace = ? AND tag LIKE ?", namespace, tagquery.LikePattern(name))
+	} else {
+		tx = tx.Where("tag LIKE ?", tagquery.LikePattern(prefix))
+	}
+	var ret []*model.TagCountM
+	err := tx.Order("public_count DESC").Order("tag").Order("namespace").Limit(limit).Find(&ret).Error
+	return ret, err
+}
+
+// ListPopular 按公开图片数从多到少列出最多 limit 个标签.
+func (t *tagCountStore) ListPopular(ctx context.Context, limit int) ([]*model.TagCountM, error) {
+	var ret []*model.TagCountM
+	err := t.db.WithContext(ctx).Where("public_count > 0").
+		Order("public_count DESC").Order("tag").Order("namespace").Limit(limit).Find(&ret).Error
+	return ret, err
+}
@@ -0,0 +1,288 @@
+package store
+
+import (
+	"context"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TagRuleStore interface {
+	ListAliases(ctx context.Context, offset, limit int) (int64, []*model.TagAliasM, error)
+	SetAlias(ctx context.Context, alias string, tag string) (*model.TagRewriteJobM, error)
+	DeleteAlias(ctx context.Context, alias string) error
+	ListImplications(ctx context.Context, offset, limit int) (int64, []*model.TagImplicationM, error)
+	AddImplication(ctx context.Context, tag string, implies string) (*model.TagRewriteJobM, error)
+	DeleteImplication(ctx context.Context, tag string, implies string) error
+	ResolveAliases(ctx context.Context, tags []string) (map[string]string, error)
+	Expand(ctx context.Context, tags []string) ([]string, error)
+	GetRewriteJob(ctx context.Context, id uint) (*model.TagRewriteJobM, error)
+	ClaimRewriteJob(ctx context.Context, lease time.Duration) (*model.TagRewriteJobM, error)
+	UpdateRewriteJob(ctx context.Context, job *model.TagRewriteJobM) error
+}
+
+type tagRuleStore struct {
+	db *gorm.DB
+}
+
+var _ TagRuleStore = (*tagRuleStore)(nil)
+
+func newTagRuleStore(db *gorm.DB) TagRuleStore {
+	return &tagRuleStore{
+		db: db,
+	}
+}
+
+// aliasesOf 返回 tags 中是别名的标签到其规范标签的映射. 别名不区分大小写，映射的键是 tags 中的原始写法.
+func aliasesOf(tx *gorm.DB, tags []string) (map[string]string, error) {
+	ret := make(map[string]string)
+	if len(tags) == 0 {
+		return ret, nil
+	}
+	var aliases []model.TagAliasM
+	if err := tx.Where("alias IN ?", tags).Find(&aliases).Error; err != nil {
+		return nil, fmt.Errorf("failed to find tag aliases: %w", err)
+	}
+	canonical := make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		canonical[strings.ToLower(alias.Alias)] = alias.Tag
+	}
+	for _, tag := range tags {
+		if c, ok := canonical[strings.ToLower(tag)]; ok {
+			ret[tag] = c
+		}
+	}
+	return ret, nil
+}
+
+// resolveAliases 将 tags 中的别名改写为规范标签并去重，保持首次出现的顺序.
+func resolveAliases(tx *gorm.DB, tags []string) ([]string, error) {
+	aliases, err := aliasesOf(tx, tags)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if c, ok := aliases[tag]; ok {
+			tag = c
+		}
+		if !slices.Contains(ret, tag) {
+			ret = append(ret, tag)
+		}
+	}
+	return ret, nil
+}
+
+// expandTags 改写 tags 中的别名，再按蕴含规则逐层补齐被蕴含的标签. 被蕴含的标签同样会改写别名，
+// 已经出现过的标签不会再展开，因此规则中的环不会导致死循环.
+func expandTags(tx *gorm.DB, tags []string) ([]string, error) {
+	ret, err := resolveAliases(tx, tags)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(ret))
+	for _, tag := range ret {
+		seen[strings.ToLower(tag)] = struct{}{}
+	}
+	frontier := ret
+	for len(frontier) > 0 {
+		var implications []model.TagImplicationM
+		if err := tx.Where("tag IN ?", frontier).Order("id").Find(&implications).Error; err != nil {
+			return nil, fmt.Errorf("failed to find tag implications: %w", err)
+		}
+		implied := make([]string, 0, len(implications))
+		for _, implication := range implications {
+			implied = append(implied, implication.Implies)
+		}
+		if implied, err = resolveAliases(tx, implied); err != nil {
+			return nil, err
+		}
+		frontier = frontier[:0:0]
+		for _, tag := range implied {
+			if _, ok := seen[strings.ToLower(tag)]; !ok {
+				seen[strings.ToLower(tag)] = struct{}{}
+				frontier = append(frontier, tag)
+			}
+		}
+		ret = append(ret, frontier...)
+	}
+	return ret, nil
+}
+
+// enqueueRewrite 为带有 tag 的图片创建改写任务，已有等待执行的同一标签的任务时直接返回该任务.
+func enqueueRewrite(tx *gorm.DB, tag string) (*model.TagRewriteJobM, error) {
+	var job model.TagRewriteJobM
+	err := tx.Where("tag = ? AND status = ?", tag, model.TagRewriteJobPending).First(&job).Error
+	if err == nil {
+		return &job, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	job = model.TagRewriteJobM{
+		Tag:       tag,
+		Status:    model.TagRewriteJobPending,
+		NextRunAt: time.Now(),
+	}
+	if err := tx.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListAliases 按别名顺序列出一页别名规则，返回规则总数.
+func (s *tagRuleStore) ListAliases(ctx context.Context, offset, limit int) (int64, []*model.TagAliasM, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&model.TagAliasM{}).Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	var ret []*model.TagAliasM
+	err := s.db.WithContext(ctx).Order("alias").Offset(offset).Limit(limit).Find(&ret).Error
+	return count, ret, err
+}
+
+// SetAlias 将 alias 改写为 tag，alias 已存在时修改其规范标签，并创建改写已有图片的任务.
+// 为避免别名链，tag 不能是别名，alias 也不能是其他别名的规范标签.
+func (s *tagRuleStore) SetAlias(ctx context.Context, alias string, tag string) (*model.TagRewriteJobM, error) {
+	if strings.EqualFold(alias, tag) {
+		return nil, fmt.Errorf("%w: alias and tag are the same", errno.ErrTagRuleConflict)
+	}
+	var job *model.TagRewriteJobM
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.TagAliasM{}).Where("alias = ? OR tag = ?", tag, alias).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: aliases cannot be chained", errno.ErrTagRuleConflict)
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "alias"}},
+			DoUpdates: clause.AssignmentColumns([]string{"tag", "updated_at"}),
+		}).Create(&model.TagAliasM{Alias: alias, Tag: tag}).Error; err != nil {
+			return err
+		}
+		var err error
+		job, err = enqueueRewrite(tx, alias)
+		return err
+	})
+	return job, err
+}
+
+// DeleteAlias 删除别名规则. 已改写的图片标签保持不变.
+func (s *tagRuleStore) DeleteAlias(ctx context.Context, alias string) error {
+	result := s.db.WithContext(ctx).Where("alias = ?", alias).Delete(&model.TagAliasM{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errno.ErrTagAliasNotFound
+	}
+	return nil
+}
+
+// ListImplications 按标签顺序列出一页蕴含规则，返回规则总数.
+func (s *tagRuleStore) ListImplications(ctx context.Context, offset, limit int) (int64, []*model.TagImplicationM, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&model.TagImplicationM{}).Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	var ret []*model.TagImplicationM
+	err := s.db.WithContext(ctx).Order("tag").Order("implies").Offset(offset).Limit(limit).Find(&ret).Error
+	return count, ret, err
+}
+
+// AddImplication 添加 tag 蕴含 implies 的规则，并创建为已有图片补齐标签的任务.
+// 规则两端都不能是别名，也不能与已有规则构成环.
+func (s *tagRuleStore) AddImplication(ctx context.Context, tag string, implies string) (*model.TagRewriteJobM, error) {
+	if strings.EqualFold(tag, implies) {
+		return nil, fmt.Errorf("%w: a tag cannot imply itself", errno.ErrTagRuleConflict)
+	}
+	var job *model.TagRewriteJobM
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		aliases, err := aliasesOf(tx, []string{tag, implies})
+		if err != nil {
+			return err
+		}
+		if len(aliases) > 0 {
+			return fmt.Errorf("%w: use the canonical tag instead of an alias", errno.ErrTagRuleConflict)
+		}
+		expanded, err := expandTags(tx, []string{implies})
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(expanded, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			return fmt.Errorf("%w: implication would create a cycle", errno.ErrTagRuleConflict)
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.TagImplicationM{Tag: tag, Implies: implies}).Error; err != nil {
+			return err
+		}
+		job, err = enqueueRewrite(tx, tag)
+		return err
+	})
+	return job, err
+}
+
+// DeleteImplication 删除蕴含规则. 已补齐的标签保持不变.
+func (s *tagRuleStore) DeleteImplication(ctx context.Context, tag string, implies string) error {
+	result := s.db.WithContext(ctx).Where("tag = ? AND implies = ?", tag, implies).Delete(&model.TagImplicationM{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errno.ErrTagImplicationNotFound
+	}
+	return nil
+}
+
+// ResolveAliases 返回 tags 中是别名的标签到其规范标签的映射.
+func (s *tagRuleStore) ResolveAliases(ctx context.Context, tags []string) (map[string]string, error) {
+	return aliasesOf(s.db.WithContext(ctx), tags)
+}
+
+// Expand 按别名和蕴含规则展开 tags，返回图片实际应当带有的标签.
+func (s *tagRuleStore) Expand(ctx context.Context, tags []string) ([]string, error) {
+	return expandTags(s.db.WithContext(ctx), tags)
+}
+
+func (s *tagRuleStore) GetRewriteJob(ctx context.Context, id uint) (*model.TagRewriteJobM, error) {
+	var job model.TagRewriteJobM
+	err := s.db.WithContext(ctx).First(&job, id).Error
+	return &job, err
+}
+
+// ClaimRewriteJob 领取一个到期的改写任务，将其标记为 processing 并把租约设置为 lease 之后过期.
+// 没有可领取的任务时返回 gorm.ErrRecordNotFound.
+func (s *tagRuleStore) ClaimRewriteJob(ctx context.Context, lease time.Duration) (*model.TagRewriteJobM, error) {
+	var job model.TagRewriteJobM
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_run_at <= ?", []string{model.TagRewriteJobPending, model.TagRewriteJobProcessing}, now).
+			Order("next_run_at").First(&job).Error; err != nil {
+			return err
+		}
+		job.Status = model.TagRewriteJobProcessing
+		job.Attempts++
+		job.NextRunAt = now.Add(lease)
+		return tx.Model(&job).Select("status", "attempts", "next_run_at").Updates(&job).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UpdateRewriteJob 保存任务的进度和执行结果.
+func (s *tagRuleStore) UpdateRewriteJob(ctx context.Context, job *model.TagRewriteJobM) error {
+	return s.db.WithContext(ctx).Model(job).
+		Select("status", "attempts", "last_error", "last_image_uuid", "processed", "next_run_at").Updates(job).Error
+}
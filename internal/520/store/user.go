@@ -37,30 +37,30 @@ func newUserStore(db *gorm.DB) *userStore {
 
 func (u *userStore) Create(ctx context.Context, user *model.UserM) error {
 	if user == nil {
-		log.Errorw("user cannot be nil")
+		log.C(ctx).Errorw("user cannot be nil")
 		return errors.New("user cannot be nil")
 	}
-	return u.db.Create(user).Error
+	return u.db.WithContext(ctx).Create(user).Error
 }
 
 func (u *userStore) Update(ctx context.Context, user *model.UserM) error {
 	if user == nil {
-		log.Errorw("user cannot be nil")
+		log.C(ctx).Errorw("user cannot be nil")
 		return errors.New("user cannot be nil")
 	}
 	if user.UserUUID == "" {
-		log.Errorw("userUUID cannot be empty")
+		log.C(ctx).Errorw("userUUID cannot be empty")
 		return errors.New("userUUID cannot be empty")
 	}
 	if !govalidator.IsUUIDv4(user.UserUUID) {
-		log.Errorw("invalid UUIDv4 format", "userUUID", user.UserUUID)
+		log.C(ctx).Errorw("invalid UUIDv4 format", "userUUID", user.UserUUID)
 		return errors.New("invalid UUIDv4 format")
 	}
-	return u.db.Model(&model.UserM{}).Where("userUUID = ?", user.UserUUID).Omit("userUUID").Updates(user).Error
+	return u.db.WithContext(ctx).Model(&model.UserM{}).Where("userUUID = ?", user.UserUUID).Omit("userUUID").Updates(user).Error
 }
 
 func (u *userStore) ChangePassword(ctx context.Context, email string, oldPassword string, newPassword string) error {
-	return u.db.Transaction(func(tx *gorm.DB) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var user model.UserM
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
 			Where("email = ?", email).
@@ -84,14 +84,14 @@ func (u *userStore) ChangePassword(ctx context.Context, email string, oldPasswor
 
 func (u *userStore) Delete(ctx context.Context, userUUID string) error {
 	if userUUID == "" {
-		log.Errorw("userUUID cannot be empty")
+		log.C(ctx).Errorw("userUUID cannot be empty")
 		return errors.New("userUUID cannot be empty")
 	}
 	if !govalidator.IsUUIDv4(userUUID) {
-		log.Errorw("invalid UUIDv4 format", "userUUID", userUUID)
+		log.C(ctx).Errorw("invalid UUIDv4 format", "userUUID", userUUID)
 		return errors.New("invalid UUIDv4 format")
 	}
-	err := u.db.Model(&model.UserM{}).Where("userUUID = ?", userUUID).Delete(&model.UserM{}).Error
+	err := u.db.WithContext(ctx).Model(&model.UserM{}).Where("userUUID = ?", userUUID).Delete(&model.UserM{}).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return fmt.Errorf("user not found with UUID: %s", userUUID)
@@ -103,28 +103,28 @@ func (u *userStore) Delete(ctx context.Context, userUUID string) error {
 
 func (u *userStore) Get(ctx context.Context, email string) (*model.UserM, error) {
 	if email == "" {
-		log.Errorw("email cannot be empty")
+		log.C(ctx).Errorw("email cannot be empty")
 		return nil, errors.New("email cannot be empty")
 	}
 	if !govalidator.IsEmail(email) {
-		log.Errorw("invalid email format", "email", email)
+		log.C(ctx).Errorw("invalid email format", "email", email)
 		return nil, errors.New("invalid email format")
 	}
 	var user model.UserM
-	err := u.db.Model(&model.UserM{}).First(&user, "email = ?", email).Error
+	err := u.db.WithContext(ctx).Model(&model.UserM{}).First(&user, "email = ?", email).Error
 	return &user, err
 }
 
 func (u *userStore) List(ctx context.Context, offset int, limit int) (*[]model.UserM, error) {
 	if offset < 0 {
-		log.Errorw("offset cannot be negative")
+		log.C(ctx).Errorw("offset cannot be negative")
 		return nil, errors.New("offset cannot be negative")
 	}
 	if limit <= 0 {
-		log.Errorw("limit must be positive")
+		log.C(ctx).Errorw("limit must be positive")
 		return nil, errors.New("limit must be positive")
 	}
 	var users []model.UserM
-	err := u.db.Model(&model.UserM{}).Limit(limit).Offset(offset).Find(&users).Error
+	err := u.db.WithContext(ctx).Model(&model.UserM{}).Limit(limit).Offset(offset).Find(&users).Error
 	return &users, err
 }
@@ -2,20 +2,26 @@ package convert
 
 import (
 	"bytes"
-	"demo520/internal/pkg/helper"
+	"context"
 	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/storage"
 	"errors"
-	"os"
-	"path/filepath"
+	"fmt"
+	"io"
+	"path"
 	"strings"
 	"sync"
 
 	"github.com/davidbyttow/govips/v2/vips"
+	"github.com/gabriel-vasile/mimetype"
 	"github.com/spf13/viper"
 )
 
 type ImageConverter interface {
-	ConvertImage(filePath string) error
+	ConvertImage(ctx context.Context, backend storage.Backend, key string, format string) (int64, error)
+	Probe(ctx context.Context, backend storage.Backend, key string) (*Properties, error)
+	Sanitize(ctx context.Context, buf []byte, keepGPS bool) ([]byte, *EXIF, error)
+	Thumbnail(ctx context.Context, backend storage.Backend, key string, t Transform) ([]byte, error)
 	Shutdown()
 }
 
@@ -27,11 +33,22 @@ var (
 
 type imageConverter struct{}
 
+// Properties 是图片的固有属性，Size 为文件的字节数，PHash 是用于查找近似图片的差异哈希.
+type Properties struct {
+	Width    int
+	Height   int
+	MimeType string
+	Size     int64
+	PHash    uint64
+}
+
 type config struct {
 	WebPQuality        int
 	WebReductionEffort int
 	AvifQuality        int
 	AvifEffort         int
+	JpegQuality        int
+	OriginalQuality    int
 	Lossless           bool
 }
 
@@ -45,35 +62,96 @@ func InitImageConverter() ImageConverter {
 			WebReductionEffort: viper.GetInt("WebReductionEffort"),
 			AvifQuality:        viper.GetInt("AvifQuality"),
 			AvifEffort:         viper.GetInt("AvifEffort"),
+			JpegQuality:        viper.GetInt("JpegQuality"),
+			OriginalQuality:    viper.GetInt("OriginalQuality"),
 			Lossless:           viper.GetBool("ImageLossless"),
 		}
+		if c.JpegQuality <= 0 {
+			c.JpegQuality = 80
+		}
+		// 原图只在旋转或去除 GPS 时才重新编码，使用较高的质量
+		if c.OriginalQuality <= 0 {
+			c.OriginalQuality = 95
+		}
 		vips.Startup(nil)
 	})
 	return &converter
 }
 
-func (i *imageConverter) ConvertImage(filePath string) error {
-	if filePath == "" {
-		return errors.New("file path is empty")
+// ConvertImage 从 backend 读取 key 对应的原图，生成 format 格式的版本并写回同一前缀下，返回生成的文件的字节数.
+// 原图本身就是 format 格式时不做任何事，避免覆盖原图，此时返回原图的字节数.
+func (i *imageConverter) ConvertImage(ctx context.Context, backend storage.Backend, key string, format string) (int64, error) {
+	if key == "" {
+		return 0, errors.New("key is empty")
 	}
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return errors.New("file path is empty")
+	ext := path.Ext(key)
+	if strings.EqualFold(ext, "."+format) {
+		info, err := backend.Stat(ctx, key)
+		if err != nil {
+			return 0, err
 		}
-		return err
+		return info.Size, nil
 	}
-	if fileInfo.IsDir() {
-		return errors.New("file path is directory")
+	keyWithoutExt := strings.TrimSuffix(key, ext)
+	buf, err := readObject(ctx, backend, key)
+	if err != nil {
+		return 0, err
 	}
-	ext := filepath.Ext(filepath.Base(filePath))
-	filePathWithoutExt := strings.TrimSuffix(filePath, ext)
-	image, err := vips.NewImageFromFile(filePath)
+	image, err := vips.NewImageFromBuffer(buf)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer image.Close()
 
+	switch format {
+	case FormatWebP:
+		return i.exportWebp(ctx, backend, image, keyWithoutExt)
+	case FormatAVIF:
+		return i.exportAvif(ctx, backend, image, keyWithoutExt)
+	default:
+		return 0, fmt.Errorf("unsupported convert format: %s", format)
+	}
+}
+
+// Probe 读取 backend 中 key 对应的图片，返回其宽高、探测到的 MIME 类型、字节数和差异哈希.
+func (i *imageConverter) Probe(ctx context.Context, backend storage.Backend, key string) (*Properties, error) {
+	if key == "" {
+		return nil, errors.New("key is empty")
+	}
+	buf, err := readObject(ctx, backend, key)
+	if err != nil {
+		return nil, err
+	}
+	image, err := vips.NewImageFromBuffer(buf)
+	if err != nil {
+		return nil, err
+	}
+	defer image.Close()
+	phash, err := perceptualHash(buf)
+	if err != nil {
+		return nil, fmt.Errorf("compute perceptual hash failed: %w", err)
+	}
+
+	return &Properties{
+		Width:    image.Width(),
+		Height:   image.Height(),
+		MimeType: mimetype.Detect(buf).String(),
+		Size:     int64(len(buf)),
+		PHash:    phash,
+	}, nil
+}
+
+// readObject 读取 key 对应对象的全部内容.
+func readObject(ctx context.Context, backend storage.Backend, key string) ([]byte, error) {
+	file, _, err := backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+func (i *imageConverter) exportWebp(ctx context.Context, backend storage.Backend, image *vips.ImageRef, keyWithoutExt string) (int64, error) {
 	webp, _, err := image.ExportWebp(&vips.WebpExportParams{
 		Quality:         c.WebPQuality,
 		Lossless:        c.Lossless,
@@ -81,14 +159,16 @@ func (i *imageConverter) ConvertImage(filePath string) error {
 		ReductionEffort: c.WebReductionEffort,
 	})
 	if err != nil {
-		log.Errorw("Failed to export webp", "err", err, filePathWithoutExt)
-		return err
+		log.C(ctx).Errorw("Failed to export webp", "err", err, "key", keyWithoutExt)
+		return 0, err
 	}
-	webpErr := helper.WriteFile(filePathWithoutExt+".webp", bytes.NewReader(webp))
-	if webpErr != nil {
-		return webpErr
+	if err := backend.Put(ctx, keyWithoutExt+".webp", bytes.NewReader(webp), int64(len(webp))); err != nil {
+		return 0, err
 	}
+	return int64(len(webp)), nil
+}
 
+func (i *imageConverter) exportAvif(ctx context.Context, backend storage.Backend, image *vips.ImageRef, keyWithoutExt string) (int64, error) {
 	avif, _, err := image.ExportAvif(&vips.AvifExportParams{
 		Quality:       c.AvifQuality,
 		Lossless:      c.Lossless,
@@ -96,14 +176,79 @@ func (i *imageConverter) ConvertImage(filePath string) error {
 		Effort:        c.AvifEffort,
 	})
 	if err != nil {
-		log.Errorw("Failed to export avif", "err", err, filePathWithoutExt)
-		return err
+		log.C(ctx).Errorw("Failed to export avif", "err", err, "key", keyWithoutExt)
+		return 0, err
+	}
+	if err := backend.Put(ctx, keyWithoutExt+".avif", bytes.NewReader(avif), int64(len(avif))); err != nil {
+		return 0, err
+	}
+	return int64(len(avif)), nil
+}
+
+// Thumbnail 按 t 对 backend 中 key 对应的图片缩放并编码，返回编码后的字节.
+// 缩略图只会缩小不会放大，并且会去除全部元数据.
+func (i *imageConverter) Thumbnail(ctx context.Context, backend storage.Backend, key string, t Transform) ([]byte, error) {
+	if key == "" {
+		return nil, errors.New("key is empty")
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	width, height, crop := t.Width, t.Height, vips.InterestingNone
+	switch t.Fit {
+	case FitCover:
+		crop = vips.InterestingCentre
+		if height == 0 {
+			height = width
+		}
+	default:
+		// contain 模式下未指定高度时只限制宽度
+		if height == 0 {
+			height = maxThumbnailDimension
+		}
+	}
+	src, err := readObject(ctx, backend, key)
+	if err != nil {
+		return nil, err
+	}
+	image, err := vips.NewThumbnailWithSizeFromBuffer(src, width, height, crop, vips.SizeDown)
+	if err != nil {
+		return nil, err
 	}
-	avifErr := helper.WriteFile(filePathWithoutExt+".avif", bytes.NewReader(avif))
-	if avifErr != nil {
-		return avifErr
+	defer image.Close()
+
+	var buf []byte
+	switch t.Format {
+	case FormatWebP:
+		buf, _, err = image.ExportWebp(&vips.WebpExportParams{
+			Quality:         c.WebPQuality,
+			Lossless:        c.Lossless,
+			StripMetadata:   true,
+			ReductionEffort: c.WebReductionEffort,
+		})
+	case FormatAVIF:
+		buf, _, err = image.ExportAvif(&vips.AvifExportParams{
+			Quality:       c.AvifQuality,
+			Lossless:      c.Lossless,
+			StripMetadata: true,
+			Effort:        c.AvifEffort,
+		})
+	case FormatJPEG:
+		buf, _, err = image.ExportJpeg(&vips.JpegExportParams{
+			Quality:       c.JpegQuality,
+			StripMetadata: true,
+		})
+	case FormatPNG:
+		buf, _, err = image.ExportPng(&vips.PngExportParams{
+			Compression:   6,
+			StripMetadata: true,
+		})
+	}
+	if err != nil {
+		log.C(ctx).Errorw("Failed to export thumbnail", "err", err, "key", key, "transform", t.Key())
+		return nil, err
 	}
-	return nil
+	return buf, nil
 }
 
 func (i *imageConverter) Shutdown() {
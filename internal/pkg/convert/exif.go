@@ -0,0 +1,130 @@
+package convert
+
+import (
+	"context"
+	"demo520/internal/pkg/log"
+	"strings"
+	"time"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+const (
+	// exifGPSPrefix 是 libvips 中 GPS IFD 字段名的前缀.
+	exifGPSPrefix = "exif-ifd3-"
+	// xmpField 是 libvips 中 XMP 数据的字段名，XMP 中同样可能包含拍摄位置.
+	xmpField = "xmp-data"
+	// exifTimeLayout 是 EXIF 中日期时间的格式.
+	exifTimeLayout = "2006:01:02 15:04:05"
+)
+
+// EXIF 是从图片 EXIF 中提取的拍摄信息. Orientation 是上传时的方向，保存的原图已经按该方向旋转为正向.
+type EXIF struct {
+	Make        string
+	Model       string
+	LensModel   string
+	CapturedAt  *time.Time
+	Orientation int
+	HasGPS      bool
+}
+
+// Sanitize 解析 buf 的 EXIF 信息，并返回用于保存的原图：按 EXIF 方向旋转为正向，keepGPS 为 false 时去除 GPS 和 XMP 信息.
+// 不需要旋转也不需要去除信息时原样返回 buf，避免重新编码带来的损失. GIF 没有 EXIF，总是原样返回.
+func (i *imageConverter) Sanitize(ctx context.Context, buf []byte, keepGPS bool) ([]byte, *EXIF, error) {
+	image, err := vips.NewImageFromBuffer(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer image.Close()
+
+	exif := parseEXIF(image)
+	if image.Format() == vips.ImageTypeGIF {
+		return buf, exif, nil
+	}
+	var keep []string
+	strip := false
+	for _, field := range image.GetFields() {
+		if !keepGPS && (strings.HasPrefix(field, exifGPSPrefix) || field == xmpField) {
+			strip = true
+			continue
+		}
+		keep = append(keep, field)
+	}
+	rotate := exif.Orientation > 1
+	if !strip && !rotate {
+		return buf, exif, nil
+	}
+
+	if rotate {
+		if err := image.AutoRotate(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if strip {
+		// libvips 保存时会从 EXIF 数据中删除对应字段已被移除的标签
+		if err := image.RemoveMetadata(keep...); err != nil {
+			return nil, nil, err
+		}
+	}
+	out, err := i.exportOriginal(image)
+	if err != nil {
+		log.C(ctx).Errorw("Failed to export sanitized original", "err", err, "format", image.Format())
+		return nil, nil, err
+	}
+	return out, exif, nil
+}
+
+// exportOriginal 按原图的格式重新编码，保留剩余的元数据.
+func (i *imageConverter) exportOriginal(image *vips.ImageRef) ([]byte, error) {
+	var (
+		buf []byte
+		err error
+	)
+	switch image.Format() {
+	case vips.ImageTypeJPEG:
+		buf, _, err = image.ExportJpeg(&vips.JpegExportParams{Quality: c.OriginalQuality})
+	case vips.ImageTypePNG:
+		buf, _, err = image.ExportPng(&vips.PngExportParams{Compression: 6})
+	case vips.ImageTypeWEBP:
+		buf, _, err = image.ExportWebp(&vips.WebpExportParams{Quality: c.OriginalQuality, Lossless: c.Lossless})
+	default:
+		buf, _, err = image.ExportNative()
+	}
+	return buf, err
+}
+
+// parseEXIF 从 libvips 解析出的 EXIF 字段中提取拍摄信息.
+func parseEXIF(image *vips.ImageRef) *EXIF {
+	fields := image.GetExif()
+	exif := &EXIF{
+		Make:        exifString(fields["exif-ifd0-Make"]),
+		Model:       exifString(fields["exif-ifd0-Model"]),
+		LensModel:   exifString(fields["exif-ifd2-LensModel"]),
+		Orientation: image.Orientation(),
+	}
+	if original := exifString(fields["exif-ifd2-DateTimeOriginal"]); original != "" {
+		loc := time.UTC
+		if offset, err := time.Parse("-07:00", exifString(fields["exif-ifd2-OffsetTimeOriginal"])); err == nil {
+			_, seconds := offset.Zone()
+			loc = time.FixedZone("", seconds)
+		}
+		if t, err := time.ParseInLocation(exifTimeLayout, original, loc); err == nil {
+			exif.CapturedAt = &t
+		}
+	}
+	for field := range fields {
+		if strings.HasPrefix(field, exifGPSPrefix) {
+			exif.HasGPS = true
+			break
+		}
+	}
+	return exif
+}
+
+// exifString 去除 libvips 在 EXIF 字段值末尾附加的类型说明，例如 "Canon (Canon, ASCII, 6 components, 6 bytes)".
+func exifString(value string) string {
+	if idx := strings.LastIndex(value, " ("); idx >= 0 && strings.HasSuffix(value, ")") {
+		value = value[:idx]
+	}
+	return strings.TrimSpace(value)
+}
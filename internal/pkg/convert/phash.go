@@ -0,0 +1,54 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+const (
+	// dHashWidth、dHashHeight 是计算差异哈希时缩放到的尺寸，每行比较相邻像素得到 8 位.
+	dHashWidth  = 9
+	dHashHeight = 8
+)
+
+// perceptualHash 计算 buf 的 64 位差异哈希（dHash）：强制缩放为 9x8 的图片，逐行比较相邻像素的亮度.
+// 内容相同而尺寸、编码或压缩质量不同的图片，哈希之间的汉明距离很小.
+func perceptualHash(buf []byte) (uint64, error) {
+	image, err := vips.NewThumbnailWithSizeFromBuffer(buf, dHashWidth, dHashHeight, vips.InterestingNone, vips.SizeForce)
+	if err != nil {
+		return 0, err
+	}
+	defer image.Close()
+
+	// 借助 PNG 解码统一处理不同的通道数和位深
+	encoded, _, err := image.ExportPng(&vips.PngExportParams{StripMetadata: true})
+	if err != nil {
+		return 0, err
+	}
+	small, err := png.Decode(bytes.NewReader(encoded))
+	if err != nil {
+		return 0, err
+	}
+	bounds := small.Bounds()
+	if bounds.Dx() != dHashWidth || bounds.Dy() != dHashHeight {
+		return 0, fmt.Errorf("unexpected dhash thumbnail size: %dx%d", bounds.Dx(), bounds.Dy())
+	}
+	luma := func(x, y int) uint8 {
+		return color.GrayModel.Convert(small.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y
+	}
+
+	var hash uint64
+	for y := 0; y < dHashHeight; y++ {
+		for x := 0; x < dHashWidth-1; x++ {
+			hash <<= 1
+			if luma(x, y) > luma(x+1, y) {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
@@ -0,0 +1,57 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxThumbnailDimension 用于 contain 模式下未指定高度时的高度上限.
+const maxThumbnailDimension = 10000
+
+const (
+	// FitContain 表示等比缩放到宽高范围以内.
+	FitContain = "contain"
+	// FitCover 表示等比缩放后居中裁剪，填满宽高.
+	FitCover = "cover"
+)
+
+const (
+	FormatWebP = "webp"
+	FormatAVIF = "avif"
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// Transform 描述了一次缩略图变换.
+type Transform struct {
+	Width  int
+	Height int
+	Fit    string
+	Format string
+}
+
+// Validate 检查 Transform 的各个字段是否合法.
+func (t Transform) Validate() error {
+	if t.Width <= 0 || t.Width > maxThumbnailDimension {
+		return fmt.Errorf("invalid thumbnail width: %d", t.Width)
+	}
+	if t.Height < 0 || t.Height > maxThumbnailDimension {
+		return fmt.Errorf("invalid thumbnail height: %d", t.Height)
+	}
+	switch t.Fit {
+	case FitContain, FitCover:
+	default:
+		return fmt.Errorf("invalid thumbnail fit: %s", t.Fit)
+	}
+	switch t.Format {
+	case FormatWebP, FormatAVIF, FormatJPEG, FormatPNG:
+	default:
+		return errors.New("invalid thumbnail format: " + t.Format)
+	}
+	return nil
+}
+
+// Key 返回 Transform 对应的缓存文件名，相同的变换参数总是得到相同的文件名.
+func (t Transform) Key() string {
+	return fmt.Sprintf("w%d_h%d_%s.%s", t.Width, t.Height, t.Fit, t.Format)
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
@@ -13,12 +14,15 @@ type ErrResponse struct {
 
 	// Message 包含了可以直接对外展示的错误信息.
 	Message string `json:"message"`
+
+	// RequestID 是本次请求的唯一标识，用户反馈问题时可以提供该值用于排查.
+	RequestID string `json:"request_id,omitempty"`
 }
 
 func WriteResponse(c *gin.Context, err error, data interface{}) {
 	if err != nil {
 		hcode, code, message := errno.Decode(err)
-		c.JSON(hcode, ErrResponse{Code: code, Message: message})
+		c.JSON(hcode, ErrResponse{Code: code, Message: message, RequestID: c.GetString(known.XRequestIDKey)})
 		return
 	}
 	c.JSON(http.StatusOK, data)
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// MySQLOptions 定义 MySQL 数据库的连接选项.
+type MySQLOptions struct {
+	Host                  string
+	Username              string
+	Password              string
+	Database              string
+	MaxIdleConnections    int
+	MaxOpenConnections    int
+	MaxConnectionLifeTime time.Duration
+	LogLevel              int
+}
+
+// DSN 从 MySQLOptions 中构造 DSN.
+func (o *MySQLOptions) DSN() string {
+	return fmt.Sprintf(`%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=%t&loc=%s`,
+		o.Username,
+		o.Password,
+		o.Host,
+		o.Database,
+		true,
+		"Local")
+}
+
+// NewMySQL 使用给定的选项创建一个新的 gorm 数据库实例.
+func NewMySQL(opts *MySQLOptions) (*gorm.DB, error) {
+	logLevel := logger.Silent
+	if opts.LogLevel != 0 {
+		logLevel = logger.LogLevel(opts.LogLevel)
+	}
+	db, err := gorm.Open(mysql.Open(opts.DSN()), &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// SetMaxOpenConns 设置到数据库的最大打开连接数
+	sqlDB.SetMaxOpenConns(opts.MaxOpenConnections)
+
+	// SetConnMaxLifetime 设置连接可重用的最长时间
+	sqlDB.SetConnMaxLifetime(opts.MaxConnectionLifeTime)
+
+	// SetMaxIdleConns 设置空闲连接池的最大连接数
+	sqlDB.SetMaxIdleConns(opts.MaxIdleConnections)
+
+	return db, nil
+}
@@ -0,0 +1,8 @@
+package errno
+
+import "net/http"
+
+var ErrAlbumNotFound = &Errno{HTTP: http.StatusNotFound, Code: "ResourceNotFound.AlbumNotFound", Message: "Album not found"}
+var ErrAlbumTitleInvalid = &Errno{HTTP: http.StatusBadRequest, Code: "InvalidParameter.AlbumTitle", Message: "Album title is empty or too long"}
+var ErrAlbumOrderMismatch = &Errno{HTTP: http.StatusBadRequest, Code: "InvalidParameter.AlbumOrder", Message: "Album order must list every image in the album exactly once"}
+var ErrAlbumFull = &Errno{HTTP: http.StatusBadRequest, Code: "LimitExceeded.AlbumFull", Message: "Album has reached the maximum number of images"}
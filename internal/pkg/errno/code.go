@@ -24,4 +24,7 @@ var (
 
 	// ErrUnauthorized 表示请求没有被授权.
 	ErrUnauthorized = &Errno{HTTP: 401, Code: "AuthFailure.Unauthorized", Message: "Unauthorized."}
+
+	// ErrForbidden 表示当前用户没有执行该操作的权限.
+	ErrForbidden = &Errno{HTTP: 403, Code: "AuthFailure.Forbidden", Message: "Permission denied."}
 )
@@ -3,6 +3,14 @@ package errno
 import "net/http"
 
 var ErrImageNotFound = &Errno{HTTP: 404, Code: "ResourceNotFound.ImageNotFound", Message: "Image not found"}
+var ErrImageFileNotFound = &Errno{HTTP: http.StatusNotFound, Code: "ResourceNotFound.ImageFileNotFound", Message: "Image file not found"}
+var ErrImageSizeNotAllowed = &Errno{HTTP: http.StatusBadRequest, Code: "InvalidParameter.ImageSizeNotAllowed", Message: "Requested image size is not allowed"}
+var ErrImageDescriptionInvalid = &Errno{HTTP: http.StatusBadRequest, Code: "InvalidParameter.ImageDescription", Message: "Image description is too long or invalid"}
+var ErrImageMetadataInvalid = &Errno{HTTP: http.StatusBadRequest, Code: "InvalidParameter.ImageMetadata", Message: "Image metadata exceeds the allowed size or structure"}
+var ErrInvalidSearchQuery = &Errno{HTTP: http.StatusBadRequest, Code: "InvalidParameter.SearchQuery", Message: "Invalid search query"}
+var ErrInvalidCursor = &Errno{HTTP: http.StatusBadRequest, Code: "InvalidParameter.Cursor", Message: "Invalid or malformed pagination cursor"}
+var ErrInvalidTag = &Errno{HTTP: http.StatusBadRequest, Code: "InvalidParameter.Tag", Message: "Tag is empty, too long or contains unsupported characters"}
+var ErrTooManyTags = &Errno{HTTP: http.StatusBadRequest, Code: "InvalidParameter.TooManyTags", Message: "Too many tags on an image"}
 var ErrImageJSONNotFound = &Errno{HTTP: http.StatusNotFound, Code: "ResourceNotFound.ImageJSON", Message: "Image metadata JSON not found"}
 var ErrImageJSONInvalid = &Errno{HTTP: http.StatusBadRequest, Code: "InvalidParameter.ImageJSON", Message: "Invalid image metadata JSON format"}
 var ErrImageFileInvalid = &Errno{
@@ -15,3 +23,4 @@ var ErrImageFileTooLarge = &Errno{
 	Code:    "LimitExceeded.ImageFileTooLarge",
 	Message: "Image file size exceeds the maximum allowed limit",
 }
+var ErrBulkJobNotFound = &Errno{HTTP: http.StatusNotFound, Code: "ResourceNotFound.BulkJobNotFound", Message: "Bulk edit job not found"}
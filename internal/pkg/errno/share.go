@@ -0,0 +1,6 @@
+package errno
+
+import "net/http"
+
+var ErrShareLinkNotFound = &Errno{HTTP: http.StatusNotFound, Code: "ResourceNotFound.ShareLinkNotFound", Message: "Share link not found or has been revoked"}
+var ErrShareLinkExpired = &Errno{HTTP: http.StatusGone, Code: "ResourceUnavailable.ShareLinkExpired", Message: "Share link has expired or reached its view limit"}
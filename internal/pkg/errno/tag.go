@@ -0,0 +1,8 @@
+package errno
+
+import "net/http"
+
+var ErrTagAliasNotFound = &Errno{HTTP: http.StatusNotFound, Code: "ResourceNotFound.TagAlias", Message: "Tag alias not found"}
+var ErrTagImplicationNotFound = &Errno{HTTP: http.StatusNotFound, Code: "ResourceNotFound.TagImplication", Message: "Tag implication not found"}
+var ErrTagRuleConflict = &Errno{HTTP: http.StatusConflict, Code: "FailedOperation.TagRuleConflict", Message: "Tag rule conflicts with an existing alias or implication"}
+var ErrTagRewriteJobNotFound = &Errno{HTTP: http.StatusNotFound, Code: "ResourceNotFound.TagRewriteJob", Message: "Tag rewrite job not found"}
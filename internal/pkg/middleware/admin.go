@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin 是管理员鉴权中间件，需要放在 Authn 之后. 只有 UUID 在 admins 中的用户可以继续访问，
+// 其他用户的请求返回 ErrForbidden.
+func RequireAdmin(admins []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userUUID := c.GetString(known.XUsernameKey)
+		if userUUID == "" || !slices.Contains(admins, userUUID) {
+			core.WriteResponse(c, errno.ErrForbidden, nil)
+			c.Abort()
+
+			return
+		}
+
+		c.Next()
+	}
+}
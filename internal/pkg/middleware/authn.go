@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/pkg/token"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authn 是认证中间件，用来从 gin.Context 中提取 token 并验证 token 是否合法，
+// 如果合法则将 token 中的用户 UUID 存放在 gin.Context 的 XUsernameKey 键中.
+// 未携带或携带了非法 token 的请求会被直接拒绝.
+func Authn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 解析 JWT Token
+		userUUID, err := token.ParseRequest(c)
+		if err != nil {
+			core.WriteResponse(c, errno.ErrTokenInvalid, nil)
+			c.Abort()
+
+			return
+		}
+
+		c.Set(known.XUsernameKey, userUUID)
+		c.Next()
+	}
+}
+
+// OptionalAuthn 是可选认证中间件. 请求未携带 Authorization 头时按匿名用户放行，
+// 携带了 token 时与 Authn 一样要求其合法，并将用户 UUID 存放在 XUsernameKey 键中.
+func OptionalAuthn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+
+			return
+		}
+
+		userUUID, err := token.ParseRequest(c)
+		if err != nil {
+			core.WriteResponse(c, errno.ErrTokenInvalid, nil)
+			c.Abort()
+
+			return
+		}
+
+		c.Set(known.XUsernameKey, userUUID)
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"demo520/internal/pkg/known"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength 限制了客户端传入的 X-Request-ID 的最大长度.
+const maxRequestIDLength = 64
+
+// RequestID 是一个 Gin 中间件，用来在每一个 HTTP 请求的 context, response 中注入 `X-Request-ID` 键值对.
+// 客户端传入的 `X-Request-ID` 合法时沿用该值，否则生成一个新的 UUID.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 检查请求头中是否有 `X-Request-ID`，如果有则复用，没有则新建
+		requestID := c.Request.Header.Get(known.XRequestIDKey)
+
+		if !isValidRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		// 将 RequestID 保存在 gin.Context 中，方便后边程序使用
+		c.Set(known.XRequestIDKey, requestID)
+
+		// 将 RequestID 保存在 HTTP 返回头中，Header 的键为 `X-Request-ID`
+		c.Writer.Header().Set(known.XRequestIDKey, requestID)
+		c.Next()
+	}
+}
+
+// isValidRequestID 校验客户端传入的请求 ID，只接受字母、数字、'-'、'_' 和 '.'，防止日志注入.
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
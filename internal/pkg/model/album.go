@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AlbumM 是用户整理的图片合集. CoverImageUUID 为空表示没有封面.
+type AlbumM struct {
+	AlbumUUID      string `gorm:"type:char(36);column:albumUUID;primaryKey" json:"albumuuid"`
+	UserUUID       string `gorm:"type:char(36);column:userUUID;not null;index" json:"useruuid"`
+	Title          string `gorm:"type:varchar(100);column:title;not null" json:"title"`
+	Description    string `gorm:"type:varchar(500);column:description;not null;default:''" json:"description"`
+	IsPublic       bool   `gorm:"type:boolean;column:is_public;not null" json:"is_public"`
+	CoverImageUUID string `gorm:"type:char(36);column:coverImageUUID;not null;default:''" json:"coverimageuuid"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+func (a *AlbumM) TableName() string {
+	return "albums"
+}
+
+// AlbumImageM 记录图片在合集中的位置，同一图片在同一合集中只出现一次，按 Position 升序排列.
+type AlbumImageM struct {
+	ID        uint      `gorm:"primary_key"`
+	AlbumUUID string    `gorm:"type:char(36);column:albumUUID;not null;uniqueIndex:album_image;index:album_position" json:"albumuuid"`
+	ImageUUID string    `gorm:"type:char(36);column:imageUUID;not null;uniqueIndex:album_image;index" json:"imageuuid"`
+	Position  int       `gorm:"column:position;not null;index:album_position" json:"position"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (a *AlbumImageM) TableName() string {
+	return "album_images"
+}
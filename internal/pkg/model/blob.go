@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// BlobM 记录一个按内容 hash 存储的图片文件. 多条 ImageM 可以共享同一个 blob，
+// 上传时会刷新 UpdatedAt，垃圾回收时对该行加锁，以保证回收与同 hash 的上传互斥.
+type BlobM struct {
+	Hash      string    `gorm:"type:char(64);column:hash;primaryKey" json:"hash"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;index" json:"updated_at"`
+}
+
+func (b *BlobM) TableName() string {
+	return "blobs"
+}
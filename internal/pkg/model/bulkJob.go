@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+const (
+	// BulkJobPending 表示任务等待执行或等待重试.
+	BulkJobPending = "pending"
+	// BulkJobProcessing 表示任务已被某个 worker 领取.
+	BulkJobProcessing = "processing"
+	// BulkJobDone 表示选中的图片都已处理.
+	BulkJobDone = "done"
+	// BulkJobFailed 表示任务重试次数用尽.
+	BulkJobFailed = "failed"
+)
+
+// BulkJobM 记录一个批量修改图片标签和可见性的后台任务. 选中的图片由 ImageUUIDs 或搜索语句 Query 之一给出，
+// 只修改属于 UserUUID 的图片. 任务按 imageUUID 顺序分批处理，每批在一个事务中完成，LastImageUUID 记录已处理到的位置.
+// 任务处于 processing 状态时 NextRunAt 是租约的过期时间.
+type BulkJobM struct {
+	ID       uint   `gorm:"primary_key" json:"id"`
+	UserUUID string `gorm:"type:char(36);column:userUUID;not null;index" json:"owneruuid"`
+	// ImageUUIDs 已去重并按顺序排列
+	ImageUUIDs JSONStrings `gorm:"type:json;column:image_uuids" json:"image_uuids"`
+	Query      string      `gorm:"type:varchar(1024);column:query;not null;default:''" json:"query"`
+	AddTags    JSONStrings `gorm:"type:json;column:add_tags" json:"add_tags"`
+	RemoveTags JSONStrings `gorm:"type:json;column:remove_tags" json:"remove_tags"`
+	// Visibility 为空时不修改可见性
+	Visibility string `gorm:"type:varchar(16);column:visibility;not null;default:''" json:"visibility"`
+
+	Status        string `gorm:"type:varchar(16);column:status;not null;index:status_next_run" json:"status"`
+	Attempts      int    `gorm:"column:attempts;not null" json:"attempts"`
+	LastError     string `gorm:"type:varchar(512);column:last_error" json:"last_error"`
+	LastImageUUID string `gorm:"type:char(36);column:last_image_uuid;not null;default:''" json:"-"`
+	// Total 是创建任务时选中的图片数，Processed 是已处理的图片数，其中 Skipped 张不存在、不属于 UserUUID 或修改后标签数超过上限
+	Total     int64     `gorm:"column:total;not null;default:0" json:"total"`
+	Processed int64     `gorm:"column:processed;not null;default:0" json:"processed"`
+	Skipped   int64     `gorm:"column:skipped;not null;default:0" json:"skipped"`
+	NextRunAt time.Time `gorm:"column:next_run_at;not null;index:status_next_run" json:"next_run_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (j *BulkJobM) TableName() string {
+	return "bulk_jobs"
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+const (
+	// ConvertJobPending 表示任务等待执行或等待重试.
+	ConvertJobPending = "pending"
+	// ConvertJobProcessing 表示任务已被某个 worker 领取.
+	ConvertJobProcessing = "processing"
+	// ConvertJobReady 表示转换结果已写入存储后端.
+	ConvertJobReady = "ready"
+	// ConvertJobFailed 表示任务重试次数用尽.
+	ConvertJobFailed = "failed"
+)
+
+// ConvertJobM 记录一个 blob 转换为某种格式的异步任务. 同一 hash 的同一格式只有一条记录，
+// 任务处于 processing 状态时 NextRunAt 是租约的过期时间，过期后可以被其他 worker 重新领取.
+// 任务完成后 Size 记录生成的文件的字节数.
+type ConvertJobM struct {
+	ID        uint      `gorm:"primary_key"`
+	Hash      string    `gorm:"type:char(64);column:hash;not null;uniqueIndex:hash_format" json:"hash"`
+	Format    string    `gorm:"type:varchar(8);column:format;not null;uniqueIndex:hash_format" json:"format"`
+	Status    string    `gorm:"type:varchar(16);column:status;not null;index:status_next_run" json:"status"`
+	Attempts  int       `gorm:"column:attempts;not null" json:"attempts"`
+	LastError string    `gorm:"type:varchar(512);column:last_error" json:"last_error"`
+	Size      int64     `gorm:"column:size;not null;default:0" json:"size"`
+	NextRunAt time.Time `gorm:"column:next_run_at;not null;index:status_next_run" json:"next_run_at"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (j *ConvertJobM) TableName() string {
+	return "convert_jobs"
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// FavoriteM 记录用户收藏的图片，同一用户对同一图片只有一条记录.
+type FavoriteM struct {
+	ID        uint      `gorm:"primary_key"`
+	UserUUID  string    `gorm:"type:char(36);column:userUUID;not null;uniqueIndex:user_image" json:"useruuid"`
+	ImageUUID string    `gorm:"type:char(36);column:imageUUID;not null;uniqueIndex:user_image;index" json:"imageuuid"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (f *FavoriteM) TableName() string {
+	return "favorites"
+}
@@ -8,16 +8,46 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	// VisibilityPrivate 表示只有所有者可以访问的图片.
+	VisibilityPrivate = "private"
+	// VisibilityUnlisted 表示持有链接即可访问，但不会出现在公开列表、搜索和近似图片中的图片.
+	VisibilityUnlisted = "unlisted"
+	// VisibilityPublic 表示任何人都可以访问和发现的图片.
+	VisibilityPublic = "public"
+)
+
+// IsValidVisibility 判断 v 是否为合法的图片可见性.
+func IsValidVisibility(v string) bool {
+	return v == VisibilityPrivate || v == VisibilityUnlisted || v == VisibilityPublic
+}
+
 type ImageM struct {
-	ImageUUID string      `gorm:"type:char(36);column:imageUUID;primaryKey" json:"imageuuid"`
-	Hash      string      `gorm:"type:char(64);column:hash;index;not null" json:"hash"`
-	Token     string      `gorm:"type:char(36);column:token;index" json:"token"`
-	UserUUID  string      `gorm:"type:char(36);column:userUUID;not null" json:"useruuid"`
-	IsPublic  bool        `gorm:"type:boolean;column:is_public;not null" json:"is_public"`
-	Tags      []ImageTagM `gorm:"foreignKey:ImageUUID;references:ImageUUID" json:"tags"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ImageUUID      string      `gorm:"type:char(36);column:imageUUID;primaryKey" json:"imageuuid"`
+	Hash           string      `gorm:"type:char(64);column:hash;index;not null" json:"hash"`
+	Token          string      `gorm:"type:char(36);column:token;index" json:"token"`
+	ShareExpiresAt *time.Time  `gorm:"column:share_expires_at" json:"share_expires_at"`                  // 分享链接的过期时间，为空表示永不过期
+	ShareMaxViews  int         `gorm:"column:share_max_views;not null;default:0" json:"share_max_views"` // 分享链接允许的访问次数，0 表示不限次数
+	ShareViews     int         `gorm:"column:share_views;not null;default:0" json:"share_views"`         // 分享链接已被访问的次数
+	UserUUID       string      `gorm:"type:char(36);column:userUUID;not null;index:idx_images_user_created,priority:1" json:"useruuid"`
+	Visibility     string      `gorm:"type:enum('private','unlisted','public');column:visibility;not null;default:'private';index" json:"visibility"`
+	Description    string      `gorm:"type:varchar(500);column:description;not null;default:''" json:"description"`
+	Metadata       JSONMap     `gorm:"type:json;column:metadata" json:"metadata"`
+	Width          int         `gorm:"column:width;not null;default:0" json:"width"`
+	Height         int         `gorm:"column:height;not null;default:0" json:"height"`
+	MimeType       string      `gorm:"type:varchar(64);column:mime_type;not null;default:''" json:"mime_type"`
+	Size           int64       `gorm:"column:size;not null;default:0" json:"size"`
+	CameraMake     string      `gorm:"type:varchar(64);column:camera_make;not null;default:''" json:"camera_make"`
+	CameraModel    string      `gorm:"type:varchar(64);column:camera_model;not null;default:''" json:"camera_model"`
+	LensModel      string      `gorm:"type:varchar(128);column:lens_model;not null;default:''" json:"lens_model"`
+	CapturedAt     *time.Time  `gorm:"column:captured_at" json:"captured_at"`
+	Orientation    int         `gorm:"column:orientation;not null;default:0" json:"orientation"`
+	PHash          *uint64     `gorm:"type:bigint unsigned;column:phash" json:"phash"`
+	Tags           []ImageTagM `gorm:"foreignKey:ImageUUID;references:ImageUUID" json:"tags"`
+	FavoriteCount  int64       `gorm:"column:favorite_count;->;-:migration" json:"-"` // 只读，仅在按收藏数排序时查出
+	CreatedAt      time.Time   `gorm:"index:idx_images_user_created,priority:2"`
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
 }
 
 func (u *ImageM) TableName() string {
@@ -32,13 +62,13 @@ func (u *ImageM) Equal(other *ImageM) bool {
 		u.Hash != other.Hash &&
 		u.Token != other.Token &&
 		u.UserUUID != other.UserUUID &&
-		u.IsPublic != other.IsPublic &&
+		u.Visibility != other.Visibility &&
 		len(u.Tags) != len(other.Tags) {
 		return false
 	}
 
-	slices.SortFunc(u.Tags, func(a, b ImageTagM) int { return cmp.Compare(a.Tag, b.Tag) })
-	slices.SortFunc(other.Tags, func(a, b ImageTagM) int { return cmp.Compare(a.Tag, b.Tag) })
+	slices.SortFunc(u.Tags, func(a, b ImageTagM) int { return cmp.Compare(a.FullTag(), b.FullTag()) })
+	slices.SortFunc(other.Tags, func(a, b ImageTagM) int { return cmp.Compare(a.FullTag(), b.FullTag()) })
 
-	return slices.EqualFunc(u.Tags, other.Tags, func(a, b ImageTagM) bool { return a.Tag == b.Tag })
+	return slices.EqualFunc(u.Tags, other.Tags, func(a, b ImageTagM) bool { return a.FullTag() == b.FullTag() })
 }
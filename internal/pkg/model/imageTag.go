@@ -2,12 +2,29 @@ package model
 
 import (
 	"gorm.io/gorm"
+	"strings"
 	"time"
 )
 
+// 标签的命名空间. 输入 namespace:name 形式的标签时，已知的命名空间与名称分开保存；
+// 不带命名空间的标签属于 general.
+const (
+	TagNamespaceGeneral   = ""
+	TagNamespaceArtist    = "artist"
+	TagNamespaceCharacter = "character"
+	TagNamespaceCopyright = "copyright"
+	TagNamespaceMeta      = "meta"
+)
+
+// TagNamespaces 是除 general 外的全部命名空间.
+var TagNamespaces = []string{TagNamespaceArtist, TagNamespaceCharacter, TagNamespaceCopyright, TagNamespaceMeta}
+
 type ImageTagM struct {
-	ID        uint   `gorm:"primary_key"`
-	Tag       string `gorm:"type:varchar(255);column:tag;not null;index:tag_image;collate:utf8mb4_unicode_ci" json:"tag"`
+	ID uint `gorm:"primary_key"`
+	// Namespace 是标签的命名空间，general 标签为空字符串
+	Namespace string `gorm:"type:varchar(16);column:namespace;not null;default:'';index:namespace_tag,priority:1" json:"namespace"`
+	// Tag 是不带命名空间前缀的标签名称
+	Tag       string `gorm:"type:varchar(255);column:tag;not null;index:tag_image;index:namespace_tag,priority:2;collate:utf8mb4_unicode_ci" json:"tag"`
 	ImageUUID string `gorm:"type:char(36);not null;index:tag_image;column:imageUUID"`
 	CreatedAt time.Time
 	DeletedAt gorm.DeletedAt `gorm:"index"`
@@ -21,5 +38,40 @@ func (u *ImageTagM) ToString() string {
 	if u == nil {
 		return ""
 	}
-	return u.Tag
+	return u.FullTag()
+}
+
+// FullTag 返回带命名空间前缀的完整标签.
+func (u *ImageTagM) FullTag() string {
+	return JoinTag(u.Namespace, u.Tag)
+}
+
+// NewImageTag 将完整标签 tag 拆分后构造 imageUUID 的标签记录.
+func NewImageTag(imageUUID string, tag string) ImageTagM {
+	namespace, name := SplitTag(tag)
+	return ImageTagM{Namespace: namespace, Tag: name, ImageUUID: imageUUID}
+}
+
+// SplitTag 将完整标签拆分为命名空间和名称，命名空间不区分大小写. 冒号前不是已知的命名空间时，
+// 整个标签都是 general 标签的名称.
+func SplitTag(tag string) (namespace string, name string) {
+	prefix, rest, ok := strings.Cut(tag, ":")
+	if !ok {
+		return TagNamespaceGeneral, tag
+	}
+	prefix = strings.ToLower(prefix)
+	for _, ns := range TagNamespaces {
+		if prefix == ns {
+			return ns, rest
+		}
+	}
+	return TagNamespaceGeneral, tag
+}
+
+// JoinTag 是 SplitTag 的逆操作.
+func JoinTag(namespace string, name string) string {
+	if namespace == TagNamespaceGeneral {
+		return name
+	}
+	return namespace + ":" + name
 }
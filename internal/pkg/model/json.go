@@ -0,0 +1,81 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONMap 是以 JSON 格式存储在数据库中的对象，nil 存储为 NULL.
+type JSONMap map[string]interface{}
+
+// Value 实现 driver.Valuer 接口.
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner 接口.
+func (m *JSONMap) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONMap", value)
+	}
+	var ret map[string]interface{}
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return err
+	}
+	*m = ret
+	return nil
+}
+
+// JSONStrings 是以 JSON 数组格式存储在数据库中的字符串列表，nil 存储为 NULL.
+type JSONStrings []string
+
+// Value 实现 driver.Valuer 接口.
+func (s JSONStrings) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal([]string(s))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner 接口.
+func (s *JSONStrings) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONStrings", value)
+	}
+	var ret []string
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return err
+	}
+	*s = ret
+	return nil
+}
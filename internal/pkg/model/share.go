@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	// ShareAccessInfo 表示通过分享链接读取图片信息.
+	ShareAccessInfo = "info"
+	// ShareAccessFile 表示通过分享链接读取图片文件.
+	ShareAccessFile = "file"
+)
+
+// ShareAccessM 记录一次通过分享链接对图片的访问.
+type ShareAccessM struct {
+	ID        uint      `gorm:"primary_key"`
+	ImageUUID string    `gorm:"type:char(36);column:imageUUID;not null;index" json:"imageuuid"`
+	Token     string    `gorm:"type:char(36);column:token;not null" json:"token"`
+	Action    string    `gorm:"type:varchar(16);column:action;not null" json:"action"`
+	ClientIP  string    `gorm:"type:varchar(64);column:client_ip;not null;default:''" json:"client_ip"`
+	UserAgent string    `gorm:"type:varchar(255);column:user_agent;not null;default:''" json:"user_agent"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (s *ShareAccessM) TableName() string {
+	return "share_accesses"
+}
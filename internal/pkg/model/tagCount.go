@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// TagCountM 记录使用每个标签的公开图片数. 计数随标签和图片可见性的修改在同一事务中维护，
+// 标签目录和自动补全直接读取计数，不必每次统计 image_tags.
+type TagCountM struct {
+	// Tag 在前，使主键也能用于不限命名空间的前缀查询
+	Tag         string `gorm:"type:varchar(255);column:tag;primaryKey;collate:utf8mb4_unicode_ci" json:"tag"`
+	Namespace   string `gorm:"type:varchar(16);column:namespace;primaryKey" json:"namespace"`
+	PublicCount int64  `gorm:"column:public_count;not null;default:0;index" json:"public_count"`
+	UpdatedAt   time.Time
+}
+
+func (t *TagCountM) TableName() string {
+	return "tag_counts"
+}
+
+// FullTag 返回带命名空间前缀的完整标签.
+func (t *TagCountM) FullTag() string {
+	return JoinTag(t.Namespace, t.Tag)
+}
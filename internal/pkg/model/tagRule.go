@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// TagAliasM 将别名 Alias 改写为规范标签 Tag. 同一别名只有一条记录，规范标签不能再是别名.
+type TagAliasM struct {
+	ID        uint      `gorm:"primary_key"`
+	Alias     string    `gorm:"type:varchar(255);column:alias;not null;uniqueIndex;collate:utf8mb4_unicode_ci" json:"alias"`
+	Tag       string    `gorm:"type:varchar(255);column:tag;not null;index;collate:utf8mb4_unicode_ci" json:"tag"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (a *TagAliasM) TableName() string {
+	return "tag_aliases"
+}
+
+// TagImplicationM 表示带有标签 Tag 的图片同时带有标签 Implies，蕴含关系可以传递.
+type TagImplicationM struct {
+	ID        uint      `gorm:"primary_key"`
+	Tag       string    `gorm:"type:varchar(255);column:tag;not null;uniqueIndex:tag_implies;collate:utf8mb4_unicode_ci" json:"tag"`
+	Implies   string    `gorm:"type:varchar(255);column:implies;not null;uniqueIndex:tag_implies;collate:utf8mb4_unicode_ci" json:"implies"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (i *TagImplicationM) TableName() string {
+	return "tag_implications"
+}
+
+const (
+	// TagRewriteJobPending 表示任务等待执行或等待重试.
+	TagRewriteJobPending = "pending"
+	// TagRewriteJobProcessing 表示任务已被某个 worker 领取.
+	TagRewriteJobProcessing = "processing"
+	// TagRewriteJobDone 表示带有该标签的图片都已按当前规则改写.
+	TagRewriteJobDone = "done"
+	// TagRewriteJobFailed 表示任务重试次数用尽.
+	TagRewriteJobFailed = "failed"
+)
+
+// TagRewriteJobM 记录一个按当前别名和蕴含规则改写带有标签 Tag 的已有图片的后台任务.
+// 任务按 imageUUID 顺序分批处理，LastImageUUID 记录已处理到的位置，任务被重新领取后从该位置继续.
+// 任务处于 processing 状态时 NextRunAt 是租约的过期时间.
+type TagRewriteJobM struct {
+	ID            uint      `gorm:"primary_key" json:"id"`
+	Tag           string    `gorm:"type:varchar(255);column:tag;not null" json:"tag"`
+	Status        string    `gorm:"type:varchar(16);column:status;not null;index:status_next_run" json:"status"`
+	Attempts      int       `gorm:"column:attempts;not null" json:"attempts"`
+	LastError     string    `gorm:"type:varchar(512);column:last_error" json:"last_error"`
+	LastImageUUID string    `gorm:"type:char(36);column:last_image_uuid;not null;default:''" json:"-"`
+	Processed     int64     `gorm:"column:processed;not null;default:0" json:"processed"`
+	NextRunAt     time.Time `gorm:"column:next_run_at;not null;index:status_next_run" json:"next_run_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (j *TagRewriteJobM) TableName() string {
+	return "tag_rewrite_jobs"
+}
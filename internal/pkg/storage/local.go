@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localBackend 将对象保存为 dir 下的文件.
+type localBackend struct {
+	dir string
+}
+
+var _ Backend = (*localBackend)(nil)
+
+// NewLocal 创建一个以 dir 为根目录的本地磁盘存储后端.
+func NewLocal(dir string) Backend {
+	return &localBackend{dir: dir}
+}
+
+func (l *localBackend) path(key string) (string, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(cleaned)), nil
+}
+
+// Put 先写入同目录下的临时文件再重命名，并发写入同一 key 时读者只会看到完整的文件.
+func (l *localBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	fullPath, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	// 创建目录（更严格的权限）
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0750); err != nil {
+		return fmt.Errorf("create directory failed: %w", err)
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(fullPath), filepath.Base(fullPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file failed: %w", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath) // 确保临时文件最终被清理
+	if _, err := io.Copy(tempFile, r); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("write file content failed: %w", err)
+	}
+	// 确保数据刷到磁盘
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("sync file failed: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("close temp file failed: %w", err)
+	}
+	if err := os.Chmod(tempPath, 0640); err != nil {
+		return fmt.Errorf("chmod temp file failed: %w", err)
+	}
+	if err := os.Rename(tempPath, fullPath); err != nil {
+		return fmt.Errorf("rename file failed: %w", err)
+	}
+	return nil
+}
+
+func (l *localBackend) Get(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	fullPath, err := l.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if fileInfo.IsDir() {
+		file.Close()
+		return nil, nil, notExist(key)
+	}
+	return file, &ObjectInfo{Key: key, Size: fileInfo.Size(), ModTime: fileInfo.ModTime()}, nil
+}
+
+func (l *localBackend) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	fullPath, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fileInfo, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo.IsDir() {
+		return nil, notExist(key)
+	}
+	return &ObjectInfo{Key: key, Size: fileInfo.Size(), ModTime: fileInfo.ModTime()}, nil
+}
+
+func (l *localBackend) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// 从 prefix 中最后一个 '/' 之前的目录开始遍历
+	root := l.dir
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir, err := l.path(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		root = dir
+	}
+	var objects []ObjectInfo
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (l *localBackend) Delete(ctx context.Context, key string) error {
+	fullPath, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	// 尽量清理空目录，目录非空时 Remove 会失败，忽略即可
+	for dir := filepath.Dir(fullPath); dir != filepath.Clean(l.dir) && strings.HasPrefix(dir, filepath.Clean(l.dir)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
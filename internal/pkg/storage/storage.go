@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// BackendLocal 表示本地磁盘存储.
+	BackendLocal = "local"
+	// BackendMemory 表示进程内存储，只用于测试和单机调试.
+	BackendMemory = "memory"
+	// BackendS3 表示 S3 兼容的对象存储.
+	BackendS3 = "s3"
+)
+
+// ObjectInfo 描述了一个已存储对象的属性.
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Backend 是图片文件的存储后端. key 使用 '/' 分隔，例如 "ab/cdef.../<hash>.webp".
+// 对象不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist).
+type Backend interface {
+	// Put 写入对象，size 未知时传 -1. 写入是原子的，读者不会看到不完整的对象.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get 打开对象，调用方负责关闭返回的 ReadSeekCloser.
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error)
+	// Stat 返回对象的属性.
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// List 递归列出 key 以 prefix 开头的全部对象.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Delete 删除对象，对象不存在时不返回错误.
+	Delete(ctx context.Context, key string) error
+}
+
+// Options 定义了存储后端的配置.
+type Options struct {
+	Backend  string
+	LocalDir string
+	S3       S3Options
+}
+
+var (
+	once    sync.Once
+	backend Backend
+)
+
+// New 根据 opts 创建存储后端.
+func New(opts *Options) (Backend, error) {
+	switch opts.Backend {
+	case "", BackendLocal:
+		return NewLocal(opts.LocalDir), nil
+	case BackendMemory:
+		return NewMemory(), nil
+	case BackendS3:
+		return NewS3(&opts.S3)
+	default:
+		return nil, fmt.Errorf("unsupported storage backend: %s", opts.Backend)
+	}
+}
+
+// Init 使用 opts 初始化全局存储后端，只有第一次调用生效.
+func Init(opts *Options) error {
+	var err error
+	once.Do(func() {
+		backend, err = New(opts)
+	})
+	return err
+}
+
+// Default 返回全局存储后端. 未调用 Init 时使用配置项 image_dir 指定的本地目录.
+func Default() Backend {
+	once.Do(func() {
+		backend = NewLocal(viper.GetString("image_dir"))
+	})
+	return backend
+}
+
+// cleanKey 规范化 key 并拒绝逃逸出存储根目录的 key.
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != strings.TrimPrefix(key, "/") {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return cleaned, nil
+}
+
+// notExist 构造一个满足 errors.Is(err, fs.ErrNotExist) 的错误.
+func notExist(key string) error {
+	return &fs.PathError{Op: "open", Path: key, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,48 @@
+package tagquery
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tagPunctuation 是标签中除字母和数字外允许出现的字符.
+const tagPunctuation = "_-.:'()!?/+&"
+
+// NormalizeOptions 控制标签的规范化方式.
+type NormalizeOptions struct {
+	// Lowercase 为 true 时将标签转换为小写
+	Lowercase bool
+	// MaxLength 是规范化后标签的最大字节数，不超过 image_tags.tag 列的长度
+	MaxLength int
+}
+
+// Normalize 规范化标签：去掉首尾空白，将连续的空白替换为一个下划线，按 opts 转换大小写，
+// 再检查长度和字符. 标签只能由字母、数字和 tagPunctuation 中的字符组成，且不能以 - 或 ~ 开头，
+// 以免与搜索语法冲突.
+func Normalize(tag string, opts NormalizeOptions) (string, error) {
+	tag = strings.Join(strings.Fields(tag), "_")
+	if opts.Lowercase {
+		tag = strings.ToLower(tag)
+	}
+	if tag == "" {
+		return "", errors.New("empty tag")
+	}
+	maxLength := maxTagLength
+	if opts.MaxLength > 0 {
+		maxLength = min(opts.MaxLength, maxTagLength)
+	}
+	if len(tag) > maxLength {
+		return "", fmt.Errorf("tag %q is longer than %d bytes", tag, maxLength)
+	}
+	if strings.HasPrefix(tag, "-") {
+		return "", fmt.Errorf("tag %q cannot start with '-'", tag)
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r) && !strings.ContainsRune(tagPunctuation, r) {
+			return "", fmt.Errorf("tag %q contains unsupported character %q", tag, r)
+		}
+	}
+	return tag, nil
+}
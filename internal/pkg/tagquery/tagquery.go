@@ -0,0 +1,109 @@
+// Package tagquery 解析 booru 风格的标签搜索语句.
+//
+// 语法：以空白分隔的若干项，全部项之间是 AND 关系.
+//
+//	cat          必须带有标签 cat
+//	-sketch      不能带有标签 sketch
+//	cat|dog      带有 cat 或 dog 之一
+//	~cat ~dog    所有以 ~ 开头的项组成一个 OR 组，与 cat|dog 等价
+//	cat*         带有任意以 cat 开头的标签，* 只能出现在末尾
+//	artist:foo   带有 artist 命名空间下的标签 foo，不带命名空间的标签只匹配 general 标签
+//	artist:*     带有任意 artist 命名空间下的标签
+package tagquery
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxTerms 是一条搜索语句允许的最大标签数.
+	MaxTerms = 20
+	// maxTagLength 与 image_tags.tag 列的长度一致.
+	maxTagLength = 255
+)
+
+// Term 是搜索语句中的一个标签，Prefix 为 true 时匹配所有以 Tag 开头的标签.
+type Term struct {
+	Tag    string
+	Prefix bool
+}
+
+// Query 是解析后的搜索语句. Include 中的每一组是一个 OR 组，组与组之间是 AND 关系；
+// 图片不能带有 Exclude 中的任何标签.
+type Query struct {
+	Include [][]Term
+	Exclude []Term
+}
+
+// IsEmpty 判断搜索语句是否没有任何条件.
+func (q *Query) IsEmpty() bool {
+	return len(q.Include) == 0 && len(q.Exclude) == 0
+}
+
+// Parse 解析搜索语句 s，空语句返回没有任何条件的 Query.
+func Parse(s string) (*Query, error) {
+	q := &Query{}
+	var tildeGroup []Term
+	count := 0
+	for _, token := range strings.Fields(s) {
+		switch {
+		case strings.HasPrefix(token, "-"):
+			term, err := parseTerm(token[1:])
+			if err != nil {
+				return nil, err
+			}
+			q.Exclude = append(q.Exclude, term)
+			count++
+		case strings.HasPrefix(token, "~"):
+			term, err := parseTerm(token[1:])
+			if err != nil {
+				return nil, err
+			}
+			tildeGroup = append(tildeGroup, term)
+			count++
+		default:
+			var group []Term
+			for _, part := range strings.Split(token, "|") {
+				term, err := parseTerm(part)
+				if err != nil {
+					return nil, err
+				}
+				group = append(group, term)
+			}
+			q.Include = append(q.Include, group)
+			count += len(group)
+		}
+		if count > MaxTerms {
+			return nil, fmt.Errorf("too many tags in query, at most %d", MaxTerms)
+		}
+	}
+	if len(tildeGroup) > 0 {
+		q.Include = append(q.Include, tildeGroup)
+	}
+	return q, nil
+}
+
+// parseTerm 解析单个标签，处理末尾的前缀通配符.
+func parseTerm(s string) (Term, error) {
+	term := Term{Tag: s}
+	if strings.HasSuffix(s, "*") {
+		term = Term{Tag: strings.TrimSuffix(s, "*"), Prefix: true}
+	}
+	if term.Tag == "" {
+		return Term{}, errors.New("empty tag in query")
+	}
+	if strings.ContainsAny(term.Tag, "*|~") || strings.HasPrefix(term.Tag, "-") {
+		return Term{}, fmt.Errorf("invalid tag in query: %q", s)
+	}
+	if len(term.Tag) > maxTagLength {
+		return Term{}, fmt.Errorf("tag too long in query: %q", s)
+	}
+	return term, nil
+}
+
+// LikePattern 返回匹配所有以 tag 开头的标签的 LIKE 模式，tag 中的通配符会被转义.
+func LikePattern(tag string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(tag) + "%"
+}
@@ -0,0 +1,72 @@
+package api
+
+// CreateAlbumRequest 是创建合集的请求，CoverImageUUID 为空表示没有封面.
+type CreateAlbumRequest struct {
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	IsPublic       bool   `json:"is_public"`
+	CoverImageUUID string `json:"cover_image_uuid"`
+}
+
+// UpdateAlbumRequest 是修改合集的请求，未出现的字段保持不变，CoverImageUUID 为空字符串时清除封面.
+type UpdateAlbumRequest struct {
+	Title          *string `json:"title"`
+	Description    *string `json:"description"`
+	IsPublic       *bool   `json:"is_public"`
+	CoverImageUUID *string `json:"cover_image_uuid"`
+}
+
+// AlbumInfo 是合集的信息. 封面图片对当前用户不可见时 CoverImageUUID 为空.
+type AlbumInfo struct {
+	AlbumUUID      string `json:"album_uuid"`
+	UserUUID       string `json:"owneruuid"`
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	IsPublic       bool   `json:"is_public"`
+	CoverImageUUID string `json:"cover_image_uuid,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+type CreateAlbumResponse AlbumInfo
+
+type UpdateAlbumResponse AlbumInfo
+
+// GetAlbumResponse 是合集的信息及按合集顺序分页的图片，Images 中只包含当前用户可见的图片.
+type GetAlbumResponse struct {
+	AlbumInfo
+	Images ImagePageResponse `json:"images"`
+}
+
+// ListAlbumsResponse 是按页码分页的合集列表.
+type ListAlbumsResponse struct {
+	Total     int64       `json:"total"`
+	Page      int         `json:"page"`
+	PageSize  int         `json:"page_size"`
+	AlbumList []AlbumInfo `json:"album_list"`
+}
+
+// ListAlbumsByCursorResponse 是按游标分页的合集列表. Count 是本页的合集数，NextCursor 用于读取下一页，
+// 为空表示没有更多合集，Total 只在请求 with_total 时返回.
+type ListAlbumsByCursorResponse struct {
+	Count      int         `json:"count"`
+	AlbumList  []AlbumInfo `json:"album_list"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Total      *int64      `json:"total,omitempty"`
+}
+
+// ListAlbumsRequest 是列出合集的查询参数，指定 cursor 或 limit 时按游标分页，否则按页码分页.
+type ListAlbumsRequest struct {
+	PageRequest
+	CursorRange
+}
+
+// AddAlbumImagesRequest 是向合集末尾追加图片的请求.
+type AddAlbumImagesRequest struct {
+	ImageUUIDs []string `json:"image_uuids"`
+}
+
+// ReorderAlbumRequest 是重新排列合集的请求，ImageUUIDs 必须恰好包含合集中的每张图片一次.
+type ReorderAlbumRequest struct {
+	ImageUUIDs []string `json:"image_uuids"`
+}
@@ -0,0 +1,25 @@
+package api
+
+// BulkEditImagesRequest 是批量修改图片的请求. ImageUUIDs 和 Query 必须且只能给出一个：ImageUUIDs 直接列出图片，
+// Query 是与搜索接口语法相同的标签搜索语句，只匹配调用者自己的图片. AddTags 和 RemoveTags 的规范化与
+// UpdateImageTagsRequest 相同，Visibility 为空时不修改可见性. 不存在、不属于调用者或修改后标签数超过上限的图片被跳过.
+type BulkEditImagesRequest struct {
+	ImageUUIDs []string `json:"image_uuids"`
+	Query      string   `json:"query"`
+	AddTags    []string `json:"add_tags"`
+	RemoveTags []string `json:"remove_tags"`
+	Visibility string   `json:"visibility"`
+}
+
+// BulkJobInfo 是批量修改任务的状态. Total 是创建任务时选中的图片数，Processed 是已处理的图片数，
+// 其中 Skipped 张不存在、不属于调用者或修改后标签数超过上限而未修改.
+type BulkJobInfo struct {
+	ID        uint   `json:"id"`
+	Status    string `json:"status"`
+	Total     int64  `json:"total"`
+	Processed int64  `json:"processed"`
+	Skipped   int64  `json:"skipped"`
+	LastError string `json:"last_error,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
@@ -1,42 +1,238 @@
 package api
 
+import "encoding/json"
+
 type HasUserUUID interface {
 	GetUserUUID() string
 }
 
 type CreateImageRequest struct {
-	UserUUID string   `json:"owneruuid" valid:"required,uuidv4"`
-	IsPublic bool     `json:"is_public"`
-	Tags     []string `json:"tags"`
+	UserUUID string `json:"owneruuid" valid:"required,uuidv4"`
+	// Visibility 取值为 private、unlisted 或 public，为空时按 IsPublic 决定
+	Visibility string `json:"visibility"`
+	// IsPublic 是旧版本的可见性参数，只在未指定 Visibility 时生效
+	IsPublic    bool                   `json:"is_public"`
+	Tags        []string               `json:"tags"`
+	Description string                 `json:"description"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	// KeepGPS 为 true 时保存的原图保留 GPS 信息，默认会去除
+	KeepGPS bool `json:"keep_gps"`
 }
 
 func (req *CreateImageRequest) GetUserUUID() string {
 	return req.UserUUID
 }
 
-type CreateImageResponse ImageInfo
+// CreateImageResponse 是上传图片的结果，NearDuplicates 是上传者已有的近似图片.
+type CreateImageResponse struct {
+	ImageInfo
+	NearDuplicates []string `json:"near_duplicates,omitempty"`
+}
 
 type GetImageInfoResponse ImageInfo
 
 type ImageInfo struct {
-	ImageUUID string   `json:"imageuuid"`
-	Token     string   `json:"token"`
-	UserUUID  string   `json:"owneruuid"`
-	IsPublic  bool     `json:"is_public"`
-	Tags      []string `json:"tags"`
-	CreatedAt string   `json:"created_at"`
-	UpdatedAt string   `json:"updated_at"`
+	ImageUUID string `json:"imageuuid"`
+	Token     string `json:"token"`
+	UserUUID  string `json:"owneruuid"`
+	// Visibility 取值为 private、unlisted 或 public
+	Visibility string `json:"visibility"`
+	// IsPublic 等价于 Visibility 为 public，为兼容旧版本的客户端保留
+	IsPublic bool `json:"is_public"`
+	// Tags 是图片的全部标签，带命名空间的标签形如 artist:name
+	Tags []string `json:"tags"`
+	// TagGroups 是按命名空间分组的标签
+	TagGroups ImageTagGroups `json:"tag_groups"`
+	// Description 是图片的文字描述，最多 500 个字符
+	Description string `json:"description"`
+	// Metadata 是自由格式的元数据，供多模态模型等功能使用
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// Width、Height 是原图的像素尺寸，MimeType 是探测到的原图类型，Size 是原图的字节数
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	// Exif 是上传时从图片 EXIF 中提取的拍摄信息
+	Exif *ExifInfo `json:"exif,omitempty"`
+	// FormatStatus 是各转换格式的状态，取值为 processing、ready 或 failed
+	FormatStatus map[string]string `json:"format_status,omitempty"`
+	// VariantSizes 是已生成的各转换格式的字节数
+	VariantSizes  map[string]int64 `json:"variant_sizes,omitempty"`
+	FavoriteCount int64            `json:"favorite_count"`
+	CreatedAt     string           `json:"created_at"`
+	UpdatedAt     string           `json:"updated_at"`
+}
+
+// ExifInfo 是从图片 EXIF 中提取的拍摄信息. Orientation 是上传时的 EXIF 方向，保存的图片已经旋转为正向.
+type ExifInfo struct {
+	CameraMake  string `json:"camera_make,omitempty"`
+	CameraModel string `json:"camera_model,omitempty"`
+	LensModel   string `json:"lens_model,omitempty"`
+	CapturedAt  string `json:"captured_at,omitempty"`
+	Orientation int    `json:"orientation,omitempty"`
+}
+
+const (
+	// FormatStatusProcessing 表示该格式正在排队或正在生成.
+	FormatStatusProcessing = "processing"
+	// FormatStatusReady 表示该格式已经可以访问.
+	FormatStatusReady = "ready"
+	// FormatStatusFailed 表示该格式生成失败，访问时会回退到其他格式.
+	FormatStatusFailed = "failed"
+)
+
+// PatchImageRequest 是修改图片属性的请求，未出现的字段保持不变.
+// Metadata 为 null 时清空元数据，为对象时整体替换原有元数据.
+type PatchImageRequest struct {
+	Visibility  *string         `json:"visibility"`
+	Description *string         `json:"description"`
+	Metadata    json.RawMessage `json:"metadata"`
 }
 
+// UpdateImageTagsRequest 是追加、替换或删除图片标签的请求. 标签保存前会被规范化：去掉首尾空白，
+// 连续空白替换为下划线，默认转换为小写，只能包含字母、数字和 _-.:'()!?/+& 等字符.
+// artist:、character:、copyright:、meta: 开头的标签归入对应的命名空间.
 type UpdateImageTagsRequest struct {
 	Tags []string `json:"tags"`
 }
 
+// ListImageResponse 是图片列表. 按 offset 分页时 Count 是符合条件的总数；按游标分页时 Count 是本页的图片数，
+// NextCursor 用于读取下一页，为空表示没有更多图片，Total 只在请求 with_total 时返回.
 type ListImageResponse struct {
-	Count     int         `json:"count"`
-	ImageList []ImageInfo `json:"image_list"`
+	Count      int         `json:"count"`
+	ImageList  []ImageInfo `json:"image_list"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Total      *int64      `json:"total,omitempty"`
+}
+
+// CursorRange 是按游标分页的查询参数. Cursor 为上一页返回的 next_cursor，为空时从第一页开始.
+// 统计总数需要额外查询，WithTotal 为 true 时才返回 total.
+type CursorRange struct {
+	Cursor    string `form:"cursor"`
+	Limit     int    `form:"limit"`
+	WithTotal bool   `form:"with_total"`
+}
+
+// CursorPageRequest 是按游标分页列出用户图片的查询参数.
+type CursorPageRequest struct {
+	CursorRange
+	ImageListFilter
+}
+
+// ImageListFilter 是用户图片列表的过滤和排序条件. Tags 中的标签必须全部带有，ExcludeTags 中的标签都不能带有；
+// CreatedAfter 和 CreatedBefore 使用 RFC 3339 格式，限定创建时间的范围 [created_after, created_before)；
+// Sort 可选 newest（默认）、oldest、recently_updated 和 most_favorited.
+type ImageListFilter struct {
+	Visibility    string   `form:"visibility"`
+	Tags          []string `form:"tag"`
+	ExcludeTags   []string `form:"exclude_tag"`
+	CreatedAfter  string   `form:"created_after"`
+	CreatedBefore string   `form:"created_before"`
+	MimeType      string   `form:"mime_type"`
+	Sort          string   `form:"sort"`
 }
 
 type DeleteImageRequest struct {
 	ImageUUID string `json:"image_uuid" valid:"required,uuidv4"`
 }
+
+// PageRequest 是按页码分页的查询参数，page 从 1 开始.
+type PageRequest struct {
+	Page     int `form:"page"`
+	PageSize int `form:"page_size"`
+}
+
+// ImagePageResponse 是按页码分页的图片列表.
+type ImagePageResponse struct {
+	Total     int64       `json:"total"`
+	Page      int         `json:"page"`
+	PageSize  int         `json:"page_size"`
+	ImageList []ImageInfo `json:"image_list"`
+}
+
+// SearchImagesRequest 是按标签搜索图片的请求，Query 使用 booru 风格的语法. 指定 cursor 或 limit 时按游标分页，
+// 否则按页码分页.
+type SearchImagesRequest struct {
+	Query string `form:"q"`
+	PageRequest
+	CursorRange
+}
+
+type SearchImagesResponse ImagePageResponse
+
+type AddFavoriteRequest struct {
+	ImageUUID string `json:"image_uuid" valid:"required,uuidv4"`
+}
+
+// ListFavoritesRequest 是列出收藏的查询参数，指定 cursor 或 limit 时按游标分页，否则按页码分页.
+type ListFavoritesRequest struct {
+	PageRequest
+	CursorRange
+}
+
+type ListFavoritesResponse ImagePageResponse
+
+// DeleteImagesRequest 是批量删除图片的请求.
+type DeleteImagesRequest struct {
+	ImageUUIDs []string `json:"image_uuids"`
+}
+
+const (
+	// DeleteResultDeleted 表示图片已被删除.
+	DeleteResultDeleted = "deleted"
+	// DeleteResultNotFound 表示图片不存在或已被删除.
+	DeleteResultNotFound = "not_found"
+	// DeleteResultForbidden 表示图片不属于当前用户，未被删除.
+	DeleteResultForbidden = "forbidden"
+)
+
+// DeleteImageResult 是批量删除中单张图片的处理结果.
+type DeleteImageResult struct {
+	ImageUUID string `json:"image_uuid"`
+	Result    string `json:"result"`
+}
+
+type DeleteImagesResponse struct {
+	Results []DeleteImageResult `json:"results"`
+}
+
+// SimilarImagesRequest 是查询近似图片的参数，Distance 是允许的最大汉明距离，未指定时使用默认值.
+type SimilarImagesRequest struct {
+	Distance *int `form:"distance"`
+	Limit    int  `form:"limit"`
+}
+
+// SimilarImage 是一张近似图片及其差异哈希与查询图片的汉明距离.
+type SimilarImage struct {
+	ImageInfo
+	Distance int `json:"distance"`
+}
+
+type SimilarImagesResponse struct {
+	ImageList []SimilarImage `json:"image_list"`
+}
+
+// ImageTagGroups 是按命名空间分组的图片标签，组内的标签不带命名空间前缀，没有命名空间的标签在 General 中.
+type ImageTagGroups struct {
+	General   []string `json:"general,omitempty"`
+	Artist    []string `json:"artist,omitempty"`
+	Character []string `json:"character,omitempty"`
+	Copyright []string `json:"copyright,omitempty"`
+	Meta      []string `json:"meta,omitempty"`
+}
+
+// Add 将命名空间 namespace 下的标签 name 加入对应的分组.
+func (g *ImageTagGroups) Add(namespace string, name string) {
+	switch namespace {
+	case "artist":
+		g.Artist = append(g.Artist, name)
+	case "character":
+		g.Character = append(g.Character, name)
+	case "copyright":
+		g.Copyright = append(g.Copyright, name)
+	case "meta":
+		g.Meta = append(g.Meta, name)
+	default:
+		g.General = append(g.General, name)
+	}
+}
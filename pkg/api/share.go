@@ -0,0 +1,21 @@
+package api
+
+// CreateShareRequest 是创建分享链接的请求. ExpiresIn 是有效期的秒数，MaxViews 是允许读取图片文件的次数，为 0 表示不限制.
+type CreateShareRequest struct {
+	ExpiresIn int64 `json:"expires_in"`
+	MaxViews  int   `json:"max_views"`
+}
+
+// ShareInfo 是图片当前的分享链接. Views 是图片文件已被读取的次数.
+type ShareInfo struct {
+	ImageUUID string `json:"imageuuid"`
+	Token     string `json:"token"`
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	MaxViews  int    `json:"max_views"`
+	Views     int    `json:"views"`
+}
+
+type CreateShareResponse ShareInfo
+
+type GetShareResponse ShareInfo
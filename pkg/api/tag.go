@@ -0,0 +1,79 @@
+package api
+
+// SetTagAliasRequest 是将别名 Alias 改写为规范标签 Tag 的请求，别名已存在时修改其规范标签.
+type SetTagAliasRequest struct {
+	Alias string `json:"alias"`
+	Tag   string `json:"tag"`
+}
+
+// AddTagImplicationRequest 是添加“带有 Tag 的图片同时带有 Implies”规则的请求.
+type AddTagImplicationRequest struct {
+	Tag     string `json:"tag"`
+	Implies string `json:"implies"`
+}
+
+type TagAliasInfo struct {
+	Alias     string `json:"alias"`
+	Tag       string `json:"tag"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type TagImplicationInfo struct {
+	Tag       string `json:"tag"`
+	Implies   string `json:"implies"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ListTagAliasesResponse 是按页码分页的别名规则列表.
+type ListTagAliasesResponse struct {
+	Total     int64          `json:"total"`
+	Page      int            `json:"page"`
+	PageSize  int            `json:"page_size"`
+	AliasList []TagAliasInfo `json:"alias_list"`
+}
+
+// ListTagImplicationsResponse 是按页码分页的蕴含规则列表.
+type ListTagImplicationsResponse struct {
+	Total           int64                `json:"total"`
+	Page            int                  `json:"page"`
+	PageSize        int                  `json:"page_size"`
+	ImplicationList []TagImplicationInfo `json:"implication_list"`
+}
+
+// TagRewriteJobInfo 是按规则改写已有图片标签的后台任务的状态，Processed 是已处理的图片数.
+type TagRewriteJobInfo struct {
+	ID        uint   `json:"id"`
+	Tag       string `json:"tag"`
+	Status    string `json:"status"`
+	Processed int64  `json:"processed"`
+	LastError string `json:"last_error,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// TagRuleResponse 是修改规则后返回的改写任务.
+type TagRuleResponse struct {
+	Job TagRewriteJobInfo `json:"job"`
+}
+
+// ListTagsRequest 是标签目录的查询条件. Prefix 为空时列出全部标签，带命名空间时只匹配该命名空间下的标签.
+type ListTagsRequest struct {
+	Prefix string `form:"prefix"`
+	Limit  int    `form:"limit"`
+}
+
+// TagCountInfo 是标签及使用它的公开图片数. Tag 是带命名空间前缀的完整标签，Name 不带前缀，
+// Namespace 取值为 general、artist、character、copyright 或 meta.
+type TagCountInfo struct {
+	Tag       string `json:"tag"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Count     int64  `json:"count"`
+}
+
+// ListTagsResponse 是按公开图片数从多到少排列的标签.
+type ListTagsResponse struct {
+	Count   int            `json:"count"`
+	TagList []TagCountInfo `json:"tag_list"`
+}
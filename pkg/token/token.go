@@ -82,5 +82,8 @@ func ParseRequest(c *gin.Context) (string, error) {
 	t := strings.TrimPrefix(header, "Bearer ")
 
 	claims, err := ParseToken(t)
-	return claims.UserUUID, err
+	if err != nil {
+		return "", err
+	}
+	return claims.UserUUID, nil
 }
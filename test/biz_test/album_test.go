@@ -0,0 +1,116 @@
+package biz_test
+
+import (
+	"context"
+	"demo520/internal/520/biz"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlbum(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	albumBiz := biz.NewIBiz(store.NewStore(db)).Albums()
+	ctx := context.Background()
+
+	viewerReq, err := genNewUser(t, db, nil)
+	require.NoError(t, err)
+	viewerInfo, err := getUserBiz(db).Get(ctx, viewerReq.Email)
+	require.NoError(t, err)
+	viewerUUID := viewerInfo.UserUUID
+
+	public := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, public.ImageUUID)
+	secret := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, secret.ImageUUID)
+	require.NoError(t, db.Model(&model.ImageM{}).Where("imageUUID = ?", secret.ImageUUID).Update("visibility", model.VisibilityPrivate).Error)
+
+	_, err = albumBiz.Create(ctx, ownerUUID, &api.CreateAlbumRequest{Title: "   "})
+	assert.ErrorIs(t, err, errno.ErrAlbumTitleInvalid)
+
+	created, err := albumBiz.Create(ctx, ownerUUID, &api.CreateAlbumRequest{
+		Title:          " 旅行 ",
+		IsPublic:       true,
+		CoverImageUUID: secret.ImageUUID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "旅行", created.Title)
+	assert.Equal(t, secret.ImageUUID, created.CoverImageUUID)
+	defer albumBiz.Delete(ctx, ownerUUID, created.AlbumUUID)
+
+	// 其他用户不能把别人的私有图片加入自己的合集
+	other, err := albumBiz.Create(ctx, viewerUUID, &api.CreateAlbumRequest{Title: faker.Word()})
+	require.NoError(t, err)
+	defer albumBiz.Delete(ctx, viewerUUID, other.AlbumUUID)
+	err = albumBiz.AddImages(ctx, viewerUUID, other.AlbumUUID, &api.AddAlbumImagesRequest{ImageUUIDs: []string{secret.ImageUUID}})
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+
+	// 只有所有者可以修改合集
+	err = albumBiz.AddImages(ctx, viewerUUID, created.AlbumUUID, &api.AddAlbumImagesRequest{ImageUUIDs: []string{public.ImageUUID}})
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+	require.NoError(t, albumBiz.AddImages(ctx, ownerUUID, created.AlbumUUID, &api.AddAlbumImagesRequest{
+		ImageUUIDs: []string{secret.ImageUUID, public.ImageUUID},
+	}))
+
+	got, err := albumBiz.Get(ctx, ownerUUID, created.AlbumUUID, &api.PageRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), got.Images.Total)
+	require.Len(t, got.Images.ImageList, 2)
+	assert.Equal(t, secret.ImageUUID, got.Images.ImageList[0].ImageUUID)
+
+	// 非所有者看不到私有图片，也看不到私有图片作为封面
+	got, err = albumBiz.Get(ctx, viewerUUID, created.AlbumUUID, &api.PageRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), got.Images.Total)
+	require.Len(t, got.Images.ImageList, 1)
+	assert.Equal(t, public.ImageUUID, got.Images.ImageList[0].ImageUUID)
+	assert.Empty(t, got.CoverImageUUID)
+
+	err = albumBiz.Reorder(ctx, ownerUUID, created.AlbumUUID, &api.ReorderAlbumRequest{ImageUUIDs: []string{public.ImageUUID}})
+	assert.ErrorIs(t, err, errno.ErrAlbumOrderMismatch)
+	require.NoError(t, albumBiz.Reorder(ctx, ownerUUID, created.AlbumUUID, &api.ReorderAlbumRequest{
+		ImageUUIDs: []string{public.ImageUUID, secret.ImageUUID},
+	}))
+	got, err = albumBiz.Get(ctx, ownerUUID, created.AlbumUUID, &api.PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, got.Images.ImageList, 2)
+	assert.Equal(t, public.ImageUUID, got.Images.ImageList[0].ImageUUID)
+
+	// 设为私有后非所有者无法读取，也不会出现在列表中
+	isPublic := false
+	updated, err := albumBiz.Update(ctx, ownerUUID, created.AlbumUUID, &api.UpdateAlbumRequest{IsPublic: &isPublic})
+	require.NoError(t, err)
+	assert.False(t, updated.IsPublic)
+	_, err = albumBiz.Get(ctx, viewerUUID, created.AlbumUUID, &api.PageRequest{})
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+	_, err = albumBiz.Get(ctx, "", created.AlbumUUID, &api.PageRequest{})
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+
+	list, err := albumBiz.ListUserAlbums(ctx, viewerUUID, ownerUUID, &api.PageRequest{})
+	require.NoError(t, err)
+	for _, album := range list.AlbumList {
+		assert.NotEqual(t, created.AlbumUUID, album.AlbumUUID)
+	}
+	list, err = albumBiz.ListUserAlbums(ctx, ownerUUID, ownerUUID, &api.PageRequest{})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, list.Total, int64(1))
+
+	require.NoError(t, albumBiz.RemoveImage(ctx, ownerUUID, created.AlbumUUID, public.ImageUUID))
+	got, err = albumBiz.Get(ctx, ownerUUID, created.AlbumUUID, &api.PageRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), got.Images.Total)
+
+	require.NoError(t, albumBiz.Delete(ctx, ownerUUID, created.AlbumUUID))
+	_, err = albumBiz.Get(ctx, ownerUUID, created.AlbumUUID, &api.PageRequest{})
+	assert.ErrorIs(t, err, errno.ErrAlbumNotFound)
+}
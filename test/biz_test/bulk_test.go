@@ -0,0 +1,100 @@
+package biz_test
+
+import (
+	"context"
+	imagebiz "demo520/internal/520/biz/image"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"testing"
+	"time"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_BulkEdit(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ds := store.NewStore(db)
+	ctx := context.Background()
+	tag := "bulk" + faker.UUIDDigit()[:8]
+
+	otherReq, err := genNewUser(t, db, nil)
+	require.NoError(t, err)
+	otherInfo, err := getUserBiz(db).Get(ctx, otherReq.Email)
+	require.NoError(t, err)
+
+	mine := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, mine.ImageUUID)
+	others := create_new_image(t, db, otherInfo.UserUUID)
+	defer imageBiz.Delete(ctx, otherInfo.UserUUID, others.ImageUUID)
+
+	for _, r := range []*api.BulkEditImagesRequest{
+		{AddTags: []string{tag}},
+		{ImageUUIDs: []string{mine.ImageUUID}, Query: tag, AddTags: []string{tag}},
+		{ImageUUIDs: []string{mine.ImageUUID}},
+		{ImageUUIDs: []string{"not-a-uuid"}, AddTags: []string{tag}},
+		{ImageUUIDs: []string{mine.ImageUUID}, Visibility: "hidden"},
+		{ImageUUIDs: []string{mine.ImageUUID}, AddTags: []string{tag}, RemoveTags: []string{tag}},
+	} {
+		_, err := imageBiz.CreateBulkJob(ctx, ownerUUID, r)
+		assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+	}
+	_, err = imageBiz.CreateBulkJob(ctx, ownerUUID, &api.BulkEditImagesRequest{Query: "-", AddTags: []string{tag}})
+	assert.ErrorIs(t, err, errno.ErrInvalidSearchQuery)
+	_, err = imageBiz.CreateBulkJob(ctx, ownerUUID, &api.BulkEditImagesRequest{ImageUUIDs: []string{mine.ImageUUID}, AddTags: []string{"-bad"}})
+	assert.ErrorIs(t, err, errno.ErrInvalidTag)
+
+	// 按 UUID 选择，其他用户的图片被跳过
+	info, err := imageBiz.CreateBulkJob(ctx, ownerUUID, &api.BulkEditImagesRequest{
+		ImageUUIDs: []string{mine.ImageUUID, others.ImageUUID, mine.ImageUUID},
+		AddTags:    []string{tag},
+		Visibility: model.VisibilityPrivate,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.BulkJobPending, info.Status)
+	assert.Equal(t, int64(2), info.Total)
+	_, err = imageBiz.GetBulkJob(ctx, otherInfo.UserUUID, info.ID)
+	assert.ErrorIs(t, err, errno.ErrBulkJobNotFound)
+
+	job, err := ds.BulkJob().Get(ctx, info.ID)
+	require.NoError(t, err)
+	require.NoError(t, imagebiz.RunBulkJob(ctx, ds, job, time.Minute))
+	progress, err := imageBiz.GetBulkJob(ctx, ownerUUID, info.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), progress.Processed)
+	assert.Equal(t, int64(1), progress.Skipped)
+
+	got, err := imageBiz.Get(ctx, ownerUUID, mine.ImageUUID)
+	require.NoError(t, err)
+	assert.Contains(t, got.Tags, tag)
+	assert.Equal(t, model.VisibilityPrivate, got.Visibility)
+	got, err = imageBiz.Get(ctx, otherInfo.UserUUID, others.ImageUUID)
+	require.NoError(t, err)
+	assert.NotContains(t, got.Tags, tag)
+
+	// 按搜索语句选择，只匹配自己的图片
+	info, err = imageBiz.CreateBulkJob(ctx, ownerUUID, &api.BulkEditImagesRequest{
+		Query:      tag,
+		RemoveTags: []string{tag},
+		Visibility: model.VisibilityUnlisted,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), info.Total)
+	job, err = ds.BulkJob().Get(ctx, info.ID)
+	require.NoError(t, err)
+	require.NoError(t, imagebiz.RunBulkJob(ctx, ds, job, time.Minute))
+	assert.Equal(t, int64(1), job.Processed)
+	assert.Zero(t, job.Skipped)
+
+	got, err = imageBiz.Get(ctx, ownerUUID, mine.ImageUUID)
+	require.NoError(t, err)
+	assert.NotContains(t, got.Tags, tag)
+	assert.Equal(t, model.VisibilityUnlisted, got.Visibility)
+}
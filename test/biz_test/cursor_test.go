@@ -0,0 +1,207 @@
+package biz_test
+
+import (
+	"context"
+	"demo520/internal/520/biz"
+	imagebiz "demo520/internal/520/biz/image"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"strings"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_ListByCursor(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+
+	created := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		image := create_new_image(t, db, ownerUUID)
+		defer imageBiz.Delete(ctx, ownerUUID, image.ImageUUID)
+		created[image.ImageUUID] = true
+	}
+
+	// 按 next_cursor 翻页直到最后一页，每张图片恰好出现一次
+	seen := make(map[string]bool)
+	r := &api.CursorPageRequest{CursorRange: api.CursorRange{Limit: 2, WithTotal: true}}
+	for {
+		resp, err := imageBiz.ListUserOwnImagesByCursor(ctx, ownerUUID, r)
+		require.NoError(t, err)
+		require.NotNil(t, resp.Total)
+		assert.Equal(t, int64(len(created)), *resp.Total)
+		assert.Equal(t, len(resp.ImageList), resp.Count)
+		for _, image := range resp.ImageList {
+			assert.False(t, seen[image.ImageUUID])
+			seen[image.ImageUUID] = true
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		r = &api.CursorPageRequest{CursorRange: api.CursorRange{Cursor: resp.NextCursor, Limit: 2}}
+	}
+	assert.Equal(t, created, seen)
+
+	// 不要求总数时不返回 total
+	resp, err := imageBiz.ListUserOwnPublicImagesByCursor(ctx, ownerUUID, &api.CursorPageRequest{CursorRange: api.CursorRange{Limit: 10}})
+	require.NoError(t, err)
+	assert.Nil(t, resp.Total)
+	assert.Empty(t, resp.NextCursor)
+
+	_, err = imageBiz.ListUserOwnImagesByCursor(ctx, ownerUUID, &api.CursorPageRequest{CursorRange: api.CursorRange{Cursor: "not-a-cursor", Limit: 2}})
+	assert.ErrorIs(t, err, errno.ErrInvalidCursor)
+
+	// offset 分页与游标分页的 limit 范围相同
+	page, err := imageBiz.ListUserOwnImages(ctx, ownerUUID, 0, 2, nil)
+	require.NoError(t, err)
+	assert.Len(t, page.ImageList, 2)
+	_, err = imageBiz.ListUserOwnImages(ctx, ownerUUID, 0, 101, nil)
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+	_, err = imageBiz.ListUserOwnImagesByCursor(ctx, ownerUUID, &api.CursorPageRequest{CursorRange: api.CursorRange{Limit: 101}})
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+}
+
+func TestImage_ListFilter(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+
+	public := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, public.ImageUUID)
+	private := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, private.ImageUUID)
+	require.NoError(t, db.Model(&model.ImageM{}).Where("imageUUID = ?", private.ImageUUID).Update("visibility", model.VisibilityPrivate).Error)
+
+	resp, err := imageBiz.ListUserOwnImages(ctx, ownerUUID, 0, 10, &api.ImageListFilter{Visibility: model.VisibilityPrivate})
+	require.NoError(t, err)
+	require.Len(t, resp.ImageList, 1)
+	assert.Equal(t, private.ImageUUID, resp.ImageList[0].ImageUUID)
+
+	// 过滤在 SQL 中完成，分页大小不受影响
+	page, err := imageBiz.ListUserOwnImagesByCursor(ctx, ownerUUID, &api.CursorPageRequest{
+		CursorRange:     api.CursorRange{Limit: 1},
+		ImageListFilter: api.ImageListFilter{Visibility: model.VisibilityPublic},
+	})
+	require.NoError(t, err)
+	require.Len(t, page.ImageList, 1)
+	assert.Equal(t, public.ImageUUID, page.ImageList[0].ImageUUID)
+
+	// 游标只能用于生成它的排序方式
+	page, err = imageBiz.ListUserOwnImagesByCursor(ctx, ownerUUID, &api.CursorPageRequest{
+		CursorRange:     api.CursorRange{Limit: 1},
+		ImageListFilter: api.ImageListFilter{Sort: "oldest"},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, page.NextCursor)
+	_, err = imageBiz.ListUserOwnImagesByCursor(ctx, ownerUUID, &api.CursorPageRequest{
+		CursorRange:     api.CursorRange{Cursor: page.NextCursor, Limit: 1},
+		ImageListFilter: api.ImageListFilter{Sort: "most_favorited"},
+	})
+	assert.ErrorIs(t, err, errno.ErrInvalidCursor)
+
+	for _, filter := range []*api.ImageListFilter{
+		{Sort: "random"},
+		{Visibility: "everyone"},
+		{CreatedAfter: "yesterday"},
+		{CreatedAfter: "2024-02-01T00:00:00Z", CreatedBefore: "2024-01-01T00:00:00Z"},
+	} {
+		_, err = imageBiz.ListUserOwnPublicImages(ctx, ownerUUID, 0, 10, filter)
+		assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+	}
+	_, err = imageBiz.ListUserOwnPublicImages(ctx, ownerUUID, 0, 10, &api.ImageListFilter{Tags: []string{" "}})
+	assert.ErrorIs(t, err, errno.ErrInvalidTag)
+}
+
+// collectCursorPages 按 next_cursor 翻页直到最后一页，返回按顺序出现的图片.
+func collectCursorPages(t *testing.T, list func(r *api.CursorRange) (*api.ListImageResponse, error)) []string {
+	var ret []string
+	r := &api.CursorRange{Limit: 1, WithTotal: true}
+	for {
+		resp, err := list(r)
+		require.NoError(t, err)
+		require.NotNil(t, resp.Total)
+		for _, image := range resp.ImageList {
+			ret = append(ret, image.ImageUUID)
+		}
+		if resp.NextCursor == "" {
+			return ret
+		}
+		r = &api.CursorRange{Cursor: resp.NextCursor, Limit: 1, WithTotal: true}
+	}
+}
+
+func TestCursor_SearchFavoritesAlbums(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	albumBiz := biz.NewIBiz(store.NewStore(db)).Albums()
+	ctx := context.Background()
+
+	tag := "cursor_" + strings.ToLower(faker.UUIDDigit()[:8])
+	var imageUUIDs []string
+	for i := 0; i < 3; i++ {
+		image := create_new_image(t, db, ownerUUID)
+		defer imageBiz.Delete(ctx, ownerUUID, image.ImageUUID)
+		require.NoError(t, imageBiz.UpdateTags(ctx, ownerUUID, image.ImageUUID, &api.UpdateImageTagsRequest{Tags: []string{tag}}))
+		require.NoError(t, imageBiz.AddFavorite(ctx, ownerUUID, image.ImageUUID))
+		imageUUIDs = append(imageUUIDs, image.ImageUUID)
+	}
+
+	found := collectCursorPages(t, func(r *api.CursorRange) (*api.ListImageResponse, error) {
+		return imageBiz.SearchByCursor(ctx, ownerUUID, &api.SearchImagesRequest{Query: tag, CursorRange: *r})
+	})
+	assert.ElementsMatch(t, imageUUIDs, found)
+
+	favorites := collectCursorPages(t, func(r *api.CursorRange) (*api.ListImageResponse, error) {
+		return imageBiz.ListFavoritesByCursor(ctx, ownerUUID, r)
+	})
+	assert.ElementsMatch(t, imageUUIDs, favorites)
+
+	album, err := albumBiz.Create(ctx, ownerUUID, &api.CreateAlbumRequest{Title: faker.Word()})
+	require.NoError(t, err)
+	defer albumBiz.Delete(ctx, ownerUUID, album.AlbumUUID)
+	require.NoError(t, albumBiz.AddImages(ctx, ownerUUID, album.AlbumUUID, &api.AddAlbumImagesRequest{ImageUUIDs: imageUUIDs}))
+
+	// 合集中的图片按合集中的顺序翻页
+	inAlbum := collectCursorPages(t, func(r *api.CursorRange) (*api.ListImageResponse, error) {
+		return albumBiz.ListImagesByCursor(ctx, ownerUUID, album.AlbumUUID, r)
+	})
+	assert.Equal(t, imageUUIDs, inAlbum)
+
+	second, err := albumBiz.Create(ctx, ownerUUID, &api.CreateAlbumRequest{Title: faker.Word()})
+	require.NoError(t, err)
+	defer albumBiz.Delete(ctx, ownerUUID, second.AlbumUUID)
+	albums, err := albumBiz.ListUserAlbumsByCursor(ctx, ownerUUID, ownerUUID, &api.CursorRange{Limit: 1, WithTotal: true})
+	require.NoError(t, err)
+	require.Len(t, albums.AlbumList, 1)
+	require.NotNil(t, albums.Total)
+	assert.Equal(t, int64(2), *albums.Total)
+	require.NotEmpty(t, albums.NextCursor)
+	next, err := albumBiz.ListUserAlbumsByCursor(ctx, ownerUUID, ownerUUID, &api.CursorRange{Cursor: albums.NextCursor, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, next.AlbumList, 1)
+	assert.NotEqual(t, albums.AlbumList[0].AlbumUUID, next.AlbumList[0].AlbumUUID)
+	assert.Empty(t, next.NextCursor)
+
+	// 游标不能跨列表使用
+	_, err = albumBiz.ListImagesByCursor(ctx, ownerUUID, album.AlbumUUID, &api.CursorRange{Cursor: albums.NextCursor, Limit: 1})
+	assert.ErrorIs(t, err, errno.ErrInvalidCursor)
+
+	// 页码和游标参数不能同时使用
+	_, err = imagebiz.IsCursorPage(&api.PageRequest{Page: 2}, &api.CursorRange{Limit: 10})
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+}
@@ -0,0 +1,114 @@
+package biz_test
+
+import (
+	"bytes"
+	"context"
+	"demo520/internal/520/biz/image"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"encoding/binary"
+	goimage "image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGPSDatum 是写入测试图片 GPS 信息中的标记，用于检查保存的原图是否仍然包含 GPS 信息.
+const testGPSDatum = "HOMEBASE1"
+
+// makeExifJPEG 生成一张 40x20 的 JPEG，EXIF 中包含相机厂商、方向 6（需顺时针旋转 90 度）以及 GPS 信息.
+func makeExifJPEG(t *testing.T) []byte {
+	img := goimage.NewRGBA(goimage.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 6), G: uint8(y * 12), B: 128, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 90}))
+
+	// TIFF 结构：头部(8) | IFD0(42) | Make(8) | GPS IFD(30) | GPSMapDatum(10)
+	le := binary.LittleEndian
+	var tiff bytes.Buffer
+	write := func(v interface{}) { require.NoError(t, binary.Write(&tiff, le, v)) }
+	entry := func(tag, typ uint16, count, value uint32) {
+		write(tag)
+		write(typ)
+		write(count)
+		write(value)
+	}
+	tiff.WriteString("II")
+	write(uint16(42))
+	write(uint32(8))
+	// IFD0
+	write(uint16(3))
+	entry(0x010F, 2, 8, 50) // Make
+	entry(0x0112, 3, 1, 6)  // Orientation
+	entry(0x8825, 4, 1, 58) // GPS IFD
+	write(uint32(0))
+	tiff.WriteString("TestCam\x00")
+	// GPS IFD
+	write(uint16(2))
+	entry(0x0000, 1, 4, 0x00000202)                   // GPSVersionID 2.2.0.0
+	entry(0x0012, 2, uint32(len(testGPSDatum)+1), 88) // GPSMapDatum
+	write(uint32(0))
+	tiff.WriteString(testGPSDatum + "\x00")
+
+	var out bytes.Buffer
+	out.Write(encoded.Bytes()[:2]) // SOI
+	out.Write([]byte{0xFF, 0xE1})
+	require.NoError(t, binary.Write(&out, binary.BigEndian, uint16(2+6+tiff.Len())))
+	out.WriteString("Exif\x00\x00")
+	out.Write(tiff.Bytes())
+	out.Write(encoded.Bytes()[2:])
+	return out.Bytes()
+}
+
+// readOriginal 读取图片保存的原图.
+func readOriginal(t *testing.T, hash string) []byte {
+	file, err := image.NewImageFileStore().Open(context.Background(), hash, image.VariantOriginal)
+	require.NoError(t, err)
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	require.NoError(t, err)
+	return data
+}
+
+func TestImage_Create_EXIF(t *testing.T) {
+	setViper()
+	db, _, userUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+	data := makeExifJPEG(t)
+
+	resp, err := imageBiz.Create(ctx, userUUID, &api.CreateImageRequest{UserUUID: userUUID}, makeFileHeader(t, "exif.jpg", "image/jpeg", data))
+	require.NoError(t, err)
+	defer imageBiz.Delete(ctx, userUUID, resp.ImageUUID)
+	require.NotNil(t, resp.Exif)
+	assert.Equal(t, "TestCam", resp.Exif.CameraMake)
+	assert.Equal(t, 6, resp.Exif.Orientation)
+	// 保存的原图已经旋转为正向
+	assert.Equal(t, 20, resp.Width)
+	assert.Equal(t, 40, resp.Height)
+
+	var stripped model.ImageM
+	require.NoError(t, db.First(&stripped, "imageUUID = ?", resp.ImageUUID).Error)
+	original := readOriginal(t, stripped.Hash)
+	assert.NotContains(t, string(original), testGPSDatum)
+	assert.Contains(t, string(original), "TestCam")
+
+	// 选择保留 GPS 时原图包含 GPS 信息，内容不同因此不会与去除 GPS 的版本共享文件
+	kept, err := imageBiz.Create(ctx, userUUID, &api.CreateImageRequest{UserUUID: userUUID, KeepGPS: true}, makeFileHeader(t, "exif.jpg", "image/jpeg", data))
+	require.NoError(t, err)
+	defer imageBiz.Delete(ctx, userUUID, kept.ImageUUID)
+	var keptM model.ImageM
+	require.NoError(t, db.First(&keptM, "imageUUID = ?", kept.ImageUUID).Error)
+	assert.NotEqual(t, stripped.Hash, keptM.Hash)
+	assert.Contains(t, string(readOriginal(t, keptM.Hash)), testGPSDatum)
+}
@@ -7,13 +7,18 @@ import (
 	"demo520/internal/520/biz/image"
 	"demo520/internal/520/biz/user"
 	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
 	"demo520/internal/pkg/model"
 	"demo520/pkg/api"
 	"fmt"
+	goimage "image"
+	"image/color"
+	"image/png"
 	"mime/multipart"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 
@@ -47,12 +52,16 @@ func setupImageDatabase() (*gorm.DB, *api.CreateUserRequest, string, error) {
 	if err := db.AutoMigrate(&model.UserM{}); err != nil {
 		return nil, nil, "", err
 	}
-	if err := db.AutoMigrate(&model.ImageM{}); err != nil {
+	// images 表需要经过 store.Migrate 完成可见性等数据迁移
+	if err := store.Migrate(db); err != nil {
 		return nil, nil, "", err
 	}
 	if err := db.AutoMigrate(&model.ImageTagM{}); err != nil {
 		return nil, nil, "", err
 	}
+	if err := db.AutoMigrate(&model.BlobM{}, &model.ConvertJobM{}, &model.FavoriteM{}, &model.AlbumM{}, &model.AlbumImageM{}, &model.ShareAccessM{}); err != nil {
+		return nil, nil, "", err
+	}
 	userReq, err := genNewUser(nil, db, nil)
 	if err != nil {
 		return nil, nil, "", err
@@ -198,6 +207,42 @@ func TestImage_Create_Success(t *testing.T) {
 	assert.Equal(t, createImageResp.IsPublic, createImageReq.IsPublic)
 	assert.Equal(t, createImageResp.UserUUID, createImageReq.UserUUID)
 	assert.Equal(t, createImageResp.Tags, createImageReq.Tags)
+	assert.Positive(t, createImageResp.Width)
+	assert.Positive(t, createImageResp.Height)
+	assert.Equal(t, "image/png", createImageResp.MimeType)
+	assert.Equal(t, int64(len(imageByte)), createImageResp.Size)
+}
+
+func TestImage_Create_DetectsFormat(t *testing.T) {
+	setViper()
+	db, _, userUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+
+	// 随机颜色使 hash 不与其他测试的图片重复
+	img := goimage.NewRGBA(goimage.Rect(0, 0, 16, 16))
+	fill := color.RGBA{R: uint8(rand.Intn(256)), G: uint8(rand.Intn(256)), B: uint8(rand.Intn(256)), A: 255}
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			img.Set(x, y, fill)
+		}
+	}
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, img))
+
+	// 以 .webp 命名的 PNG 仍按 PNG 保存，不会与 WebP 转换结果混淆
+	resp, err := imageBiz.Create(ctx, userUUID, &api.CreateImageRequest{UserUUID: userUUID},
+		makeFileHeader(t, "disguised.webp", "image/webp", encoded.Bytes()))
+	require.NoError(t, err)
+	defer imageBiz.Delete(ctx, userUUID, resp.ImageUUID)
+	var imageM model.ImageM
+	require.NoError(t, db.First(&imageM, "imageUUID = ?", resp.ImageUUID).Error)
+	file, err := image.NewImageFileStore().Open(ctx, imageM.Hash, image.VariantOriginal)
+	require.NoError(t, err)
+	defer file.Close()
+	assert.Equal(t, "image/png", file.ContentType)
 }
 
 func TestImage_Del_Success(t *testing.T) {
@@ -212,6 +257,174 @@ func TestImage_Del_Success(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestImage_DeleteCollection(t *testing.T) {
+	setViper()
+	db, _, userUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+
+	// 另一个用户的图片不能被删除
+	otherReq, err := genNewUser(t, db, nil)
+	require.NoError(t, err)
+	otherInfo, err := getUserBiz(db).Get(ctx, otherReq.Email)
+	require.NoError(t, err)
+	otherImage := create_new_image(t, db, otherInfo.UserUUID)
+	defer imageBiz.Delete(ctx, otherInfo.UserUUID, otherImage.ImageUUID)
+
+	own := create_new_imageList(t, db, userUUID)
+	missing := faker.UUIDHyphenated()
+	imageUUIDs := []string{own[0].ImageUUID, otherImage.ImageUUID, missing}
+	for _, image := range own[1:] {
+		imageUUIDs = append(imageUUIDs, image.ImageUUID)
+	}
+	// 重复的 UUID 只处理一次
+	imageUUIDs = append(imageUUIDs, own[0].ImageUUID)
+
+	resp, err := imageBiz.DeleteCollection(ctx, userUUID, imageUUIDs)
+	require.NoError(t, err)
+	require.Len(t, resp.Results, len(own)+2)
+	assert.Equal(t, api.DeleteImageResult{ImageUUID: own[0].ImageUUID, Result: api.DeleteResultDeleted}, resp.Results[0])
+	assert.Equal(t, api.DeleteImageResult{ImageUUID: otherImage.ImageUUID, Result: api.DeleteResultForbidden}, resp.Results[1])
+	assert.Equal(t, api.DeleteImageResult{ImageUUID: missing, Result: api.DeleteResultNotFound}, resp.Results[2])
+	for _, result := range resp.Results[3:] {
+		assert.Equal(t, api.DeleteResultDeleted, result.Result)
+	}
+
+	for _, image := range own {
+		_, err := imageBiz.Get(ctx, userUUID, image.ImageUUID)
+		assert.ErrorIs(t, err, errno.ErrImageNotFound)
+		var tagCount int64
+		require.NoError(t, db.Model(&model.ImageTagM{}).Where("imageUUID = ?", image.ImageUUID).Count(&tagCount).Error)
+		assert.Equal(t, int64(0), tagCount)
+	}
+	_, err = imageBiz.Get(ctx, otherInfo.UserUUID, otherImage.ImageUUID)
+	assert.NoError(t, err)
+
+	// 再次删除时全部为 not_found
+	resp, err = imageBiz.DeleteCollection(ctx, userUUID, []string{own[0].ImageUUID})
+	require.NoError(t, err)
+	assert.Equal(t, api.DeleteResultNotFound, resp.Results[0].Result)
+
+	_, err = imageBiz.DeleteCollection(ctx, userUUID, nil)
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+	_, err = imageBiz.DeleteCollection(ctx, userUUID, []string{"not-a-uuid"})
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+}
+
+func TestImage_Favorites(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+
+	fanReq, err := genNewUser(t, db, nil)
+	require.NoError(t, err)
+	fanInfo, err := getUserBiz(db).Get(ctx, fanReq.Email)
+	require.NoError(t, err)
+	fanUUID := fanInfo.UserUUID
+
+	imageInfo := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, imageInfo.ImageUUID)
+	// create_new_image 创建的是公开图片
+	require.True(t, imageInfo.IsPublic)
+
+	// 重复收藏只记一次
+	require.NoError(t, imageBiz.AddFavorite(ctx, fanUUID, imageInfo.ImageUUID))
+	require.NoError(t, imageBiz.AddFavorite(ctx, fanUUID, imageInfo.ImageUUID))
+	getInfo, err := imageBiz.Get(ctx, fanUUID, imageInfo.ImageUUID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), getInfo.FavoriteCount)
+
+	list, err := imageBiz.ListFavorites(ctx, fanUUID, &api.PageRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), list.Total)
+	require.Len(t, list.ImageList, 1)
+	assert.Equal(t, imageInfo.ImageUUID, list.ImageList[0].ImageUUID)
+	assert.Equal(t, int64(1), list.ImageList[0].FavoriteCount)
+
+	// 图片被设为私有后从收藏列表中消失，也不能再被收藏
+	require.NoError(t, db.Model(&model.ImageM{}).Where("imageUUID = ?", imageInfo.ImageUUID).Update("visibility", model.VisibilityPrivate).Error)
+	list, err = imageBiz.ListFavorites(ctx, fanUUID, &api.PageRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), list.Total)
+	assert.Empty(t, list.ImageList)
+	assert.ErrorIs(t, imageBiz.AddFavorite(ctx, fanUUID, imageInfo.ImageUUID), errno.ErrUnauthorized)
+	assert.ErrorIs(t, imageBiz.AddFavorite(ctx, ownerUUID, faker.UUIDHyphenated()), errno.ErrImageNotFound)
+
+	// 所有者仍然可以收藏自己的私有图片
+	require.NoError(t, imageBiz.AddFavorite(ctx, ownerUUID, imageInfo.ImageUUID))
+	list, err = imageBiz.ListFavorites(ctx, ownerUUID, &api.PageRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), list.Total)
+
+	require.NoError(t, imageBiz.RemoveFavorite(ctx, fanUUID, imageInfo.ImageUUID))
+	require.NoError(t, imageBiz.RemoveFavorite(ctx, fanUUID, imageInfo.ImageUUID))
+	getInfo, err = imageBiz.Get(ctx, ownerUUID, imageInfo.ImageUUID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), getInfo.FavoriteCount)
+
+	_, err = imageBiz.ListFavorites(ctx, fanUUID, &api.PageRequest{PageSize: 101})
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+}
+
+func TestImage_Patch(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+
+	imageInfo := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, imageInfo.ImageUUID)
+
+	description := "海边的日落"
+	resp, err := imageBiz.Patch(ctx, ownerUUID, imageInfo.ImageUUID, &api.PatchImageRequest{
+		Description: &description,
+		Metadata:    []byte(`{"caption":"sunset","objects":["sea","sun"],"score":0.9}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, description, resp.Description)
+	assert.Equal(t, "sunset", resp.Metadata["caption"])
+	assert.Equal(t, []interface{}{"sea", "sun"}, resp.Metadata["objects"])
+
+	// 未出现的字段保持不变
+	resp, err = imageBiz.Patch(ctx, ownerUUID, imageInfo.ImageUUID, &api.PatchImageRequest{
+		Metadata: []byte(`{"caption":"dusk"}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, description, resp.Description)
+	assert.Equal(t, map[string]interface{}{"caption": "dusk"}, resp.Metadata)
+
+	// null 清空元数据
+	resp, err = imageBiz.Patch(ctx, ownerUUID, imageInfo.ImageUUID, &api.PatchImageRequest{
+		Metadata: []byte(`null`),
+	})
+	require.NoError(t, err)
+	assert.Nil(t, resp.Metadata)
+
+	tooLong := strings.Repeat("长", 501)
+	_, err = imageBiz.Patch(ctx, ownerUUID, imageInfo.ImageUUID, &api.PatchImageRequest{Description: &tooLong})
+	assert.ErrorIs(t, err, errno.ErrImageDescriptionInvalid)
+	_, err = imageBiz.Patch(ctx, ownerUUID, imageInfo.ImageUUID, &api.PatchImageRequest{Metadata: []byte(`[1,2]`)})
+	assert.ErrorIs(t, err, errno.ErrImageMetadataInvalid)
+	deep := strings.Repeat(`{"a":`, 10) + "1" + strings.Repeat("}", 10)
+	_, err = imageBiz.Patch(ctx, ownerUUID, imageInfo.ImageUUID, &api.PatchImageRequest{Metadata: []byte(deep)})
+	assert.ErrorIs(t, err, errno.ErrImageMetadataInvalid)
+
+	// 非所有者不能修改
+	otherReq, err := genNewUser(t, db, nil)
+	require.NoError(t, err)
+	otherInfo, err := getUserBiz(db).Get(ctx, otherReq.Email)
+	require.NoError(t, err)
+	_, err = imageBiz.Patch(ctx, otherInfo.UserUUID, imageInfo.ImageUUID, &api.PatchImageRequest{Description: &description})
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+}
+
 func TestImage_Get_Success(t *testing.T) {
 	setViper()
 	db, _, userUUID, err := setupImageDatabase()
@@ -273,7 +486,7 @@ func TestImage_ListUserOwnImages(t *testing.T) {
 			imageBiz.Delete(ctx, userUUID, img.ImageUUID)
 		}
 	}()
-	imageListResp, err := imageBiz.ListUserOwnImages(ctx, userUUID, 0, len(imageInfo))
+	imageListResp, err := imageBiz.ListUserOwnImages(ctx, userUUID, 0, len(imageInfo), nil)
 	require.NoError(t, err)
 	assert.Equal(t, len(imageListResp.ImageList), len(imageInfo))
 	assert.Equal(t, imageListResp.Count, len(imageInfo))
@@ -291,7 +504,13 @@ func TestImage_ListUserOwnImages(t *testing.T) {
 		assert.Equal(t, imageInfo[index].UpdatedAt, imageMap[imageInfo[index].ImageUUID].UpdatedAt)
 	}
 
-	if _, err := imageBiz.ListUserOwnImages(ctx, faker.UUIDHyphenated(), 0, 10); err != nil {
+	// 超出最后一页时返回空列表
+	pastEnd, err := imageBiz.ListUserOwnImages(ctx, userUUID, len(imageInfo), 10, nil)
+	require.NoError(t, err)
+	assert.Empty(t, pastEnd.ImageList)
+	assert.Equal(t, len(imageInfo), pastEnd.Count)
+
+	if _, err := imageBiz.ListUserOwnImages(ctx, faker.UUIDHyphenated(), 0, 10, nil); err != nil {
 
 	}
 }
@@ -315,7 +534,7 @@ func TestImage_ListUserOwnPublicImages(t *testing.T) {
 			public_count++
 		}
 	}
-	imageListResp, err := imageBiz.ListUserOwnPublicImages(ctx, userUUID, 0, len(imageInfo))
+	imageListResp, err := imageBiz.ListUserOwnPublicImages(ctx, userUUID, 0, len(imageInfo), nil)
 	require.NoError(t, err)
 	assert.Equal(t, public_count, len(imageListResp.ImageList))
 	assert.Equal(t, public_count, len(imageListResp.ImageList))
@@ -333,7 +552,7 @@ func TestImage_ListUserOwnPublicImages(t *testing.T) {
 		assert.Equal(t, imageInfo[index].UpdatedAt, imageMap[imageInfo[index].ImageUUID].UpdatedAt)
 	}
 
-	if _, err := imageBiz.ListUserOwnPublicImages(ctx, faker.UUIDHyphenated(), 0, 10); err != nil {
+	if _, err := imageBiz.ListUserOwnPublicImages(ctx, faker.UUIDHyphenated(), 0, 10, nil); err != nil {
 
 	}
 }
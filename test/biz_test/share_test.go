@@ -0,0 +1,102 @@
+package biz_test
+
+import (
+	"context"
+	imagebiz "demo520/internal/520/biz/image"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_Share(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+	client := imagebiz.ShareClient{IP: "192.0.2.1", UserAgent: "share-test"}
+	variants := []imagebiz.Variant{imagebiz.VariantOriginal}
+
+	otherReq, err := genNewUser(t, db, nil)
+	require.NoError(t, err)
+	otherInfo, err := getUserBiz(db).Get(ctx, otherReq.Email)
+	require.NoError(t, err)
+
+	imageInfo := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, imageInfo.ImageUUID)
+	require.NoError(t, db.Model(&model.ImageM{}).Where("imageUUID = ?", imageInfo.ImageUUID).Update("visibility", model.VisibilityPrivate).Error)
+
+	// 只有所有者可以分享图片
+	_, err = imageBiz.CreateShare(ctx, otherInfo.UserUUID, imageInfo.ImageUUID, &api.CreateShareRequest{})
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+	_, err = imageBiz.GetShare(ctx, ownerUUID, imageInfo.ImageUUID)
+	assert.ErrorIs(t, err, errno.ErrShareLinkNotFound)
+	_, err = imageBiz.CreateShare(ctx, ownerUUID, imageInfo.ImageUUID, &api.CreateShareRequest{MaxViews: -1})
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+	_, err = imageBiz.CreateShare(ctx, ownerUUID, imageInfo.ImageUUID, &api.CreateShareRequest{ExpiresIn: math.MaxInt64})
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+
+	share, err := imageBiz.CreateShare(ctx, ownerUUID, imageInfo.ImageUUID, &api.CreateShareRequest{ExpiresIn: 3600, MaxViews: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "/s/"+share.Token, share.URL)
+	assert.NotEmpty(t, share.ExpiresAt)
+
+	// 匿名用户凭令牌可以读取私有图片，令牌不会出现在图片信息中
+	info, err := imageBiz.GetShared(ctx, share.Token, client)
+	require.NoError(t, err)
+	assert.Equal(t, imageInfo.ImageUUID, info.ImageUUID)
+	assert.Empty(t, info.Token)
+
+	// 分段读取的后续分段不计入访问次数，但必须先读取过文件开头
+	ranged := client
+	ranged.Continuation = true
+	_, err = imageBiz.GetSharedFile(ctx, share.Token, variants, ranged)
+	assert.ErrorIs(t, err, errno.ErrShareLinkExpired)
+	for i := 0; i < 2; i++ {
+		file, err := imageBiz.GetSharedFile(ctx, share.Token, variants, client)
+		require.NoError(t, err)
+		file.Close()
+		file, err = imageBiz.GetSharedFile(ctx, share.Token, variants, ranged)
+		require.NoError(t, err)
+		file.Close()
+	}
+	_, err = imageBiz.GetSharedFile(ctx, share.Token, variants, client)
+	assert.ErrorIs(t, err, errno.ErrShareLinkExpired)
+	_, err = imageBiz.GetShared(ctx, share.Token, client)
+	assert.ErrorIs(t, err, errno.ErrShareLinkExpired)
+
+	got, err := imageBiz.GetShare(ctx, ownerUUID, imageInfo.ImageUUID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Views)
+
+	var accesses int64
+	require.NoError(t, db.Model(&model.ShareAccessM{}).Where("token = ?", share.Token).Count(&accesses).Error)
+	assert.Equal(t, int64(5), accesses)
+
+	// 重新生成令牌后旧令牌失效
+	renewed, err := imageBiz.CreateShare(ctx, ownerUUID, imageInfo.ImageUUID, &api.CreateShareRequest{})
+	require.NoError(t, err)
+	assert.NotEqual(t, share.Token, renewed.Token)
+	_, err = imageBiz.GetShared(ctx, share.Token, client)
+	assert.ErrorIs(t, err, errno.ErrShareLinkNotFound)
+
+	// 过期的令牌不能再读取
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, db.Model(&model.ImageM{}).Where("imageUUID = ?", imageInfo.ImageUUID).Update("share_expires_at", past).Error)
+	_, err = imageBiz.GetSharedFile(ctx, renewed.Token, variants, client)
+	assert.ErrorIs(t, err, errno.ErrShareLinkExpired)
+
+	require.NoError(t, imageBiz.RevokeShare(ctx, ownerUUID, imageInfo.ImageUUID))
+	_, err = imageBiz.GetShared(ctx, renewed.Token, client)
+	assert.ErrorIs(t, err, errno.ErrShareLinkNotFound)
+	_, err = imageBiz.GetShared(ctx, faker.Word(), client)
+	assert.ErrorIs(t, err, errno.ErrShareLinkNotFound)
+}
@@ -0,0 +1,71 @@
+package biz_test
+
+import (
+	"bytes"
+	"context"
+	"demo520/internal/pkg/errno"
+	"demo520/pkg/api"
+	goimage "image"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// halveImage 将 PNG 图片缩小为一半后重新编码，得到内容相同但 SHA-256 不同的图片.
+func halveImage(t *testing.T, data []byte) []byte {
+	src, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	bounds := src.Bounds()
+	dst := goimage.NewRGBA(goimage.Rect(0, 0, bounds.Dx()/2, bounds.Dy()/2))
+	for x := 0; x < bounds.Dx()/2; x++ {
+		for y := 0; y < bounds.Dy()/2; y++ {
+			dst.Set(x, y, src.At(bounds.Min.X+x*2, bounds.Min.Y+y*2))
+		}
+	}
+	var out bytes.Buffer
+	require.NoError(t, png.Encode(&out, dst))
+	return out.Bytes()
+}
+
+func TestImage_Similar(t *testing.T) {
+	setViper()
+	db, _, userUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+
+	data, err := os.ReadFile(test_image_path)
+	require.NoError(t, err)
+	original, err := imageBiz.Create(ctx, userUUID, &api.CreateImageRequest{UserUUID: userUUID}, makeFileHeader(t, "original.png", "image/png", data))
+	require.NoError(t, err)
+	defer imageBiz.Delete(ctx, userUUID, original.ImageUUID)
+
+	resized, err := imageBiz.Create(ctx, userUUID, &api.CreateImageRequest{UserUUID: userUUID}, makeFileHeader(t, "resized.png", "image/png", halveImage(t, data)))
+	require.NoError(t, err)
+	defer imageBiz.Delete(ctx, userUUID, resized.ImageUUID)
+	assert.Contains(t, resized.NearDuplicates, original.ImageUUID)
+
+	resp, err := imageBiz.Similar(ctx, userUUID, resized.ImageUUID, &api.SimilarImagesRequest{})
+	require.NoError(t, err)
+	found := false
+	for _, image := range resp.ImageList {
+		assert.NotEqual(t, resized.ImageUUID, image.ImageUUID)
+		if image.ImageUUID == original.ImageUUID {
+			found = true
+			assert.LessOrEqual(t, image.Distance, 10)
+		}
+	}
+	assert.True(t, found)
+
+	// 匿名用户不能查询私有图片的近似图片
+	_, err = imageBiz.Similar(ctx, "", original.ImageUUID, &api.SimilarImagesRequest{})
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+
+	tooFar := 25
+	_, err = imageBiz.Similar(ctx, userUUID, resized.ImageUUID, &api.SimilarImagesRequest{Distance: &tooFar})
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+}
@@ -0,0 +1,117 @@
+package biz_test
+
+import (
+	"context"
+	"demo520/internal/520/biz"
+	tagbiz "demo520/internal/520/biz/tag"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagRules(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ds := store.NewStore(db)
+	tagBiz := biz.NewIBiz(ds).Tags()
+	ctx := context.Background()
+	suffix := "_" + faker.UUIDDigit()[:8]
+	kitty, cat, animal := "kitty"+suffix, "cat"+suffix, "animal"+suffix
+
+	imageInfo := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, imageInfo.ImageUUID)
+	// 规则建立前已有的标签
+	require.NoError(t, db.Create(&model.ImageTagM{ImageUUID: imageInfo.ImageUUID, Tag: kitty}).Error)
+
+	_, err = tagBiz.SetAlias(ctx, &api.SetTagAliasRequest{Alias: "-bad", Tag: cat})
+	assert.ErrorIs(t, err, errno.ErrInvalidTag)
+
+	// 规则两端的标签同样会被规范化
+	resp, err := tagBiz.SetAlias(ctx, &api.SetTagAliasRequest{Alias: " " + kitty + " ", Tag: cat})
+	require.NoError(t, err)
+	assert.Equal(t, kitty, resp.Job.Tag)
+	defer tagBiz.DeleteAlias(ctx, kitty)
+	_, err = tagBiz.AddImplication(ctx, &api.AddTagImplicationRequest{Tag: cat, Implies: animal})
+	require.NoError(t, err)
+	defer tagBiz.DeleteImplication(ctx, cat, animal)
+
+	aliases, err := tagBiz.ListAliases(ctx, &api.PageRequest{})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, aliases.Total, int64(1))
+
+	// 后台任务改写已有图片的标签
+	job, err := ds.TagRule().GetRewriteJob(ctx, resp.Job.ID)
+	require.NoError(t, err)
+	require.NoError(t, tagbiz.RunRewriteJob(ctx, ds, job, time.Minute))
+	got, err := imageBiz.Get(ctx, ownerUUID, imageInfo.ImageUUID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{cat, animal}, got.Tags)
+
+	progress, err := tagBiz.GetRewriteJob(ctx, resp.Job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), progress.Processed)
+	_, err = tagBiz.GetRewriteJob(ctx, 0)
+	assert.ErrorIs(t, err, errno.ErrTagRewriteJobNotFound)
+
+	// 新写入的标签直接按规则展开
+	require.NoError(t, imageBiz.ReplaceTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{Tags: []string{kitty}}))
+	got, err = imageBiz.Get(ctx, ownerUUID, imageInfo.ImageUUID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{cat, animal}, got.Tags)
+}
+
+func TestTagDirectory(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	tagBiz := biz.NewIBiz(store.NewStore(db)).Tags()
+	ctx := context.Background()
+	prefix := "dir" + faker.UUIDDigit()[:8]
+
+	imageInfo := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, imageInfo.ImageUUID)
+	require.NoError(t, db.Model(&model.ImageM{}).Where("imageUUID = ?", imageInfo.ImageUUID).Update("visibility", model.VisibilityPrivate).Error)
+	require.NoError(t, imageBiz.ReplaceTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{
+		Tags: []string{prefix + " blue sky", "artist:" + prefix},
+	}))
+
+	// 私有图片的标签不出现在目录中，公开后才计数
+	list, err := tagBiz.List(ctx, &api.ListTagsRequest{Prefix: prefix})
+	require.NoError(t, err)
+	assert.Zero(t, list.Count)
+	public := model.VisibilityPublic
+	_, err = imageBiz.Patch(ctx, ownerUUID, imageInfo.ImageUUID, &api.PatchImageRequest{Visibility: &public})
+	require.NoError(t, err)
+
+	// 前缀中的空白替换为下划线，不带命名空间的前缀匹配全部命名空间
+	list, err = tagBiz.List(ctx, &api.ListTagsRequest{Prefix: strings.ToUpper(prefix) + " blue"})
+	require.NoError(t, err)
+	require.Len(t, list.TagList, 1)
+	assert.Equal(t, api.TagCountInfo{Tag: prefix + "_blue_sky", Namespace: "general", Name: prefix + "_blue_sky", Count: 1}, list.TagList[0])
+	list, err = tagBiz.List(ctx, &api.ListTagsRequest{Prefix: prefix})
+	require.NoError(t, err)
+	assert.Equal(t, 2, list.Count)
+	list, err = tagBiz.List(ctx, &api.ListTagsRequest{Prefix: "artist:" + prefix})
+	require.NoError(t, err)
+	require.Len(t, list.TagList, 1)
+	assert.Equal(t, "artist", list.TagList[0].Namespace)
+
+	_, err = tagBiz.List(ctx, &api.ListTagsRequest{Limit: 51})
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+	popular, err := tagBiz.ListPopular(ctx, &api.ListTagsRequest{})
+	require.NoError(t, err)
+	assert.LessOrEqual(t, popular.Count, 50)
+}
@@ -0,0 +1,140 @@
+package biz_test
+
+import (
+	"context"
+	"demo520/internal/pkg/errno"
+	"demo520/pkg/api"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_ReplaceAndDeleteTags(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+
+	otherReq, err := genNewUser(t, db, nil)
+	require.NoError(t, err)
+	otherInfo, err := getUserBiz(db).Get(ctx, otherReq.Email)
+	require.NoError(t, err)
+
+	imageInfo := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, imageInfo.ImageUUID)
+
+	// 标签去掉首尾空白、空白替换为下划线、转换为小写并去重
+	require.NoError(t, imageBiz.ReplaceTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{
+		Tags: []string{" Blue  Sky ", "blue_sky", "Cat"},
+	}))
+	got, err := imageBiz.Get(ctx, ownerUUID, imageInfo.ImageUUID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"blue_sky", "cat"}, got.Tags)
+
+	// 过滤条件和搜索语句中的标签按相同的方式规范化
+	list, err := imageBiz.ListUserOwnImages(ctx, ownerUUID, 0, 10, &api.ImageListFilter{Tags: []string{" Blue Sky"}})
+	require.NoError(t, err)
+	require.Len(t, list.ImageList, 1)
+	assert.Equal(t, imageInfo.ImageUUID, list.ImageList[0].ImageUUID)
+	_, err = imageBiz.ListUserOwnImages(ctx, ownerUUID, 0, 10, &api.ImageListFilter{ExcludeTags: []string{"a;b"}})
+	assert.ErrorIs(t, err, errno.ErrInvalidTag)
+	found, err := imageBiz.Search(ctx, ownerUUID, &api.SearchImagesRequest{Query: "Blue_Sky BLUE_S* -Dog"})
+	require.NoError(t, err)
+	require.Len(t, found.ImageList, 1)
+	assert.Equal(t, imageInfo.ImageUUID, found.ImageList[0].ImageUUID)
+	_, err = imageBiz.Search(ctx, ownerUUID, &api.SearchImagesRequest{Query: "a;b"})
+	assert.ErrorIs(t, err, errno.ErrInvalidSearchQuery)
+
+	require.NoError(t, imageBiz.DeleteTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{Tags: []string{"CAT", "missing"}}))
+	got, err = imageBiz.Get(ctx, ownerUUID, imageInfo.ImageUUID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blue_sky"}, got.Tags)
+
+	// 只有所有者可以修改标签
+	err = imageBiz.ReplaceTags(ctx, otherInfo.UserUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{})
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+	err = imageBiz.DeleteTags(ctx, otherInfo.UserUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{Tags: []string{"blue_sky"}})
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+
+	for _, tags := range [][]string{{"-sketch"}, {"a;b"}, {"   "}, {strings.Repeat("a", 65)}} {
+		err = imageBiz.ReplaceTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{Tags: tags})
+		assert.ErrorIs(t, err, errno.ErrInvalidTag)
+	}
+	require.NoError(t, imageBiz.ReplaceTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{}))
+	got, err = imageBiz.Get(ctx, ownerUUID, imageInfo.ImageUUID)
+	require.NoError(t, err)
+	assert.Empty(t, got.Tags)
+}
+
+func TestImage_TagLimit(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+
+	imageInfo := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, imageInfo.ImageUUID)
+	genTags := func(prefix string, n int) []string {
+		ret := make([]string, n)
+		for i := range ret {
+			ret[i] = fmt.Sprintf("%s%d", prefix, i)
+		}
+		return ret
+	}
+
+	// 上限按图片上的标签总数计算，多次追加也不能超过
+	require.NoError(t, imageBiz.ReplaceTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{Tags: genTags("a", 60)}))
+	require.NoError(t, imageBiz.UpdateTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{Tags: genTags("b", 40)}))
+	err = imageBiz.UpdateTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{Tags: []string{"c0"}})
+	assert.ErrorIs(t, err, errno.ErrTooManyTags)
+	err = imageBiz.UpdateTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{Tags: genTags("c", 100)})
+	assert.ErrorIs(t, err, errno.ErrTooManyTags)
+
+	got, err := imageBiz.Get(ctx, ownerUUID, imageInfo.ImageUUID)
+	require.NoError(t, err)
+	assert.Len(t, got.Tags, 100)
+	// 已有的标签不计入新增
+	require.NoError(t, imageBiz.UpdateTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{Tags: []string{"a0"}}))
+}
+
+func TestImage_NamespacedTags(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+
+	imageInfo := create_new_image(t, db, ownerUUID)
+	defer imageBiz.Delete(ctx, ownerUUID, imageInfo.ImageUUID)
+
+	// 命名空间不区分大小写，只规范化名称部分；未知的前缀属于标签名称
+	require.NoError(t, imageBiz.ReplaceTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{
+		Tags: []string{"Artist: Foo Bar", "character:alice", "copyright:wonderland", "meta:highres", "cat", "foo:bar"},
+	}))
+	got, err := imageBiz.Get(ctx, ownerUUID, imageInfo.ImageUUID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"artist:foo_bar", "character:alice", "copyright:wonderland", "meta:highres", "cat", "foo:bar"}, got.Tags)
+	assert.Equal(t, []string{"foo_bar"}, got.TagGroups.Artist)
+	assert.Equal(t, []string{"alice"}, got.TagGroups.Character)
+	assert.Equal(t, []string{"wonderland"}, got.TagGroups.Copyright)
+	assert.Equal(t, []string{"highres"}, got.TagGroups.Meta)
+	assert.ElementsMatch(t, []string{"cat", "foo:bar"}, got.TagGroups.General)
+
+	for _, tags := range [][]string{{"artist:"}, {"artist:-foo"}} {
+		err = imageBiz.ReplaceTags(ctx, ownerUUID, imageInfo.ImageUUID, &api.UpdateImageTagsRequest{Tags: tags})
+		assert.ErrorIs(t, err, errno.ErrInvalidTag)
+	}
+
+	list, err := imageBiz.ListUserOwnImages(ctx, ownerUUID, 0, 10, &api.ImageListFilter{Tags: []string{"ARTIST:foo_bar"}})
+	require.NoError(t, err)
+	require.Len(t, list.ImageList, 1)
+	assert.Equal(t, imageInfo.ImageUUID, list.ImageList[0].ImageUUID)
+}
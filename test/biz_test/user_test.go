@@ -149,13 +149,16 @@ func TestUserChangePassword_Success(t *testing.T) {
 		OldPassword: userCreateReq.Password,
 		NewPassword: faker.Password(),
 	}
-	if err := userBiz.ChangePassword(ctx, userCreateReq.Email, &changePasswordReq); err != nil {
-		t.Fatalf("failed to change password: %v", err)
-	}
 	userInfo, err := userBiz.Get(ctx, userCreateReq.Email)
 	if err != nil {
 		t.Fatalf("failed to get user info: %v", err)
 	}
+	// 不能修改其他用户的密码
+	err = userBiz.ChangePassword(ctx, faker.UUIDHyphenated(), userCreateReq.Email, &changePasswordReq)
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+	if err := userBiz.ChangePassword(ctx, userInfo.UserUUID, userCreateReq.Email, &changePasswordReq); err != nil {
+		t.Fatalf("failed to change password: %v", err)
+	}
 	loginReq := api.LoginRequest{
 		Email:    userCreateReq.Email,
 		Password: userCreateReq.Password,
@@ -0,0 +1,71 @@
+package biz_test
+
+import (
+	"context"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/pkg/api"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_Visibility(t *testing.T) {
+	setViper()
+	db, _, ownerUUID, err := setupImageDatabase()
+	require.NoError(t, err)
+	defer cleanTestData()
+	imageBiz := getImageBiz(db)
+	ctx := context.Background()
+
+	data, err := os.ReadFile(test_image_path)
+	require.NoError(t, err)
+	_, err = imageBiz.Create(ctx, ownerUUID, &api.CreateImageRequest{UserUUID: ownerUUID, Visibility: "hidden"},
+		makeFileHeader(t, "invalid.png", "image/png", data))
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+
+	created, err := imageBiz.Create(ctx, ownerUUID, &api.CreateImageRequest{UserUUID: ownerUUID, Visibility: model.VisibilityUnlisted},
+		makeFileHeader(t, "unlisted.png", "image/png", data))
+	require.NoError(t, err)
+	defer imageBiz.Delete(ctx, ownerUUID, created.ImageUUID)
+	assert.Equal(t, model.VisibilityUnlisted, created.Visibility)
+	assert.False(t, created.IsPublic)
+
+	// 不公开列出的图片持有链接即可访问
+	got, err := imageBiz.Get(ctx, "", created.ImageUUID)
+	require.NoError(t, err)
+	assert.Equal(t, model.VisibilityUnlisted, got.Visibility)
+
+	// 但不会出现在公开列表中
+	list, err := imageBiz.ListUserOwnPublicImages(ctx, ownerUUID, 0, 100, nil)
+	require.NoError(t, err)
+	for _, image := range list.ImageList {
+		assert.NotEqual(t, created.ImageUUID, image.ImageUUID)
+	}
+	random, err := imageBiz.ListRandomPublicImages(ctx, 1000)
+	require.NoError(t, err)
+	for _, image := range random.ImageList {
+		assert.NotEqual(t, created.ImageUUID, image.ImageUUID)
+	}
+
+	private := model.VisibilityPrivate
+	patched, err := imageBiz.Patch(ctx, ownerUUID, created.ImageUUID, &api.PatchImageRequest{Visibility: &private})
+	require.NoError(t, err)
+	assert.Equal(t, model.VisibilityPrivate, patched.Visibility)
+	_, err = imageBiz.Get(ctx, "", created.ImageUUID)
+	assert.ErrorIs(t, err, errno.ErrUnauthorized)
+
+	invalid := "everyone"
+	_, err = imageBiz.Patch(ctx, ownerUUID, created.ImageUUID, &api.PatchImageRequest{Visibility: &invalid})
+	assert.ErrorIs(t, err, errno.ErrInvalidParameter)
+
+	// 未指定 visibility 时按 is_public 决定
+	legacy, err := imageBiz.Create(ctx, ownerUUID, &api.CreateImageRequest{UserUUID: ownerUUID, IsPublic: true},
+		makeFileHeader(t, "legacy.png", "image/png", data))
+	require.NoError(t, err)
+	defer imageBiz.Delete(ctx, ownerUUID, legacy.ImageUUID)
+	assert.Equal(t, model.VisibilityPublic, legacy.Visibility)
+	assert.True(t, legacy.IsPublic)
+}
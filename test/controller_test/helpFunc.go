@@ -5,6 +5,7 @@ import (
 	"demo520/internal/520/controller/image"
 	"demo520/internal/520/controller/user"
 	"demo520/internal/520/store"
+	"demo520/internal/pkg/middleware"
 	"demo520/pkg/api"
 	"encoding/json"
 	"fmt"
@@ -57,8 +58,10 @@ func getUserController(db *gorm.DB) *user.UserController {
 	return user.NewUserController(iStore)
 }
 
+// appendJWTHeader 设置认证头并执行认证中间件，模拟路由中的认证流程
 func appendJWTHeader(c *gin.Context, token string) {
 	c.Request.Header.Set("Authorization", "Bearer "+token)
+	middleware.Authn()(c)
 }
 
 func loginAndGetToken(db *gorm.DB, email, password string) (string, error) {
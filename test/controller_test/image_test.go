@@ -1,14 +1,19 @@
 package controller_test
 
 import (
+	imagebiz "demo520/internal/520/biz/image"
+	"demo520/internal/520/store"
 	"demo520/internal/pkg/log"
 	"demo520/internal/pkg/model"
 	"demo520/pkg/api"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/go-faker/faker/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -37,13 +42,18 @@ func setupImageDatabase() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(&model.ImageM{}); err != nil {
+	// images 表需要经过 store.Migrate 完成可见性等数据迁移
+	if err := store.Migrate(db); err != nil {
 		return nil, err
 	}
 
 	if err := db.AutoMigrate(&model.ImageTagM{}); err != nil {
 		return nil, err
 	}
+
+	if err := db.AutoMigrate(&model.BlobM{}, &model.ConvertJobM{}, &model.FavoriteM{}, &model.AlbumM{}, &model.AlbumImageM{}, &model.ShareAccessM{}); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
@@ -92,3 +102,172 @@ func TestImage_Create_Success(t *testing.T) {
 	imageController.Create(c)
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestImage_GetFile_Success(t *testing.T) {
+	setViper()
+	defer cleanTestData()
+	log.Init(nil)
+	db, err := setupImageDatabase()
+	require.NoError(t, err)
+	user, password, err := genUser(db)
+	require.NoError(t, err)
+
+	userToken, err := loginAndGetToken(db, user.Email, password)
+	require.NoError(t, err)
+
+	createImageReq := api.CreateImageRequest{
+		UserUUID: user.UserUUID,
+		IsPublic: true,
+		Tags:     []string{faker.Word(), faker.Word()},
+	}
+	c, w := prepareContextWithFile(t, test_image_path, &createImageReq)
+	appendJWTHeader(c, userToken)
+	imageController := getImageController(db)
+	imageController.Create(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	var createResp api.CreateImageResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &createResp))
+	assert.Equal(t, api.FormatStatusProcessing, createResp.FormatStatus["avif"])
+
+	// WebP/AVIF 由转换队列异步生成，等待两种格式都可用
+	convertQueue := imagebiz.NewConvertQueue(store.NewStore(db))
+	convertQueue.Start()
+	defer convertQueue.Stop()
+	require.Eventually(t, func() bool {
+		c, w := createTestContext("GET", "/v1/images/"+createResp.ImageUUID, nil)
+		c.Params = gin.Params{gin.Param{Key: "imageUUID", Value: createResp.ImageUUID}}
+		imageController.Get(c)
+		var info api.GetImageInfoResponse
+		if json.Unmarshal(w.Body.Bytes(), &info) != nil {
+			return false
+		}
+		return info.FormatStatus["webp"] == api.FormatStatusReady && info.FormatStatus["avif"] == api.FormatStatusReady
+	}, 30*time.Second, 100*time.Millisecond)
+
+	getFile := func(headers map[string]string) *httptest.ResponseRecorder {
+		c, w := createTestContext("GET", "/v1/images/"+createResp.ImageUUID+"/file", nil)
+		c.Params = gin.Params{gin.Param{Key: "imageUUID", Value: createResp.ImageUUID}}
+		for k, v := range headers {
+			c.Request.Header.Set(k, v)
+		}
+		imageController.GetFile(c)
+		return w
+	}
+
+	w = getFile(map[string]string{"Accept": "image/avif,image/webp,*/*;q=0.8"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/avif", w.Header().Get("Content-Type"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = getFile(map[string]string{"Accept": "image/webp"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/webp", w.Header().Get("Content-Type"))
+
+	w = getFile(map[string]string{"Accept": "*/*"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+
+	w = getFile(map[string]string{"Accept": "image/avif", "If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = getFile(map[string]string{"Range": "bytes=0-9"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, 10, w.Body.Len())
+}
+
+func TestImage_GetThumbnail_Success(t *testing.T) {
+	setViper()
+	defer cleanTestData()
+	log.Init(nil)
+	db, err := setupImageDatabase()
+	require.NoError(t, err)
+	user, password, err := genUser(db)
+	require.NoError(t, err)
+
+	userToken, err := loginAndGetToken(db, user.Email, password)
+	require.NoError(t, err)
+
+	createImageReq := api.CreateImageRequest{
+		UserUUID: user.UserUUID,
+		IsPublic: true,
+		Tags:     []string{faker.Word()},
+	}
+	c, w := prepareContextWithFile(t, test_image_path, &createImageReq)
+	appendJWTHeader(c, userToken)
+	imageController := getImageController(db)
+	imageController.Create(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	var createResp api.CreateImageResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &createResp))
+
+	getThumbnail := func(query string) *httptest.ResponseRecorder {
+		c, w := createTestContext("GET", "/v1/images/"+createResp.ImageUUID+"/file?"+query, nil)
+		c.Params = gin.Params{gin.Param{Key: "imageUUID", Value: createResp.ImageUUID}}
+		imageController.GetFile(c)
+		return w
+	}
+
+	w = getThumbnail("w=320&fit=cover&fmt=webp")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/webp", w.Header().Get("Content-Type"))
+
+	// 第二次请求命中磁盘缓存，返回相同的内容
+	body := w.Body.Bytes()
+	w = getThumbnail("w=320&fit=cover&fmt=webp")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, w.Body.Bytes())
+
+	w = getThumbnail("w=321&fmt=webp")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = getThumbnail("fmt=webp")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestImage_GetSharedFile_Range(t *testing.T) {
+	setViper()
+	defer cleanTestData()
+	log.Init(nil)
+	db, err := setupImageDatabase()
+	require.NoError(t, err)
+	user, password, err := genUser(db)
+	require.NoError(t, err)
+	userToken, err := loginAndGetToken(db, user.Email, password)
+	require.NoError(t, err)
+
+	c, w := prepareContextWithFile(t, test_image_path, &api.CreateImageRequest{UserUUID: user.UserUUID})
+	appendJWTHeader(c, userToken)
+	imageController := getImageController(db)
+	imageController.Create(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	var createResp api.CreateImageResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &createResp))
+
+	c, w = createTestContext("POST", "/v1/authenticated/images/"+createResp.ImageUUID+"/share", &api.CreateShareRequest{MaxViews: 2})
+	appendJWTHeader(c, userToken)
+	c.Params = gin.Params{gin.Param{Key: "imageUUID", Value: createResp.ImageUUID}}
+	imageController.CreateShare(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	var share api.CreateShareResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &share))
+
+	getFile := func(headers map[string]string) *httptest.ResponseRecorder {
+		c, w := createTestContext("GET", "/s/"+share.Token+"/file", nil)
+		c.Params = gin.Params{gin.Param{Key: "token", Value: share.Token}}
+		for k, v := range headers {
+			c.Request.Header.Set(k, v)
+		}
+		imageController.GetSharedFile(c)
+		return w
+	}
+
+	// 从文件开头读取的分段计入一次访问，后续分段不计入
+	assert.Equal(t, http.StatusPartialContent, getFile(map[string]string{"Range": "bytes=0-9"}).Code)
+	assert.Equal(t, http.StatusPartialContent, getFile(map[string]string{"Range": "bytes=10-19"}).Code)
+	assert.Equal(t, http.StatusPartialContent, getFile(map[string]string{"Range": "bytes=20-"}).Code)
+	// 后缀区间和带 If-Range 的请求照常计数
+	assert.Equal(t, http.StatusPartialContent, getFile(map[string]string{"Range": "bytes=-10"}).Code)
+	assert.Equal(t, http.StatusGone, getFile(map[string]string{"Range": "bytes=10-19", "If-Range": `"stale"`}).Code)
+	assert.Equal(t, http.StatusGone, getFile(nil).Code)
+}
@@ -144,7 +144,24 @@ func TestUser_CreateAndChangePassword_Success(t *testing.T) {
 		OldPassword: createUserReq.Password,
 		NewPassword: faker.Password(),
 	}
+	userToken, err := loginAndGetToken(db, createUserReq.Email, createUserReq.Password)
+	require.NoError(t, err)
+
+	// 其他用户不能修改该用户的密码
+	other := genCreateUserReq()
+	c, w = createTestContext("POST", "/users", &other)
+	userController.Create(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	otherToken, err := loginAndGetToken(db, other.Email, other.Password)
+	require.NoError(t, err)
 	c, w = createTestContext("POST", "/change", &changePassword)
+	appendJWTHeader(c, otherToken)
+	c.Params = gin.Params{gin.Param{Key: "email", Value: createUserReq.Email}}
+	userController.ChangePassword(c)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	c, w = createTestContext("POST", "/change", &changePassword)
+	appendJWTHeader(c, userToken)
 	c.Params = gin.Params{gin.Param{Key: "email", Value: createUserReq.Email}}
 	userController.ChangePassword(c)
 	assert.Equal(t, http.StatusOK, w.Code)
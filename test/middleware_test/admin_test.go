@@ -0,0 +1,39 @@
+package middleware_test
+
+import (
+	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/middleware"
+	"demo520/pkg/token"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	log.Init(nil)
+	token.Init("")
+	adminUUID := uuid.New().String()
+	adminJWT, err := token.GenerateToken(adminUUID)
+	require.NoError(t, err)
+	userJWT, err := token.GenerateToken(uuid.New().String())
+	require.NoError(t, err)
+
+	g := setupRouter(middleware.Authn(), middleware.RequireAdmin([]string{adminUUID}))
+
+	w := doRequest(g, "Bearer "+adminJWT)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, adminUUID, w.Body.String())
+
+	w = doRequest(g, "Bearer "+userJWT)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doRequest(g, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// 未配置管理员时拒绝所有用户
+	w = doRequest(setupRouter(middleware.Authn(), middleware.RequireAdmin(nil)), "Bearer "+adminJWT)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,77 @@
+package middleware_test
+
+import (
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/log"
+	"demo520/internal/pkg/middleware"
+	"demo520/pkg/token"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRouter(handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	handlers = append(handlers, func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(known.XUsernameKey))
+	})
+	g.GET("/", handlers...)
+	return g
+}
+
+func doRequest(g *gin.Engine, authorization string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	g.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthn(t *testing.T) {
+	log.Init(nil)
+	token.Init("")
+	userUUID := uuid.New().String()
+	jwt, err := token.GenerateToken(userUUID)
+	require.NoError(t, err)
+
+	g := setupRouter(middleware.Authn())
+
+	w := doRequest(g, "Bearer "+jwt)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, userUUID, w.Body.String())
+
+	w = doRequest(g, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doRequest(g, "Bearer invalid")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestOptionalAuthn(t *testing.T) {
+	log.Init(nil)
+	token.Init("")
+	userUUID := uuid.New().String()
+	jwt, err := token.GenerateToken(userUUID)
+	require.NoError(t, err)
+
+	g := setupRouter(middleware.OptionalAuthn())
+
+	w := doRequest(g, "Bearer "+jwt)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, userUUID, w.Body.String())
+
+	w = doRequest(g, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = doRequest(g, "Bearer invalid")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,73 @@
+package middleware_test
+
+import (
+	"demo520/internal/pkg/core"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/known"
+	"demo520/internal/pkg/middleware"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRequestIDRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(middleware.RequestID())
+	g.GET("/ok", func(c *gin.Context) {
+		core.WriteResponse(c, nil, map[string]string{"request_id": c.GetString(known.XRequestIDKey)})
+	})
+	g.GET("/err", func(c *gin.Context) {
+		core.WriteResponse(c, errno.ErrInvalidParameter, nil)
+	})
+	return g
+}
+
+func doRequestWithID(g *gin.Engine, path, requestID string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if requestID != "" {
+		req.Header.Set(known.XRequestIDKey, requestID)
+	}
+	g.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestID_Generate(t *testing.T) {
+	g := setupRequestIDRouter()
+	w := doRequestWithID(g, "/ok", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	requestID := w.Header().Get(known.XRequestIDKey)
+	assert.True(t, govalidator.IsUUIDv4(requestID))
+
+	var resp map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, requestID, resp["request_id"])
+}
+
+func TestRequestID_Propagate(t *testing.T) {
+	g := setupRequestIDRouter()
+	w := doRequestWithID(g, "/ok", "client-request.42")
+	assert.Equal(t, "client-request.42", w.Header().Get(known.XRequestIDKey))
+
+	// 非法的请求 ID 会被替换为新生成的 UUID
+	w = doRequestWithID(g, "/ok", "bad id\nwith newline")
+	assert.True(t, govalidator.IsUUIDv4(w.Header().Get(known.XRequestIDKey)))
+}
+
+func TestRequestID_ErrResponse(t *testing.T) {
+	g := setupRequestIDRouter()
+	w := doRequestWithID(g, "/err", "trace-me")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp core.ErrResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, errno.ErrInvalidParameter.Code, resp.Code)
+	assert.Equal(t, "trace-me", resp.RequestID)
+}
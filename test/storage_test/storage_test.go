@@ -0,0 +1,147 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"demo520/internal/pkg/storage"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBucket = "images"
+
+// newS3Backend 启动一个内存中的 S3 兼容服务，返回连接到该服务的存储后端.
+func newS3Backend(t *testing.T) storage.Backend {
+	mem := s3mem.New()
+	require.NoError(t, mem.CreateBucket(testBucket))
+	server := httptest.NewServer(gofakes3.New(mem).Server())
+	t.Cleanup(server.Close)
+
+	backend, err := storage.NewS3(&storage.S3Options{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Bucket:    testBucket,
+		AccessKey: "test",
+		SecretKey: "test",
+		Region:    "us-east-1",
+		PathStyle: true,
+	})
+	require.NoError(t, err)
+	return backend
+}
+
+func backends(t *testing.T) map[string]storage.Backend {
+	return map[string]storage.Backend{
+		storage.BackendLocal:  storage.NewLocal(t.TempDir()),
+		storage.BackendMemory: storage.NewMemory(),
+		storage.BackendS3:     newS3Backend(t),
+	}
+}
+
+func readAll(t *testing.T, backend storage.Backend, key string) []byte {
+	file, info, err := backend.Get(context.Background(), key)
+	require.NoError(t, err)
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), info.Size)
+	return data
+}
+
+func TestBackend_PutGet(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			content := []byte("hello image")
+			require.NoError(t, backend.Put(ctx, "ab/cdef/abcdef.png", bytes.NewReader(content), int64(len(content))))
+			assert.Equal(t, content, readAll(t, backend, "ab/cdef/abcdef.png"))
+
+			// 覆盖写入
+			content = []byte("replaced")
+			require.NoError(t, backend.Put(ctx, "ab/cdef/abcdef.png", bytes.NewReader(content), -1))
+			assert.Equal(t, content, readAll(t, backend, "ab/cdef/abcdef.png"))
+
+			// 支持 Seek，http.ServeContent 依赖它实现 Range 请求
+			file, _, err := backend.Get(ctx, "ab/cdef/abcdef.png")
+			require.NoError(t, err)
+			defer file.Close()
+			_, err = file.Seek(3, io.SeekStart)
+			require.NoError(t, err)
+			rest, err := io.ReadAll(file)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("laced"), rest)
+
+			info, err := backend.Stat(ctx, "ab/cdef/abcdef.png")
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(content)), info.Size)
+		})
+	}
+}
+
+func TestBackend_NotExist(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := backend.Get(ctx, "ab/missing.png")
+			assert.True(t, errors.Is(err, fs.ErrNotExist), "unexpected error: %v", err)
+			_, err = backend.Stat(ctx, "ab/missing.png")
+			assert.True(t, errors.Is(err, fs.ErrNotExist), "unexpected error: %v", err)
+			// 删除不存在的对象不报错
+			assert.NoError(t, backend.Delete(ctx, "ab/missing.png"))
+		})
+	}
+}
+
+func TestBackend_ListDelete(t *testing.T) {
+	ctx := context.Background()
+	keys := []string{"ab/cd/abcd.png", "ab/cd/abcd.webp", "ab/cd/derived/w160_h0_contain.jpeg", "ab/ce/abce.png"}
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range keys {
+				require.NoError(t, backend.Put(ctx, key, strings.NewReader(key), int64(len(key))))
+			}
+
+			objects, err := backend.List(ctx, "ab/cd/")
+			require.NoError(t, err)
+			var listed []string
+			var total int64
+			for _, obj := range objects {
+				listed = append(listed, obj.Key)
+				total += obj.Size
+			}
+			assert.ElementsMatch(t, keys[:3], listed)
+			assert.Equal(t, int64(len(keys[0])+len(keys[1])+len(keys[2])), total)
+
+			objects, err = backend.List(ctx, "ab/cd/abcd")
+			require.NoError(t, err)
+			assert.Len(t, objects, 2)
+
+			for _, key := range keys[:3] {
+				require.NoError(t, backend.Delete(ctx, key))
+			}
+			objects, err = backend.List(ctx, "ab/cd/")
+			require.NoError(t, err)
+			assert.Empty(t, objects)
+			assert.Equal(t, []byte(keys[3]), readAll(t, backend, keys[3]))
+		})
+	}
+}
+
+func TestBackend_InvalidKey(t *testing.T) {
+	ctx := context.Background()
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, backend.Put(ctx, "../escape.png", strings.NewReader("x"), 1))
+			_, _, err := backend.Get(ctx, "ab/../../escape.png")
+			assert.Error(t, err)
+		})
+	}
+}
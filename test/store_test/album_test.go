@@ -0,0 +1,155 @@
+package store_test
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlbumStore(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.AlbumM{}, &model.AlbumImageM{}))
+
+	ctx := context.Background()
+	s := store.NewStore(db)
+	albumStore := s.Album()
+	owner, viewer := users[0].UserUUID, users[1].UserUUID
+
+	// 前两张为公开图片，第三张为私有图片
+	var imageUUIDs []string
+	for i := 0; i < 3; i++ {
+		image := model.ImageM{
+			ImageUUID:  faker.UUIDHyphenated(),
+			Hash:       genHash(),
+			UserUUID:   owner,
+			Visibility: visibilityOf(i < 2),
+		}
+		require.NoError(t, s.Image().Create(ctx, &image))
+		imageUUIDs = append(imageUUIDs, image.ImageUUID)
+	}
+
+	album := model.AlbumM{
+		AlbumUUID: faker.UUIDHyphenated(),
+		UserUUID:  owner,
+		Title:     faker.Word(),
+		IsPublic:  true,
+	}
+	require.NoError(t, albumStore.Create(ctx, &album))
+	private := model.AlbumM{
+		AlbumUUID: faker.UUIDHyphenated(),
+		UserUUID:  owner,
+		Title:     faker.Word(),
+	}
+	require.NoError(t, albumStore.Create(ctx, &private))
+
+	t.Run("ListByUser", func(t *testing.T) {
+		count, albums, err := albumStore.ListByUser(ctx, owner, false, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+		assert.Len(t, albums, 2)
+
+		count, albums, err = albumStore.ListByUser(ctx, owner, true, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+		require.Len(t, albums, 1)
+		assert.Equal(t, album.AlbumUUID, albums[0].AlbumUUID)
+	})
+
+	t.Run("ListByUserAfter", func(t *testing.T) {
+		first, err := albumStore.ListByUserAfter(ctx, owner, false, nil, 1)
+		require.NoError(t, err)
+		require.Len(t, first, 1)
+		after := &store.AlbumCursor{Time: first[0].CreatedAt, AlbumUUID: first[0].AlbumUUID}
+		rest, err := albumStore.ListByUserAfter(ctx, owner, false, after, 10)
+		require.NoError(t, err)
+		require.Len(t, rest, 1)
+		assert.NotEqual(t, first[0].AlbumUUID, rest[0].AlbumUUID)
+
+		count, err := albumStore.CountByUser(ctx, owner, true)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("AddImages", func(t *testing.T) {
+		require.NoError(t, albumStore.AddImages(ctx, album.AlbumUUID, imageUUIDs[:2], 3))
+		// 已在合集中的图片保持原位置
+		require.NoError(t, albumStore.AddImages(ctx, album.AlbumUUID, []string{imageUUIDs[2], imageUUIDs[0]}, 3))
+
+		_, got, err := albumStore.ListVisibleImageUUIDs(ctx, album.AlbumUUID, owner, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, imageUUIDs, got)
+
+		extra := model.ImageM{ImageUUID: faker.UUIDHyphenated(), Hash: genHash(), UserUUID: owner}
+		require.NoError(t, s.Image().Create(ctx, &extra))
+		err = albumStore.AddImages(ctx, album.AlbumUUID, []string{extra.ImageUUID}, 3)
+		assert.ErrorIs(t, err, errno.ErrAlbumFull)
+	})
+
+	t.Run("ListVisibleImageUUIDs", func(t *testing.T) {
+		count, got, err := albumStore.ListVisibleImageUUIDs(ctx, album.AlbumUUID, viewer, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+		assert.Equal(t, imageUUIDs[:2], got)
+
+		count, got, err = albumStore.ListVisibleImageUUIDs(ctx, album.AlbumUUID, "", 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+		assert.Equal(t, imageUUIDs[1:2], got)
+	})
+
+	t.Run("ListVisibleImagesAfter", func(t *testing.T) {
+		var got []string
+		var after *store.ImageCursor
+		for {
+			page, err := albumStore.ListVisibleImagesAfter(ctx, album.AlbumUUID, viewer, after, 1)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+			got = append(got, page[0].ImageUUID)
+			after = &store.ImageCursor{Position: page[0].Position, ImageUUID: page[0].ImageUUID}
+		}
+		assert.Equal(t, imageUUIDs[:2], got)
+
+		count, err := albumStore.CountVisibleImages(ctx, album.AlbumUUID, viewer)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("Reorder", func(t *testing.T) {
+		err := albumStore.Reorder(ctx, album.AlbumUUID, imageUUIDs[:2])
+		assert.ErrorIs(t, err, errno.ErrAlbumOrderMismatch)
+		err = albumStore.Reorder(ctx, album.AlbumUUID, []string{imageUUIDs[0], imageUUIDs[0], imageUUIDs[1]})
+		assert.ErrorIs(t, err, errno.ErrAlbumOrderMismatch)
+
+		reordered := []string{imageUUIDs[2], imageUUIDs[0], imageUUIDs[1]}
+		require.NoError(t, albumStore.Reorder(ctx, album.AlbumUUID, reordered))
+		_, got, err := albumStore.ListVisibleImageUUIDs(ctx, album.AlbumUUID, owner, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, reordered, got)
+	})
+
+	t.Run("RemoveImage", func(t *testing.T) {
+		require.NoError(t, albumStore.RemoveImage(ctx, album.AlbumUUID, imageUUIDs[2]))
+		_, got, err := albumStore.ListVisibleImageUUIDs(ctx, album.AlbumUUID, owner, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, imageUUIDs[:2], got)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, albumStore.Delete(ctx, album.AlbumUUID))
+		_, err := albumStore.Get(ctx, album.AlbumUUID)
+		assert.Error(t, err)
+
+		var count int64
+		require.NoError(t, db.Model(&model.AlbumImageM{}).Where("albumUUID = ?", album.AlbumUUID).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+}
@@ -0,0 +1,98 @@
+package store_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/model"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func genHash() string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(faker.UUIDHyphenated())))
+}
+
+func TestBlobStore(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.BlobM{}, &model.ConvertJobM{}))
+
+	ctx := context.Background()
+	blobStore := store.NewStore(db).Blob()
+	imageStore := store.NewStore(db).Image()
+
+	liveHash := genHash()
+	orphanHash := genHash()
+	require.NoError(t, blobStore.Touch(ctx, liveHash))
+	require.NoError(t, blobStore.Touch(ctx, orphanHash))
+	live := model.ImageM{
+		ImageUUID: faker.UUIDHyphenated(),
+		Hash:      liveHash,
+		UserUUID:  users[0].UserUUID,
+	}
+	require.NoError(t, imageStore.Create(ctx, &live))
+
+	future := time.Now().Add(time.Minute)
+	recoverableAfter := time.Now().Add(-time.Hour)
+
+	t.Run("grace period protects recent uploads", func(t *testing.T) {
+		deleted, err := blobStore.DeleteIfUnreferenced(ctx, orphanHash, recoverableAfter, time.Now().Add(-time.Hour), func() error { return nil })
+		require.NoError(t, err)
+		assert.False(t, deleted)
+	})
+
+	t.Run("list unreferenced", func(t *testing.T) {
+		blobs, err := blobStore.ListUnreferenced(ctx, "", recoverableAfter, future, 1000)
+		require.NoError(t, err)
+		hashes := make(map[string]struct{}, len(blobs))
+		for _, b := range blobs {
+			hashes[b.Hash] = struct{}{}
+		}
+		assert.Contains(t, hashes, orphanHash)
+		assert.NotContains(t, hashes, liveHash)
+	})
+
+	t.Run("soft deleted image is still recoverable", func(t *testing.T) {
+		require.NoError(t, imageStore.Delete(ctx, live.ImageUUID))
+		removed := false
+		deleted, err := blobStore.DeleteIfUnreferenced(ctx, liveHash, recoverableAfter, future, func() error {
+			removed = true
+			return nil
+		})
+		require.NoError(t, err)
+		assert.False(t, deleted)
+		assert.False(t, removed)
+	})
+
+	t.Run("collect unreferenced blob", func(t *testing.T) {
+		removed := false
+		deleted, err := blobStore.DeleteIfUnreferenced(ctx, orphanHash, recoverableAfter, future, func() error {
+			removed = true
+			return nil
+		})
+		require.NoError(t, err)
+		assert.True(t, deleted)
+		assert.True(t, removed)
+
+		// 回收之后再次上传同一个 hash 会重新登记 blob
+		require.NoError(t, blobStore.Touch(ctx, orphanHash))
+		var count int64
+		require.NoError(t, db.Model(&model.BlobM{}).Where("hash = ?", orphanHash).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("expired soft deleted image is purged", func(t *testing.T) {
+		deleted, err := blobStore.DeleteIfUnreferenced(ctx, liveHash, time.Now().Add(time.Minute), future, func() error { return nil })
+		require.NoError(t, err)
+		assert.True(t, deleted)
+		var count int64
+		require.NoError(t, db.Unscoped().Model(&model.ImageM{}).Where("imageUUID = ?", live.ImageUUID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	})
+}
@@ -0,0 +1,104 @@
+package store_test
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/model"
+	"demo520/internal/pkg/tagquery"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageStore_BulkEdit(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	s := store.NewStore(db)
+	owner, other := users[0].UserUUID, users[1].UserUUID
+	prefix := tagPrefix("bulk")
+	tagsOf := func(imageUUID string) []string {
+		image, err := s.Image().Get(ctx, imageUUID)
+		require.NoError(t, err)
+		ret := make([]string, len(image.Tags))
+		for i, tag := range image.Tags {
+			ret[i] = tag.FullTag()
+		}
+		return ret
+	}
+	a := createTaggedImage(t, db, owner, model.VisibilityPrivate, prefix+"import", prefix+"old")
+	b := createTaggedImage(t, db, owner, model.VisibilityPrivate, prefix+"import")
+	c := createTaggedImage(t, db, other, model.VisibilityPrivate, prefix+"import", prefix+"old")
+	missing := faker.UUIDHyphenated()
+
+	t.Run("ListOwnUUIDsMatching", func(t *testing.T) {
+		q, err := tagquery.Parse(prefix + "import")
+		require.NoError(t, err)
+		count, err := s.Image().CountOwnMatching(ctx, owner, q)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		first, err := s.Image().ListOwnUUIDsMatching(ctx, owner, q, "", 1)
+		require.NoError(t, err)
+		require.Len(t, first, 1)
+		rest, err := s.Image().ListOwnUUIDsMatching(ctx, owner, q, first[0], 10)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{a, b}, append(first, rest...))
+	})
+
+	t.Run("BulkEdit", func(t *testing.T) {
+		edited, err := s.Image().BulkEdit(ctx, owner, []string{a, b, c, missing}, &store.BulkImageEdit{
+			AddTags:    []string{prefix + "new"},
+			RemoveTags: []string{prefix + "old"},
+			Visibility: model.VisibilityPublic,
+			MaxTags:    2,
+		})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{a, b}, edited)
+
+		assert.ElementsMatch(t, []string{prefix + "import", prefix + "new"}, tagsOf(a))
+		assert.ElementsMatch(t, []string{prefix + "import", prefix + "new"}, tagsOf(b))
+		// 其他用户的图片保持不变
+		assert.ElementsMatch(t, []string{prefix + "import", prefix + "old"}, tagsOf(c))
+		image, err := s.Image().Get(ctx, c)
+		require.NoError(t, err)
+		assert.Equal(t, model.VisibilityPrivate, image.Visibility)
+
+		// 公开后计入标签计数
+		tags, err := s.TagCount().ListByPrefix(ctx, prefix, 10)
+		require.NoError(t, err)
+		counts := make(map[string]int64, len(tags))
+		for _, tag := range tags {
+			counts[tag.FullTag()] = tag.PublicCount
+		}
+		assert.Equal(t, map[string]int64{prefix + "import": 2, prefix + "new": 2}, counts)
+	})
+
+	t.Run("MaxTags", func(t *testing.T) {
+		// 超过上限的图片整张跳过，删除标签和修改可见性也不生效
+		edited, err := s.Image().BulkEdit(ctx, owner, []string{a, b}, &store.BulkImageEdit{
+			AddTags:    []string{prefix + "extra"},
+			RemoveTags: []string{prefix + "new"},
+			Visibility: model.VisibilityPrivate,
+			MaxTags:    2,
+		})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{a, b}, edited)
+
+		edited, err = s.Image().BulkEdit(ctx, owner, []string{a, b}, &store.BulkImageEdit{
+			AddTags:    []string{prefix + "one", prefix + "two"},
+			RemoveTags: []string{prefix + "extra"},
+			Visibility: model.VisibilityPublic,
+			MaxTags:    2,
+		})
+		require.NoError(t, err)
+		assert.Empty(t, edited)
+		assert.ElementsMatch(t, []string{prefix + "import", prefix + "extra"}, tagsOf(a))
+		image, err := s.Image().Get(ctx, a)
+		require.NoError(t, err)
+		assert.Equal(t, model.VisibilityPrivate, image.Visibility)
+	})
+}
@@ -0,0 +1,107 @@
+package store_test
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/model"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestConvertJobStore(t *testing.T) {
+	db, _, err := setupImageDatabase()
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.ConvertJobM{}))
+	// 清空残留任务，保证 Claim 只会领取本测试创建的任务
+	require.NoError(t, db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&model.ConvertJobM{}).Error)
+
+	ctx := context.Background()
+	jobStore := store.NewStore(db).ConvertJob()
+	hash := genHash()
+	formats := []string{"webp", "avif"}
+
+	t.Run("enqueue is idempotent", func(t *testing.T) {
+		require.NoError(t, jobStore.Enqueue(ctx, hash, formats))
+		require.NoError(t, jobStore.Enqueue(ctx, hash, formats))
+		jobs, err := jobStore.ListByHashes(ctx, []string{hash})
+		require.NoError(t, err)
+		require.Len(t, jobs, 2)
+		for _, job := range jobs {
+			assert.Equal(t, model.ConvertJobPending, job.Status)
+		}
+	})
+
+	t.Run("claim takes a lease", func(t *testing.T) {
+		first, err := jobStore.Claim(ctx, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, model.ConvertJobProcessing, first.Status)
+		assert.Equal(t, 1, first.Attempts)
+
+		second, err := jobStore.Claim(ctx, time.Minute)
+		require.NoError(t, err)
+		assert.NotEqual(t, first.ID, second.ID)
+
+		// 两个任务都在租约内，没有可领取的任务
+		_, err = jobStore.Claim(ctx, time.Minute)
+		assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+		first.Status = model.ConvertJobReady
+		require.NoError(t, jobStore.Update(ctx, first))
+		// 租约过期的任务可以被重新领取
+		second.NextRunAt = time.Now().Add(-time.Second)
+		require.NoError(t, jobStore.Update(ctx, second))
+		reclaimed, err := jobStore.Claim(ctx, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, second.ID, reclaimed.ID)
+		assert.Equal(t, 2, reclaimed.Attempts)
+
+		reclaimed.Status = model.ConvertJobFailed
+		reclaimed.LastError = "boom"
+		require.NoError(t, jobStore.Update(ctx, reclaimed))
+	})
+
+	t.Run("list by hashes", func(t *testing.T) {
+		jobs, err := jobStore.ListByHashes(ctx, []string{hash, genHash()})
+		require.NoError(t, err)
+		statuses := make(map[string]string)
+		for _, job := range jobs {
+			statuses[job.Format] = job.Status
+		}
+		assert.ElementsMatch(t, []string{model.ConvertJobReady, model.ConvertJobFailed}, []string{statuses["webp"], statuses["avif"]})
+	})
+	t.Run("list ready without size", func(t *testing.T) {
+		jobs, err := jobStore.ListReadyWithoutSize(ctx, 0, 1000)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		assert.Equal(t, hash, jobs[0].Hash)
+
+		jobs[0].Size = 2048
+		require.NoError(t, jobStore.Update(ctx, jobs[0]))
+		jobs, err = jobStore.ListReadyWithoutSize(ctx, 0, 1000)
+		require.NoError(t, err)
+		assert.Empty(t, jobs)
+	})
+
+	t.Run("enqueue retries failed jobs", func(t *testing.T) {
+		require.NoError(t, jobStore.Enqueue(ctx, hash, formats))
+		jobs, err := jobStore.ListByHashes(ctx, []string{hash})
+		require.NoError(t, err)
+		require.Len(t, jobs, 2)
+		for _, job := range jobs {
+			if job.Size == 2048 {
+				// 已完成的任务保持不变
+				assert.Equal(t, model.ConvertJobReady, job.Status)
+				continue
+			}
+			assert.Equal(t, model.ConvertJobPending, job.Status)
+			assert.Zero(t, job.Attempts)
+			assert.Empty(t, job.LastError)
+			assert.False(t, job.NextRunAt.After(time.Now()))
+		}
+	})
+}
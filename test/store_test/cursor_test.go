@@ -0,0 +1,79 @@
+package store_test
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/model"
+	"testing"
+	"time"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageStore_ListUserImagesAfter(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	imageStore := store.NewStore(db).Image()
+	owner := users[0].UserUUID
+
+	// 前三张共享同一创建时间，用于检查同一时间内按 imageUUID 排序；偶数下标为公开图片
+	base := time.Now().Truncate(time.Second)
+	images := make([]model.ImageM, 5)
+	for i := range images {
+		createdAt := base
+		if i >= 3 {
+			createdAt = base.Add(-time.Duration(i) * time.Minute)
+		}
+		images[i] = model.ImageM{
+			ImageUUID:  faker.UUIDHyphenated(),
+			Hash:       genHash(),
+			UserUUID:   owner,
+			Visibility: visibilityOf(i%2 == 0),
+			CreatedAt:  createdAt,
+		}
+		require.NoError(t, imageStore.Create(ctx, &images[i]))
+	}
+
+	t.Run("all", func(t *testing.T) {
+		var got []*model.ImageM
+		var after *store.ImageCursor
+		for {
+			page, err := imageStore.ListUserImagesAfter(ctx, owner, false, nil, after, 2)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+			got = append(got, page...)
+			last := page[len(page)-1]
+			after = &store.ImageCursor{Time: last.CreatedAt, ImageUUID: last.ImageUUID}
+		}
+		require.Len(t, got, len(images))
+		for i := 1; i < len(got); i++ {
+			prev, cur := got[i-1], got[i]
+			assert.True(t, prev.CreatedAt.After(cur.CreatedAt) ||
+				(prev.CreatedAt.Equal(cur.CreatedAt) && prev.ImageUUID > cur.ImageUUID))
+		}
+	})
+
+	t.Run("public", func(t *testing.T) {
+		page, err := imageStore.ListUserImagesAfter(ctx, owner, true, nil, nil, 10)
+		require.NoError(t, err)
+		assert.Len(t, page, 3)
+		for _, image := range page {
+			assert.Equal(t, model.VisibilityPublic, image.Visibility)
+		}
+	})
+
+	t.Run("count", func(t *testing.T) {
+		count, err := imageStore.CountUserImages(ctx, owner, false, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), count)
+		count, err = imageStore.CountUserImages(ctx, owner, true, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+}
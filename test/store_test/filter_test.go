@@ -0,0 +1,119 @@
+package store_test
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/model"
+	"testing"
+	"time"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func imageUUIDsOf(images []*model.ImageM) []string {
+	ret := make([]string, len(images))
+	for i, image := range images {
+		ret[i] = image.ImageUUID
+	}
+	return ret
+}
+
+func TestImageStore_ListUserImagesFilter(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	s := store.NewStore(db)
+	imageStore := s.Image()
+	owner := users[0].UserUUID
+
+	// 创建时间从早到晚，第一张被收藏两次，第二张被收藏一次
+	base := time.Now().Truncate(time.Second)
+	specs := []struct {
+		visibility string
+		mimeType   string
+		tags       []string
+	}{
+		{model.VisibilityPublic, "image/png", []string{"cat"}},
+		{model.VisibilityPrivate, "image/jpeg", []string{"cat", "dog"}},
+		{model.VisibilityUnlisted, "image/png", []string{"dog"}},
+		{model.VisibilityPublic, "image/jpeg", nil},
+	}
+	uuids := make([]string, len(specs))
+	for i, spec := range specs {
+		image := model.ImageM{
+			ImageUUID:  faker.UUIDHyphenated(),
+			Hash:       genHash(),
+			UserUUID:   owner,
+			Visibility: spec.visibility,
+			MimeType:   spec.mimeType,
+			CreatedAt:  base.Add(time.Duration(i-len(specs)+1) * time.Hour),
+		}
+		require.NoError(t, imageStore.Create(ctx, &image))
+		if len(spec.tags) > 0 {
+			require.NoError(t, imageStore.AddTagsToImage(ctx, image.ImageUUID, spec.tags, 0))
+		}
+		uuids[i] = image.ImageUUID
+	}
+	require.NoError(t, s.Favorite().Add(ctx, users[0].UserUUID, uuids[0]))
+	require.NoError(t, s.Favorite().Add(ctx, users[1].UserUUID, uuids[0]))
+	require.NoError(t, s.Favorite().Add(ctx, users[1].UserUUID, uuids[1]))
+
+	list := func(t *testing.T, publicOnly bool, opts *store.ImageListOptions) []string {
+		count, images, err := imageStore.ListUserImages(ctx, owner, publicOnly, opts, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(images)), count)
+		return imageUUIDsOf(images)
+	}
+
+	t.Run("visibility", func(t *testing.T) {
+		assert.Equal(t, []string{uuids[3], uuids[0]}, list(t, false, &store.ImageListOptions{Visibility: model.VisibilityPublic}))
+		assert.Empty(t, list(t, true, &store.ImageListOptions{Visibility: model.VisibilityPrivate}))
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		assert.Equal(t, []string{uuids[1], uuids[0]}, list(t, false, &store.ImageListOptions{IncludeTags: []string{"cat"}}))
+		assert.Equal(t, []string{uuids[1]}, list(t, false, &store.ImageListOptions{IncludeTags: []string{"cat", "dog"}}))
+		assert.Equal(t, []string{uuids[3], uuids[0]}, list(t, false, &store.ImageListOptions{ExcludeTags: []string{"dog"}}))
+	})
+
+	t.Run("created range", func(t *testing.T) {
+		after, before := base.Add(-2*time.Hour), base
+		assert.Equal(t, []string{uuids[2], uuids[1]}, list(t, false, &store.ImageListOptions{CreatedAfter: &after, CreatedBefore: &before}))
+	})
+
+	t.Run("mime type", func(t *testing.T) {
+		assert.Equal(t, []string{uuids[2], uuids[0]}, list(t, false, &store.ImageListOptions{MimeType: "image/png"}))
+		assert.Equal(t, []string{uuids[3]}, list(t, true, &store.ImageListOptions{MimeType: "image/jpeg"}))
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		assert.Equal(t, uuids, list(t, false, &store.ImageListOptions{Sort: store.ImageSortOldest}))
+		assert.Equal(t, uuids[:2], list(t, false, &store.ImageListOptions{Sort: store.ImageSortMostFavorited})[:2])
+
+		require.NoError(t, imageStore.Update(ctx, uuids[1], map[string]interface{}{"description": faker.Sentence()}))
+		assert.Equal(t, uuids[1], list(t, false, &store.ImageListOptions{Sort: store.ImageSortUpdated})[0])
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		opts := &store.ImageListOptions{Sort: store.ImageSortMostFavorited}
+		var got []string
+		var after *store.ImageCursor
+		for {
+			page, err := imageStore.ListUserImagesAfter(ctx, owner, false, opts, after, 1)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+			got = append(got, page[0].ImageUUID)
+			after = &store.ImageCursor{FavoriteCount: page[0].FavoriteCount, ImageUUID: page[0].ImageUUID}
+		}
+		assert.Equal(t, list(t, false, opts), got)
+
+		count, err := imageStore.CountUserImages(ctx, owner, false, &store.ImageListOptions{IncludeTags: []string{"dog"}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+}
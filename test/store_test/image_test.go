@@ -40,7 +40,8 @@ func setupImageDatabase() (*gorm.DB, []model.UserM, error) {
 	if err := db.AutoMigrate(&model.UserM{}); err != nil {
 		return nil, nil, err
 	}
-	if err := db.AutoMigrate(&model.ImageM{}); err != nil {
+	// images 表需要经过 store.Migrate 完成可见性等数据迁移
+	if err := store.Migrate(db); err != nil {
 		return nil, nil, err
 	}
 	if err := db.AutoMigrate(&model.ImageTagM{}); err != nil {
@@ -66,6 +67,14 @@ func setupImageDatabase() (*gorm.DB, []model.UserM, error) {
 	return db, users, nil
 }
 
+// visibilityOf 将旧版本的 is_public 转换为可见性.
+func visibilityOf(isPublic bool) string {
+	if isPublic {
+		return model.VisibilityPublic
+	}
+	return model.VisibilityPrivate
+}
+
 func TestImageStore(t *testing.T) {
 	db, users, err := setupImageDatabase()
 	if err != nil {
@@ -92,7 +101,7 @@ func TestImageStore(t *testing.T) {
 						return
 					}
 					images[i].Hash = fmt.Sprintf("%x", hasher.Sum(nil))
-					images[i].IsPublic = i < (imagesCount-1)%2
+					images[i].Visibility = visibilityOf(i < (imagesCount-1)%2)
 					images[i].Tags = []model.ImageTagM{
 						{Tag: faker.Word(), ImageUUID: images[i].ImageUUID},
 						{Tag: faker.Word(), ImageUUID: images[i].ImageUUID},
@@ -118,7 +127,7 @@ func TestImageStore(t *testing.T) {
 			wg.Add(1)
 			go func(i int) {
 				defer wg.Done()
-				if err := imageStore.AddTagsToImage(ctx, images[i].ImageUUID, newTags); err != nil {
+				if err := imageStore.AddTagsToImage(ctx, images[i].ImageUUID, newTags, 0); err != nil {
 					t.Errorf("failed to add tags to image %d: %v", i, err)
 					return
 				}
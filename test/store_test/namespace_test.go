@@ -0,0 +1,67 @@
+package store_test
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/model"
+	"demo520/internal/pkg/tagquery"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageStore_TagNamespaces(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	imageStore := store.NewStore(db).Image()
+	name := tagPrefix("ns")
+	owner := users[0].UserUUID
+	artist := createTaggedImage(t, db, owner, model.VisibilityPublic, "artist:"+name, "cat")
+	// 后添加的标签同样拆分命名空间
+	character := createTaggedImage(t, db, owner, model.VisibilityPublic)
+	require.NoError(t, imageStore.AddTagsToImage(ctx, character, []string{"character:" + name, "meta:" + name}, 0))
+	general := createTaggedImage(t, db, owner, model.VisibilityPublic, name)
+
+	// 命名空间与名称分开保存
+	var tag model.ImageTagM
+	require.NoError(t, db.Where("imageUUID = ? AND namespace = ?", artist, model.TagNamespaceArtist).First(&tag).Error)
+	assert.Equal(t, name, tag.Tag)
+	assert.Equal(t, "artist:"+name, tag.FullTag())
+
+	got, err := imageStore.Get(ctx, character)
+	require.NoError(t, err)
+	namespaces := make([]string, len(got.Tags))
+	for i, tag := range got.Tags {
+		namespaces[i] = tag.Namespace
+	}
+	assert.ElementsMatch(t, []string{model.TagNamespaceCharacter, model.TagNamespaceMeta}, namespaces)
+
+	search := func(query string) []string {
+		q, err := tagquery.Parse(query)
+		require.NoError(t, err)
+		_, images, err := imageStore.Search(ctx, "", q, 0, 100)
+		require.NoError(t, err)
+		uuids := make([]string, len(images))
+		for i, image := range images {
+			uuids[i] = image.ImageUUID
+		}
+		return uuids
+	}
+	assert.Equal(t, []string{artist}, search("artist:"+name))
+	assert.Equal(t, []string{artist}, search("Artist:"+name[:5]+"*"))
+	assert.Equal(t, []string{general}, search(name))
+	assert.ElementsMatch(t, []string{artist, character}, search("artist:"+name+"|character:"+name))
+	assert.Contains(t, search("meta:*"), character)
+	assert.NotContains(t, search("artist:*"), general)
+
+	// 按命名空间删除标签
+	require.NoError(t, imageStore.DeleteTagFromImage(ctx, artist, []string{"artist:" + name}))
+	assert.Empty(t, search("artist:"+name))
+	got, err = imageStore.Get(ctx, artist)
+	require.NoError(t, err)
+	require.Len(t, got.Tags, 1)
+	assert.Equal(t, "cat", got.Tags[0].FullTag())
+}
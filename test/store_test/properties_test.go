@@ -0,0 +1,124 @@
+package store_test
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/model"
+	"slices"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageStore_Properties(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	imageStore := store.NewStore(db).Image()
+
+	hash := genHash()
+	var images []model.ImageM
+	for i := 0; i < 2; i++ {
+		image := model.ImageM{
+			ImageUUID: faker.UUIDHyphenated(),
+			Hash:      hash,
+			UserUUID:  users[0].UserUUID,
+		}
+		require.NoError(t, imageStore.Create(ctx, &image))
+		images = append(images, image)
+	}
+	phash := uint64(0x0f0f0f0f0f0f0f0f)
+	probed := model.ImageM{
+		ImageUUID: faker.UUIDHyphenated(),
+		Hash:      genHash(),
+		UserUUID:  users[0].UserUUID,
+		MimeType:  "image/png",
+		PHash:     &phash,
+	}
+	require.NoError(t, imageStore.Create(ctx, &probed))
+	// 软删除的图片仍可能被恢复，同样需要补齐
+	require.NoError(t, imageStore.Delete(ctx, images[1].ImageUUID))
+
+	hashes, err := imageStore.ListHashesWithoutProperties(ctx, "", 1000)
+	require.NoError(t, err)
+	assert.Contains(t, hashes, hash)
+	assert.NotContains(t, hashes, probed.Hash)
+	assert.IsIncreasing(t, hashes)
+
+	require.NoError(t, imageStore.UpdateByHash(ctx, hash, map[string]interface{}{
+		"width":     640,
+		"height":    480,
+		"mime_type": "image/jpeg",
+		"size":      12345,
+		"phash":     uint64(1),
+	}))
+	for _, image := range images {
+		var got model.ImageM
+		require.NoError(t, db.Unscoped().First(&got, "imageUUID = ?", image.ImageUUID).Error)
+		assert.Equal(t, 640, got.Width)
+		assert.Equal(t, 480, got.Height)
+		assert.Equal(t, "image/jpeg", got.MimeType)
+		assert.Equal(t, int64(12345), got.Size)
+	}
+
+	hashes, err = imageStore.ListHashesWithoutProperties(ctx, "", 1000)
+	require.NoError(t, err)
+	assert.NotContains(t, hashes, hash)
+}
+
+func TestImageStore_Similar(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	imageStore := store.NewStore(db).Image()
+
+	// 低位决定与 base 的汉明距离
+	base := uint64(0xa5a5a5a500000000)
+	create := func(userUUID string, isPublic bool, phash uint64) string {
+		image := model.ImageM{
+			ImageUUID:  faker.UUIDHyphenated(),
+			Hash:       genHash(),
+			UserUUID:   userUUID,
+			Visibility: visibilityOf(isPublic),
+			PHash:      &phash,
+		}
+		require.NoError(t, imageStore.Create(ctx, &image))
+		return image.ImageUUID
+	}
+	ownNear := create(users[0].UserUUID, false, base|0x1)
+	ownFar := create(users[0].UserUUID, true, base|0xffff)
+	otherPublic := create(users[1].UserUUID, true, base|0x3)
+	otherPrivate := create(users[1].UserUUID, false, base)
+
+	uuids := func(images []*model.ImageM) []string {
+		ret := make([]string, len(images))
+		for i, image := range images {
+			ret[i] = image.ImageUUID
+		}
+		return ret
+	}
+
+	images, err := imageStore.ListSimilar(ctx, users[0].UserUUID, base, 4, 100)
+	require.NoError(t, err)
+	got := uuids(images)
+	assert.Contains(t, got, ownNear)
+	assert.Contains(t, got, otherPublic)
+	assert.NotContains(t, got, ownFar)
+	assert.NotContains(t, got, otherPrivate)
+	// 按距离从近到远排序
+	assert.Less(t, slices.Index(got, ownNear), slices.Index(got, otherPublic))
+
+	images, err = imageStore.ListSimilar(ctx, "", base, 4, 100)
+	require.NoError(t, err)
+	got = uuids(images)
+	assert.Contains(t, got, otherPublic)
+	assert.NotContains(t, got, ownNear)
+
+	images, err = imageStore.ListOwnSimilar(ctx, users[1].UserUUID, base, 0, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []string{otherPrivate}, uuids(images))
+}
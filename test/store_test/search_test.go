@@ -0,0 +1,118 @@
+package store_test
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/model"
+	"demo520/internal/pkg/tagquery"
+	"strings"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// tagPrefix 返回以 name 开头的随机标签前缀，避免匹配到其他测试写入的标签.
+func tagPrefix(name string) string {
+	return name + faker.UUIDDigit()[:8] + "_"
+}
+
+// createTaggedImage 为 userUUID 创建一张带有 tags 的图片，返回图片 UUID.
+func createTaggedImage(t *testing.T, db *gorm.DB, userUUID, visibility string, tags ...string) string {
+	image := model.ImageM{ImageUUID: faker.UUIDHyphenated(), Hash: genHash(), UserUUID: userUUID, Visibility: visibility}
+	for _, tag := range tags {
+		image.Tags = append(image.Tags, model.NewImageTag(image.ImageUUID, tag))
+	}
+	require.NoError(t, store.NewStore(db).Image().Create(context.Background(), &image))
+	return image.ImageUUID
+}
+
+func TestImageStore_Search(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	imageStore := store.NewStore(db).Image()
+	prefix := tagPrefix("s")
+	a := createTaggedImage(t, db, users[0].UserUUID, model.VisibilityPublic, prefix+"cat")
+	b := createTaggedImage(t, db, users[0].UserUUID, model.VisibilityPublic, prefix+"cat", prefix+"sketch")
+	c := createTaggedImage(t, db, users[1].UserUUID, model.VisibilityPublic, prefix+"dog")
+	d := createTaggedImage(t, db, users[0].UserUUID, model.VisibilityPrivate, prefix+"cat")
+	createTaggedImage(t, db, users[1].UserUUID, model.VisibilityPrivate, prefix+"cat")
+	f := createTaggedImage(t, db, users[1].UserUUID, model.VisibilityPublic, prefix+"catgirl")
+
+	search := func(userUUID, query string, offset, limit int) (int64, []string) {
+		q, err := tagquery.Parse(strings.ReplaceAll(query, "$", prefix))
+		require.NoError(t, err)
+		count, images, err := imageStore.Search(ctx, userUUID, q, offset, limit)
+		require.NoError(t, err)
+		uuids := make([]string, len(images))
+		for i, image := range images {
+			uuids[i] = image.ImageUUID
+		}
+		return count, uuids
+	}
+
+	for query, want := range map[string][]string{
+		"$cat":                 {a, b, d},
+		"$cat -$sketch":        {a, d},
+		"$cat|$dog":            {a, b, c, d},
+		"~$cat ~$dog -$sketch": {a, c, d},
+		"$cat*":                {a, b, d, f},
+		"$cat* -$cat":          {f},
+		"$cat $dog":            {},
+	} {
+		count, uuids := search(users[0].UserUUID, query, 0, 100)
+		assert.Equal(t, int64(len(want)), count, query)
+		assert.ElementsMatch(t, want, uuids, query)
+	}
+
+	t.Run("anonymous only sees public images", func(t *testing.T) {
+		count, uuids := search("", "$cat", 0, 100)
+		assert.Equal(t, int64(2), count)
+		assert.ElementsMatch(t, []string{a, b}, uuids)
+	})
+
+	t.Run("pages are stable", func(t *testing.T) {
+		_, all := search(users[0].UserUUID, "$cat*", 0, 100)
+		var paged []string
+		for offset := 0; offset < len(all); offset += 3 {
+			count, uuids := search(users[0].UserUUID, "$cat*", offset, 3)
+			assert.Equal(t, int64(len(all)), count)
+			paged = append(paged, uuids...)
+		}
+		assert.Equal(t, all, paged)
+	})
+
+	t.Run("unlisted images are only listed for their owner", func(t *testing.T) {
+		owner := users[2].UserUUID
+		image := model.ImageM{
+			ImageUUID:  faker.UUIDHyphenated(),
+			Hash:       genHash(),
+			UserUUID:   owner,
+			Visibility: model.VisibilityUnlisted,
+		}
+		image.Tags = []model.ImageTagM{{Tag: prefix + "unlisted", ImageUUID: image.ImageUUID}}
+		require.NoError(t, imageStore.Create(ctx, &image))
+
+		for _, userUUID := range []string{"", users[0].UserUUID} {
+			count, _ := search(userUUID, "$unlisted", 0, 100)
+			assert.Zero(t, count)
+		}
+		_, uuids := search(owner, "$unlisted", 0, 100)
+		assert.Equal(t, []string{image.ImageUUID}, uuids)
+
+		_, publicImages, err := imageStore.GetUserPublicImages(ctx, owner, 0, 100)
+		require.NoError(t, err)
+		for _, public := range publicImages {
+			assert.NotEqual(t, image.ImageUUID, public.ImageUUID)
+		}
+
+		// 持有链接即可访问
+		visible, err := imageStore.ListVisibleByUUIDs(ctx, "", []string{image.ImageUUID})
+		require.NoError(t, err)
+		assert.Len(t, visible, 1)
+	})
+}
@@ -0,0 +1,83 @@
+package store_test
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagCountStore(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	s := store.NewStore(db)
+	prefix := tagPrefix("tc")
+	counts := func(query string) map[string]int64 {
+		tags, err := s.TagCount().ListByPrefix(ctx, query, 50)
+		require.NoError(t, err)
+		ret := make(map[string]int64, len(tags))
+		for _, tag := range tags {
+			ret[tag.FullTag()] = tag.PublicCount
+		}
+		return ret
+	}
+	owner := users[0].UserUUID
+	a := createTaggedImage(t, db, owner, model.VisibilityPublic, prefix+"cat", prefix+"dog")
+	b := createTaggedImage(t, db, owner, model.VisibilityPublic, prefix+"cat")
+	c := createTaggedImage(t, db, owner, model.VisibilityPrivate, prefix+"cat")
+	assert.Equal(t, map[string]int64{prefix + "cat": 2, prefix + "dog": 1}, counts(prefix))
+	// 按公开图片数排序
+	tags, err := s.TagCount().ListByPrefix(ctx, prefix, 1)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, prefix+"cat", tags[0].FullTag())
+
+	// tag_counts 为空时重新迁移会按已有的公开图片补齐计数
+	t.Run("Migrate", func(t *testing.T) {
+		require.NoError(t, db.Exec("DELETE FROM tag_counts").Error)
+		require.NoError(t, store.Migrate(db))
+		assert.Equal(t, map[string]int64{prefix + "cat": 2, prefix + "dog": 1}, counts(prefix))
+		require.NoError(t, store.Migrate(db))
+		assert.Equal(t, map[string]int64{prefix + "cat": 2, prefix + "dog": 1}, counts(prefix))
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		require.NoError(t, s.Image().AddTagsToImage(ctx, b, []string{prefix + "dog", "artist:" + prefix + "x"}, 0))
+		assert.Equal(t, map[string]int64{prefix + "cat": 2, prefix + "dog": 2}, counts(prefix))
+		assert.Equal(t, map[string]int64{"artist:" + prefix + "x": 1}, counts("artist:"+prefix))
+
+		require.NoError(t, s.Image().DeleteTagFromImage(ctx, a, []string{prefix + "dog", prefix + "missing"}))
+		require.NoError(t, s.Image().ReplaceTags(ctx, b, []string{prefix + "bird"}, 0))
+		assert.Equal(t, map[string]int64{prefix + "cat": 1, prefix + "bird": 1}, counts(prefix))
+		assert.Empty(t, counts("artist:"+prefix))
+
+		// 私有图片的标签不计数
+		require.NoError(t, s.Image().AddTagsToImage(ctx, c, []string{prefix + "bird"}, 0))
+		assert.Equal(t, map[string]int64{prefix + "cat": 1, prefix + "bird": 1}, counts(prefix))
+	})
+
+	t.Run("Visibility", func(t *testing.T) {
+		require.NoError(t, s.Image().Update(ctx, c, map[string]interface{}{"visibility": model.VisibilityPublic}))
+		assert.Equal(t, map[string]int64{prefix + "cat": 2, prefix + "bird": 2}, counts(prefix))
+		require.NoError(t, s.Image().Update(ctx, a, map[string]interface{}{"visibility": model.VisibilityUnlisted}))
+		assert.Equal(t, map[string]int64{prefix + "cat": 1, prefix + "bird": 2}, counts(prefix))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, s.Image().Delete(ctx, b))
+		_, err := s.Image().DeleteCollection(ctx, users[0].UserUUID, []string{a, c})
+		require.NoError(t, err)
+		assert.Empty(t, counts(prefix))
+
+		popular, err := s.TagCount().ListPopular(ctx, 1000)
+		require.NoError(t, err)
+		for _, tag := range popular {
+			assert.Positive(t, tag.PublicCount)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package store_test
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"demo520/internal/pkg/tagquery"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagRuleStore(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	s := store.NewStore(db)
+	rules := s.TagRule()
+	// 规则是全局的，使用随机后缀避免与其他测试冲突
+	prefix := tagPrefix("tr")
+	kitty, cat, animal, pet := prefix+"kitty", prefix+"cat", prefix+"animal", prefix+"pet"
+
+	job, err := rules.SetAlias(ctx, kitty, cat)
+	require.NoError(t, err)
+	assert.Equal(t, kitty, job.Tag)
+	assert.Equal(t, model.TagRewriteJobPending, job.Status)
+	_, err = rules.AddImplication(ctx, cat, animal)
+	require.NoError(t, err)
+	_, err = rules.AddImplication(ctx, animal, pet)
+	require.NoError(t, err)
+
+	t.Run("Conflicts", func(t *testing.T) {
+		_, err := rules.SetAlias(ctx, prefix+"other", kitty)
+		assert.ErrorIs(t, err, errno.ErrTagRuleConflict)
+		_, err = rules.SetAlias(ctx, cat, prefix+"feline")
+		assert.ErrorIs(t, err, errno.ErrTagRuleConflict)
+		_, err = rules.AddImplication(ctx, kitty, animal)
+		assert.ErrorIs(t, err, errno.ErrTagRuleConflict)
+		_, err = rules.AddImplication(ctx, pet, cat)
+		assert.ErrorIs(t, err, errno.ErrTagRuleConflict)
+	})
+
+	t.Run("Expand", func(t *testing.T) {
+		got, err := rules.Expand(ctx, []string{kitty})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{cat, animal, pet}, got)
+	})
+
+	imageUUID := createTaggedImage(t, db, users[0].UserUUID, model.VisibilityPublic)
+	tagsOf := func() []string {
+		got, err := s.Image().Get(ctx, imageUUID)
+		require.NoError(t, err)
+		ret := make([]string, len(got.Tags))
+		for i, tag := range got.Tags {
+			ret[i] = tag.Tag
+		}
+		return ret
+	}
+
+	t.Run("AddTagsToImage", func(t *testing.T) {
+		require.NoError(t, s.Image().AddTagsToImage(ctx, imageUUID, []string{kitty}, 0))
+		assert.ElementsMatch(t, []string{cat, animal, pet}, tagsOf())
+
+		// 搜索别名时按规范标签匹配
+		q, err := tagquery.Parse(kitty)
+		require.NoError(t, err)
+		count, images, err := s.Image().Search(ctx, "", q, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+		require.Len(t, images, 1)
+		assert.Equal(t, imageUUID, images[0].ImageUUID)
+		// 列表按别名过滤时同样按规范标签匹配
+		_, images, err = s.Image().ListUserImages(ctx, users[0].UserUUID, false, &store.ImageListOptions{IncludeTags: []string{kitty}}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{imageUUID}, imageUUIDsOf(images))
+		_, images, err = s.Image().ListUserImages(ctx, users[0].UserUUID, false, &store.ImageListOptions{ExcludeTags: []string{kitty}}, 0, 10)
+		require.NoError(t, err)
+		assert.NotContains(t, imageUUIDsOf(images), imageUUID)
+
+		require.NoError(t, s.Image().DeleteTagFromImage(ctx, imageUUID, []string{kitty}))
+		assert.ElementsMatch(t, []string{animal, pet}, tagsOf())
+	})
+
+	t.Run("ReapplyTagRules", func(t *testing.T) {
+		// 模拟规则建立前写入的旧标签
+		require.NoError(t, db.Create(&model.ImageTagM{ImageUUID: imageUUID, Tag: kitty}).Error)
+		uuids, err := s.Image().ListUUIDsWithTag(ctx, kitty, "", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{imageUUID}, uuids)
+
+		require.NoError(t, s.Image().ReapplyTagRules(ctx, imageUUID))
+		assert.ElementsMatch(t, []string{cat, animal, pet}, tagsOf())
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, rules.DeleteAlias(ctx, kitty))
+		assert.ErrorIs(t, rules.DeleteAlias(ctx, kitty), errno.ErrTagAliasNotFound)
+		require.NoError(t, rules.DeleteImplication(ctx, animal, pet))
+		assert.ErrorIs(t, rules.DeleteImplication(ctx, animal, pet), errno.ErrTagImplicationNotFound)
+		require.NoError(t, rules.DeleteImplication(ctx, cat, animal))
+
+		got, err := rules.Expand(ctx, []string{kitty})
+		require.NoError(t, err)
+		assert.Equal(t, []string{kitty}, got)
+	})
+}
@@ -0,0 +1,64 @@
+package store_test
+
+import (
+	"context"
+	"demo520/internal/520/store"
+	"demo520/internal/pkg/errno"
+	"demo520/internal/pkg/model"
+	"testing"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageStore_ReplaceTags(t *testing.T) {
+	db, users, err := setupImageDatabase()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	imageStore := store.NewStore(db).Image()
+	image := model.ImageM{ImageUUID: faker.UUIDHyphenated(), Hash: genHash(), UserUUID: users[0].UserUUID}
+	require.NoError(t, imageStore.Create(ctx, &image))
+	require.NoError(t, imageStore.AddTagsToImage(ctx, image.ImageUUID, []string{"cat", "dog"}, 0))
+
+	tagsOf := func() []string {
+		got, err := imageStore.Get(ctx, image.ImageUUID)
+		require.NoError(t, err)
+		ret := make([]string, len(got.Tags))
+		for i, tag := range got.Tags {
+			ret[i] = tag.Tag
+		}
+		return ret
+	}
+	var kept model.ImageTagM
+	require.NoError(t, db.Where("imageUUID = ? AND tag = ?", image.ImageUUID, "cat").First(&kept).Error)
+
+	require.NoError(t, imageStore.ReplaceTags(ctx, image.ImageUUID, []string{"cat", "bird"}, 0))
+	assert.ElementsMatch(t, []string{"cat", "bird"}, tagsOf())
+	// 未变化的标签保留原记录
+	var after model.ImageTagM
+	require.NoError(t, db.Where("imageUUID = ? AND tag = ?", image.ImageUUID, "cat").First(&after).Error)
+	assert.Equal(t, kept.ID, after.ID)
+
+	// 仅大小写不同的标签也会被替换
+	require.NoError(t, imageStore.ReplaceTags(ctx, image.ImageUUID, []string{"Cat"}, 0))
+	assert.Equal(t, []string{"Cat"}, tagsOf())
+
+	require.NoError(t, imageStore.ReplaceTags(ctx, image.ImageUUID, nil, 0))
+	assert.Empty(t, tagsOf())
+
+	// 标签数上限按图片上的标签总数计算
+	require.NoError(t, imageStore.AddTagsToImage(ctx, image.ImageUUID, []string{"cat", "dog"}, 3))
+	err = imageStore.AddTagsToImage(ctx, image.ImageUUID, []string{"bird", "fish"}, 3)
+	assert.ErrorIs(t, err, errno.ErrTooManyTags)
+	assert.ElementsMatch(t, []string{"cat", "dog"}, tagsOf())
+	err = imageStore.ReplaceTags(ctx, image.ImageUUID, []string{"a", "b", "c", "d"}, 3)
+	assert.ErrorIs(t, err, errno.ErrTooManyTags)
+	require.NoError(t, imageStore.ReplaceTags(ctx, image.ImageUUID, nil, 0))
+
+	err = imageStore.ReplaceTags(ctx, faker.UUIDHyphenated(), []string{"cat"}, 0)
+	assert.ErrorIs(t, err, errno.ErrImageNotFound)
+	err = imageStore.DeleteTagFromImage(ctx, faker.UUIDHyphenated(), []string{"cat"})
+	assert.ErrorIs(t, err, errno.ErrImageNotFound)
+}
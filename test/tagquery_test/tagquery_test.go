@@ -0,0 +1,75 @@
+package tagquery_test
+
+import (
+	"demo520/internal/pkg/tagquery"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	q, err := tagquery.Parse("cat  dog|bird* -sketch ~red ~blue")
+	require.NoError(t, err)
+	assert.Equal(t, [][]tagquery.Term{
+		{{Tag: "cat"}},
+		{{Tag: "dog"}, {Tag: "bird", Prefix: true}},
+		{{Tag: "red"}, {Tag: "blue"}},
+	}, q.Include)
+	assert.Equal(t, []tagquery.Term{{Tag: "sketch"}}, q.Exclude)
+
+	q, err = tagquery.Parse("   ")
+	require.NoError(t, err)
+	assert.True(t, q.IsEmpty())
+
+	q, err = tagquery.Parse("-sketch*")
+	require.NoError(t, err)
+	assert.Empty(t, q.Include)
+	assert.Equal(t, []tagquery.Term{{Tag: "sketch", Prefix: true}}, q.Exclude)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"*",
+		"-",
+		"~",
+		"c*t",
+		"cat|",
+		"-cat|dog",
+		"--cat",
+		strings.Repeat("a", 256),
+		strings.Repeat("tag ", tagquery.MaxTerms+1),
+	} {
+		_, err := tagquery.Parse(s)
+		assert.Error(t, err, "query %q", s)
+	}
+}
+
+func TestLikePattern(t *testing.T) {
+	assert.Equal(t, `cat%`, tagquery.LikePattern("cat"))
+	assert.Equal(t, `100\%\_off\\%`, tagquery.LikePattern(`100%_off\`))
+}
+
+func TestNormalize(t *testing.T) {
+	opts := tagquery.NormalizeOptions{Lowercase: true, MaxLength: 16}
+	for in, want := range map[string]string{
+		"  Blue   Sky ": "blue_sky",
+		"artist:Foo":    "artist:foo",
+		"猫":             "猫",
+		"c++":           "c++",
+	} {
+		got, err := tagquery.Normalize(in, opts)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got)
+	}
+
+	got, err := tagquery.Normalize("Blue Sky", tagquery.NormalizeOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Blue_Sky", got)
+
+	for _, in := range []string{"", "   ", "-sketch", "cat*", "cat|dog", "~red", "a;b", strings.Repeat("a", 17)} {
+		_, err := tagquery.Normalize(in, opts)
+		assert.Error(t, err, in)
+	}
+}